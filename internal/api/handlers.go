@@ -1,14 +1,21 @@
 package api
 
 import (
+	"App/internal/archiveservice"
 	"App/internal/blogservice"
+	"App/internal/health"
+	"App/internal/mediaservice"
 	"App/internal/types"
 	"App/internal/userservice"
 	"App/internal/utils"
-	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -18,15 +25,91 @@ func GetNotFoundHandler(context *gin.Context) {
 	utils.SendErrorResponse(context, http.StatusNotFound, utils.INVALID_REQUEST_MESSAGE)
 }
 
-func GetHomePageHandler(context *gin.Context) {
-	// Check if the user is logged in and redirect accordingly
-	if user, isLoggedIn := userservice.IsUserLoggedIn(context); isLoggedIn {
-		context.Redirect(http.StatusFound, "/profile/"+user.Username)
-		return
+func GetHealthzHandler(context *gin.Context) {
+	// The process is up & serving, whatever state its dependencies are in
+	context.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+func GetReadyzHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		checks, ready := health.Ready(context.Request.Context(), app)
+
+		// Tell the proxy to hold traffic back until the database & schema are usable
+		if !ready {
+			context.JSON(http.StatusServiceUnavailable, gin.H{"status": "unavailable", "checks": checks})
+			return
+		}
+
+		context.JSON(http.StatusOK, gin.H{"status": "ok", "checks": checks})
+	}
+}
+
+func PostCSPReportHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Browsers send reports on their own, so nothing useful can be returned beyond an acknowledgement
+		body, err := io.ReadAll(io.LimitReader(context.Request.Body, utils.CSP_REPORT_MAX_BYTES))
+
+		if err != nil {
+			context.Status(http.StatusBadRequest)
+			return
+		}
+
+		// Older browsers post a single report, the Reporting API posts a batch
+		var reports []types.CSPReport
+		var legacy types.CSPLegacyReport
+		var batch []types.CSPReportingAPIReport
+
+		if json.Unmarshal(body, &legacy) == nil && legacy.Report.EffectiveDirective != "" {
+			reports = append(reports, legacy.Report)
+		} else if json.Unmarshal(body, &batch) == nil {
+			for _, report := range batch {
+				if report.Type != "csp-violation" {
+					continue
+				}
+
+				reports = append(reports, types.CSPReport{
+					EffectiveDirective: report.Body.EffectiveDirective,
+					BlockedURI:         report.Body.BlockedURL,
+					SourceFile:         report.Body.SourceFile,
+					LineNumber:         report.Body.LineNumber,
+					Disposition:        report.Body.Disposition,
+				})
+			}
+		}
+
+		// The document URL is left out of the log since share links carry their token in the path
+		for _, report := range reports {
+			app.Logger.WarnContext(context.Request.Context(), "Content Security Policy violation",
+				"directive", report.EffectiveDirective,
+				"blocked", report.BlockedURI,
+				"source", report.SourceFile,
+				"line", report.LineNumber,
+				"disposition", report.Disposition,
+			)
+		}
+
+		context.Status(http.StatusNoContent)
 	}
+}
+
+func GetHomePageHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Check if the user is logged in and redirect accordingly
+		if user, isLoggedIn := userservice.IsUserLoggedIn(context); isLoggedIn {
+			context.Redirect(http.StatusFound, "/profile/"+user.Username)
+			return
+		}
+
+		// Show a few trending posts to logged out visitors
+		posts, _, err := blogservice.GetTrendingPosts(context.Request.Context(), app, utils.TRENDING_WINDOW_WEEK, "", 0, 1, utils.TRENDING_HOME_PAGE_POSTS)
+
+		if err != nil {
+			app.Logger.ErrorContext(context.Request.Context(), "Failed to load trending posts for the home page", "error", err)
+		}
 
-	// Render the default homepage if the user is not logged in
-	context.HTML(http.StatusOK, utils.ROOT_PAGE, nil)
+		// Render the default homepage if the user is not logged in
+		context.HTML(http.StatusOK, utils.ROOT_PAGE, &types.HomePageData{Posts: posts})
+	}
 }
 
 func GetLoginPageHandler(context *gin.Context) {
@@ -41,13 +124,13 @@ func PostLoginHandler(app *types.App) gin.HandlerFunc {
 
 		// Validate the username & password form inputs
 		if err := userservice.ValidateAuthInputLength(username, password); err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		// Authenticate user credentials and save session
 		if err := userservice.VerifyUserCredentialsAndSaveSession(username, password, context, app); err != nil {
-			utils.SendErrorResponse(context, http.StatusUnauthorized, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -59,8 +142,8 @@ func PostLoginHandler(app *types.App) gin.HandlerFunc {
 func PostLogoutHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		// Call LogoutSession to log the user out and handle any errors
-		if err := userservice.LogoutUserSession(context, app.SessionStore); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+		if err := userservice.LogoutUserSession(context, app); err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -81,13 +164,13 @@ func PostSignupHandler(app *types.App) gin.HandlerFunc {
 
 		// Validate the username & password form inputs
 		if err := userservice.ValidateAuthInputLength(username, password); err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		// Attempt to create a new user in the database
 		if err := userservice.RegisterUserAndSaveSession(username, password, context, app); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -96,7 +179,7 @@ func PostSignupHandler(app *types.App) gin.HandlerFunc {
 	}
 }
 
-func RenderUserProfilePageHandler(db *sql.DB) gin.HandlerFunc {
+func RenderUserProfilePageHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		// Retrieve the username from the URL parameter
 		username := strings.ToLower(context.Param(utils.USERNAME))
@@ -104,54 +187,101 @@ func RenderUserProfilePageHandler(db *sql.DB) gin.HandlerFunc {
 		// Check if the user is logged in and if the requested user is the owner of the blog
 		user, isLoggedIn, isOwner := userservice.GetUserAndStatus(context, username)
 
-		// Handle pagination to determine which posts to retrieve
-		page := blogservice.GetPageQuery(context)
+		// Optional tag filter for the listed posts
+		tag := blogservice.GetTagQuery(context)
 
-		// Fetch the blog posts from the database
-		posts, totalCount, err := blogservice.GetBlogPostsByUser(db, username, isOwner, page, user.ID)
+		// Load the display name, bio, links & avatar shown above the posts
+		profile, err := userservice.GetUserProfile(context.Request.Context(), app, username)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusNotFound, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
-		previews := make([]types.BlogPreview, len(posts))
+		// Work out whether the viewer follows, blocked or muted the profile's owner
+		relationship := &types.UserRelationship{}
 
-		for i, p := range posts {
-			previews[i] = types.BlogPreview{
-				ID:      p.ID,
-				Title:   p.Title,
-				Content: p.Content,
+		if isLoggedIn && !isOwner {
+			if relationship, err = blogservice.GetUserRelationship(context.Request.Context(), app, user.ID, username); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+		}
+
+		// Users who blocked the viewer look like they don't exist
+		if relationship.IsBlockedBy {
+			utils.SendErrorResponse(context, http.StatusNotFound, "user not found")
+			return
+		}
+
+		// Private accounts only show their posts, tags & follow lists to the owner & approved followers
+		isLocked := profile.IsPrivate && !isOwner && !relationship.IsFollowing
+
+		// Fetch the tag cloud built from the user's public posts, unless the viewer blocked them
+		var tagCloud []*types.TagCount
+
+		if !relationship.IsBlocked && !isLocked {
+			if tagCloud, err = blogservice.GetTagCloudForUser(context.Request.Context(), app, username); err != nil {
+				utils.SendServiceError(context, err)
+				return
 			}
 		}
 
-		isFollowing := false
+		// API clients & infinite scroll page through posts with a cursor
+		if utils.WantsJSON(context) {
+			cursor, err := blogservice.GetCursorQuery(context)
 
-		if isLoggedIn {
-			if f, err := blogservice.IsFollowingUser(db, user.ID, username); err == nil {
-				isFollowing = f
-			} else {
-				utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			if err != nil {
+				utils.SendServiceError(context, err)
 				return
 			}
+
+			limit := blogservice.GetLimitQuery(context, app.PostsPerPage)
+
+			posts, nextCursor, err := blogservice.GetBlogPostsByUserAfter(context.Request.Context(), app, username, isOwner, user.ID, tag, cursor, limit)
+
+			if err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			context.JSON(http.StatusOK, gin.H{"profile": profile, "relationship": relationship, "isLocked": isLocked, "posts": toBlogPreviews(posts), "nextCursor": nextCursor, "tags": tagCloud})
+			return
+		}
+
+		// Handle pagination to determine which posts to retrieve
+		page := blogservice.GetPageQuery(context)
+
+		// Fetch the blog posts from the database
+		posts, totalCount, err := blogservice.GetBlogPostsByUser(context.Request.Context(), app, username, isOwner, page, user.ID, tag, app.PostsPerPage)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
 		}
 
+		tabs := blogservice.TotalPages(totalCount, app.PostsPerPage)
+
 		htmlPayload := &types.BlogPageData{
-			Username:    utils.CapitalizeFirstLetter(username),
-			Posts:       posts,
-			IsOwner:     isOwner,
-			IsLoggedIn:  isLoggedIn,
-			IsFollowing: isFollowing,
-			CurrentPage: page,
-			Tabs:        (totalCount + utils.POST_LIMIT_PER_PAGE - 1) / utils.POST_LIMIT_PER_PAGE,
+			Username:     utils.CapitalizeFirstLetter(username),
+			Profile:      profile,
+			Posts:        posts,
+			IsOwner:      isOwner,
+			IsLoggedIn:   isLoggedIn,
+			Relationship: relationship,
+			IsLocked:     isLocked,
+			CurrentPage:  page,
+			Tabs:         tabs,
+			Tag:          tag,
+			TagCloud:     tagCloud,
 		}
 
-		context.Negotiate(http.StatusOK, gin.Negotiate{
-			Offered:  []string{gin.MIMEJSON, gin.MIMEHTML},
-			HTMLName: utils.USER_PROFILE_PAGE,
-			JSONData: gin.H{"posts": previews},
-			Data:     htmlPayload,
-		})
+		// Let infinite scroll continue from the last post on this page
+		if page < tabs && len(posts) > 0 {
+			htmlPayload.NextCursor = posts[len(posts)-1].Cursor
+		}
+
+		context.HTML(http.StatusOK, utils.USER_PROFILE_PAGE, htmlPayload)
 	}
 }
 
@@ -161,7 +291,7 @@ func RenderSingleBlogPostHandler(app *types.App) gin.HandlerFunc {
 		id, err := blogservice.ValidatePostIDInput(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -169,15 +299,50 @@ func RenderSingleBlogPostHandler(app *types.App) gin.HandlerFunc {
 		user, isLoggedIn := userservice.IsUserLoggedIn(context)
 
 		// Get blog post data from the database
-		pageData, err := blogservice.GetBlogPostData(app.Database, id, user.ID, isLoggedIn)
+		pageData, err := blogservice.GetBlogPostData(context.Request.Context(), app, id, user.ID, isLoggedIn)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusNotFound, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
-		// Render the blog post
-		context.HTML(http.StatusOK, utils.BLOG_POST_PAGE, pageData)
+		// Load the images attached to the post
+		if pageData.Media, err = mediaservice.GetMediaForPost(context.Request.Context(), app.Database, id); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Private posts can't be saved, everything else shows where the user filed it
+		if isLoggedIn && pageData.Post.Visibility != utils.VISIBILITY_PRIVATE {
+			if pageData.IsBookmarked, pageData.BookmarkedIn, err = blogservice.GetBookmark(context.Request.Context(), app, user.ID, id); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			if pageData.Collections, err = blogservice.GetBookmarkCollections(context.Request.Context(), app, user.ID); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+		}
+
+		// Render the blog post, or hand API clients the post with its author & comments
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.BLOG_POST_PAGE,
+			HTMLData: pageData,
+			JSONData: gin.H{
+				"post": toBlogPreviews([]*types.BlogPostData{pageData.Post})[0],
+				"author": gin.H{
+					"username":    pageData.Username,
+					"displayName": pageData.DisplayName,
+					"avatarUrl":   pageData.AvatarURL,
+				},
+				"media":      pageData.Media,
+				"comments":   pageData.Comments,
+				"likesCount": pageData.LikesCount,
+				"bookmarked": pageData.IsBookmarked,
+			},
+		})
 	}
 }
 
@@ -187,7 +352,7 @@ func GetCreateOrEditPostPageHandler(app *types.App) gin.HandlerFunc {
 		postID, isEditMode, err := blogservice.GetPostIDAndMode(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -200,14 +365,19 @@ func GetCreateOrEditPostPageHandler(app *types.App) gin.HandlerFunc {
 			IsEditing: isEditMode,
 			PostID:    postID,
 			BlogPostBase: types.BlogPostBase{
-				IsPublic: true, // Default for new posts
+				Visibility: utils.VISIBILITY_PUBLIC, // Default for new posts
 			},
 		}
 
 		// Populate form data if editing a post
 		if isEditMode {
-			if err := blogservice.GetPostDataOnEdit(app.Database, formData, postID, user.ID); err != nil {
-				utils.SendErrorResponse(context, http.StatusNotFound, err.Error())
+			if err := blogservice.GetPostDataOnEdit(context.Request.Context(), app, formData, postID, user.ID); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			if formData.Media, err = mediaservice.GetMediaForPost(context.Request.Context(), app.Database, postID); err != nil {
+				utils.SendServiceError(context, err)
 				return
 			}
 		}
@@ -221,28 +391,44 @@ func CreatePostHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		// Retrieve form values
 		title := context.PostForm("title")
-		isPublic := context.DefaultPostForm("isPublic", "false") // Default to "0" if not selected
+		visibility := context.DefaultPostForm("visibility", utils.VISIBILITY_PRIVATE) // Default to private if not selected
 		message := context.PostForm("message")
 
 		// Validate form values
-		if err := blogservice.ValidatePostInputs(title, isPublic, message); err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+		if err := blogservice.ValidatePostInputs(title, visibility, message); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Parse & validate the comma separated tags
+		tags, err := blogservice.ParseTags(context.PostForm("tags"))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		// Get user info from context
 		user := userservice.GetUserFromContext(context)
 
-		// Execute the query with parameterized values
-		if err := blogservice.InsertBlogPostIntoDB(app.Database, &types.CreateBlogPost{
+		// Attach the images uploaded from the editor in the same transaction as the post
+		media := mediaservice.NewPostMedia(app, user.ID, context.PostFormArray("media"), !blogservice.IsEncryptedVisibility(visibility))
+
+		_, err = blogservice.InsertBlogPostIntoDB(context.Request.Context(), app, &types.CreateBlogPost{
 			BlogPostBase: types.BlogPostBase{
-				Title:    title,
-				IsPublic: blogservice.ConvertIsPublicToBool(isPublic),
-				Content:  message,
+				Title:      title,
+				Visibility: visibility,
+				Content:    message,
+				Tags:       tags,
 			},
 			UserID: user.ID,
-		}); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+		}, media.Attach)
+
+		// Image blobs follow the transaction, deleted ones go & re-encrypted ones are put back if it failed
+		media.Finish(context.Request.Context(), err == nil)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -255,12 +441,20 @@ func UpdatePostHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		// Retrieve form values
 		title := context.PostForm("title")
-		isPublic := context.DefaultPostForm("isPublic", "false")
+		visibility := context.DefaultPostForm("visibility", utils.VISIBILITY_PRIVATE)
 		message := context.PostForm("message")
 
 		// Validate form values
-		if err := blogservice.ValidatePostInputs(title, isPublic, message); err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+		if err := blogservice.ValidatePostInputs(title, visibility, message); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Parse & validate the comma separated tags
+		tags, err := blogservice.ParseTags(context.PostForm("tags"))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -268,21 +462,32 @@ func UpdatePostHandler(app *types.App) gin.HandlerFunc {
 		id, err := blogservice.ValidatePostIDInput(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
-		// Update Blog Post Data
-		if err := blogservice.UpdateBlogPostInDB(app.Database, &types.UpdateBlogPost{
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Sync the post's images with the editor in the same transaction, re-encrypting them if the visibility changed
+		media := mediaservice.NewPostMedia(app, user.ID, context.PostFormArray("media"), !blogservice.IsEncryptedVisibility(visibility))
+
+		err = blogservice.UpdateBlogPostInDB(context.Request.Context(), app, &types.UpdateBlogPost{
 			BlogPostBase: types.BlogPostBase{
-				Title:    title,
-				Content:  message,
-				IsPublic: blogservice.ConvertIsPublicToBool(isPublic),
+				Title:      title,
+				Content:    message,
+				Visibility: visibility,
+				Tags:       tags,
 			},
-			UserID: (userservice.GetUserFromContext(context)).ID,
+			UserID: user.ID,
 			ID:     id,
-		}); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+		}, media.Attach)
+
+		// Image blobs follow the transaction, deleted ones go & re-encrypted ones are put back if it failed
+		media.Finish(context.Request.Context(), err == nil)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -300,45 +505,246 @@ func DeletePostHandler(app *types.App) gin.HandlerFunc {
 		id, err := blogservice.ValidatePostIDInput(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Remember the post's images, deleting the post detaches them
+		media, err := mediaservice.GetMediaForPost(context.Request.Context(), app.Database, id)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		// Delete Blog Post
-		if err := blogservice.DeleteBlogPostFromDB(app.Database, id, user.ID); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+		if err := blogservice.DeleteBlogPostFromDB(context.Request.Context(), app, id, user.ID); err != nil {
+			utils.SendServiceError(context, err)
 			return
 		}
 
+		// Anything left over is picked up by the unattached media cleanup
+		if err := mediaservice.DeleteMedia(context.Request.Context(), app, media); err != nil {
+			app.Logger.ErrorContext(context.Request.Context(), "Failed to delete images", "post_id", id, "error", err)
+		}
+
 		// Redirect to the user's page after successful deletion
 		context.Redirect(http.StatusFound, "/profile/"+user.Username)
 	}
 }
 
+func GetPostHistoryHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Only the owner gets any revisions back
+		revisions, err := blogservice.GetPostRevisions(context.Request.Context(), app, postID, user.ID)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if len(revisions) == 0 {
+			utils.SendErrorResponse(context, http.StatusNotFound, "post not found or access denied")
+			return
+		}
+
+		// Compare the chosen revision with the one before it unless told otherwise
+		to := findRevision(revisions, context.Query("to"), len(revisions)-1)
+		toIndex := slices.Index(revisions, to)
+		from := findRevision(revisions, context.Query("from"), max(toIndex-1, 0))
+
+		pageData := types.RevisionsPageData{
+			PostID:       postID,
+			Title:        revisions[len(revisions)-1].Title,
+			Username:     utils.CapitalizeFirstLetter(user.Username),
+			From:         from,
+			To:           to,
+			Diff:         blogservice.DiffRevisions(from, to),
+			TitleChanged: from.Title != to.Title,
+			TagsChanged:  strings.Join(from.Tags, ",") != strings.Join(to.Tags, ","),
+		}
+
+		// List the newest revision first
+		for i := len(revisions) - 1; i >= 0; i-- {
+			pageData.Revisions = append(pageData.Revisions, revisions[i])
+		}
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.REVISIONS_PAGE,
+			HTMLData: pageData,
+			JSONData: gin.H{
+				"revisions": pageData.Revisions,
+				"from":      from.ID,
+				"to":        to.ID,
+				"diff":      pageData.Diff,
+			},
+		})
+	}
+}
+
+func PostRestoreRevisionHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		revisionID, err := strconv.Atoi(context.Param("revision"))
+
+		if err != nil || revisionID < 1 {
+			utils.SendErrorResponse(context, http.StatusBadRequest, "invalid revision ID")
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Restoring saves the old version as a new revision, so it can be undone too
+		if err := blogservice.RestoreRevision(context.Request.Context(), app, postID, revisionID, user.ID); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/blogpost/"+strconv.Itoa(postID))
+	}
+}
+
+func GetShareLinksHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Only the owner can see the links, each one is rebuilt with its key
+		pageData, err := blogservice.GetShareLinksPageData(context.Request.Context(), app, postID, user.ID, app.SiteURL)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// The page holds secret links, keep it out of every cache
+		context.Header("Cache-Control", "no-store")
+		context.HTML(http.StatusOK, utils.SHARE_LINKS_PAGE, pageData)
+	}
+}
+
+func PostShareLinkHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.CreateShareLink(context.Request.Context(), app, postID, user.ID, context.PostForm("expires")); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/blogpost/"+strconv.Itoa(postID)+"/share")
+	}
+}
+
+func PostRevokeShareLinkHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.RevokeShareLink(context.Request.Context(), app, postID, context.Param("token"), user.ID); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/blogpost/"+strconv.Itoa(postID)+"/share")
+	}
+}
+
+func GetSharedPostHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// The page only carries ciphertext, the key in the URL fragment never reaches the server
+		pageData, err := blogservice.GetSharedPost(context.Request.Context(), app, context.Param("token"))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Shared posts stay out of caches, search engines & referrers
+		context.Header("Cache-Control", "no-store")
+		context.Header("X-Robots-Tag", "noindex, nofollow")
+		context.Header("Referrer-Policy", "no-referrer")
+		context.HTML(http.StatusOK, utils.SHARED_POST_PAGE, pageData)
+	}
+}
+
+func findRevision(revisions []*types.PostRevision, rawID string, fallback int) *types.PostRevision {
+	// Unknown or missing IDs fall back to the given position
+	if id, err := strconv.Atoi(rawID); err == nil {
+		for _, revision := range revisions {
+			if revision.ID == id {
+				return revision
+			}
+		}
+	}
+
+	return revisions[fallback]
+}
+
 func PostCommentHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
 		postID, err := blogservice.ValidatePostIDInput(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		comment := context.PostForm("content")
 
 		if err := blogservice.IsValidComment(comment); err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		user := userservice.GetUserFromContext(context)
 
-		if err := blogservice.InsertCommentIntoDB(app.Database, &types.CreateComment{
+		if err := blogservice.InsertCommentIntoDB(context.Request.Context(), app, &types.CreateComment{
 			PostID:  postID,
 			UserID:  user.ID,
 			Comment: comment,
 		}); err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -351,16 +757,16 @@ func PostLikeHandler(app *types.App) gin.HandlerFunc {
 		postID, err := blogservice.ValidatePostIDInput(context)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusBadRequest, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
 		user := userservice.GetUserFromContext(context)
 
-		liked, err := blogservice.ToggleLikeOnPost(app.Database, postID, user.ID)
+		liked, err := blogservice.ToggleLikeOnPost(context.Request.Context(), app, postID, user.ID)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
@@ -381,39 +787,999 @@ func PostFollowHandler(app *types.App) gin.HandlerFunc {
 		// Get the current user from the context
 		user := userservice.GetUserFromContext(context)
 
-		// Attempt to toggle follow
-		err := blogservice.ToggleFollowUser(app.Database, user.ID, username)
+		// Attempt to toggle follow, private accounts get a request instead
+		status, err := blogservice.ToggleFollowUser(context.Request.Context(), app, user.ID, username)
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
-		// No response needed, just send 200 OK
-		context.Status(http.StatusOK)
+		// Tell the button whether the user now follows, is waiting or neither
+		context.JSON(http.StatusOK, gin.H{"status": status})
 	}
 }
 
-func GetHomeFeedHandler(app *types.App) gin.HandlerFunc {
+func PostBlockHandler(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
 		// Get the current user from the context
 		user := userservice.GetUserFromContext(context)
 
-		// Handle pagination to determine which posts to retrieve
-		page := blogservice.GetPageQuery(context)
+		blocked, err := blogservice.ToggleBlockUser(context.Request.Context(), app, user.ID, username)
 
-		// Get the user's feed
-		posts, totalCount, err := blogservice.GetHomeFeedPosts(app.Database, user.ID, page)
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusOK, gin.H{"blocked": blocked})
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/profile/"+username)
+	}
+}
+
+func PostMuteHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
+		// Get the current user from the context
+		user := userservice.GetUserFromContext(context)
+
+		muted, err := blogservice.ToggleMuteUser(context.Request.Context(), app, user.ID, username)
 
 		if err != nil {
-			utils.SendErrorResponse(context, http.StatusInternalServerError, err.Error())
+			utils.SendServiceError(context, err)
 			return
 		}
 
-		// If not AJAX, We pass the page data to render the HTML page
-		context.HTML(http.StatusOK, "feed.html", &types.HomeFeedPage{
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusOK, gin.H{"muted": muted})
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/profile/"+username)
+	}
+}
+
+func GetFollowListHandler(app *types.App, list string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
+		// Check if the user is logged in
+		user, isLoggedIn := userservice.IsUserLoggedIn(context)
+
+		relationship, err := blogservice.GetUserRelationship(context.Request.Context(), app, user.ID, username)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Users who blocked the viewer look like they don't exist
+		if relationship.IsBlockedBy {
+			utils.SendErrorResponse(context, http.StatusNotFound, "user not found")
+			return
+		}
+
+		// Private accounts only show who they follow & who follows them to the owner & approved followers
+		if relationship.IsPrivate && relationship.UserID != user.ID && !relationship.IsFollowing {
+			utils.SendServiceError(context, utils.Forbidden("this account is private"))
+			return
+		}
+
+		profile, err := userservice.GetUserProfile(context.Request.Context(), app, username)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Handle pagination to determine which users to list
+		page := blogservice.GetPageQuery(context)
+
+		users, totalCount, err := blogservice.GetFollowList(context.Request.Context(), app, relationship.UserID, list, user.ID, page, utils.FOLLOW_LIST_PAGE_SIZE)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		tabs := blogservice.TotalPages(totalCount, utils.FOLLOW_LIST_PAGE_SIZE)
+
+		// Render the list, or hand API clients the page of users with the totals
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.FOLLOW_LIST_PAGE,
+			HTMLData: &types.FollowListPageData{
+				Username:    utils.CapitalizeFirstLetter(username),
+				DisplayName: profile.DisplayName,
+				List:        list,
+				Users:       users,
+				Total:       totalCount,
+				IsLoggedIn:  isLoggedIn,
+				CurrentPage: page,
+				Tabs:        tabs,
+			},
+			JSONData: gin.H{
+				"username":   profile.Username,
+				"list":       list,
+				"users":      users,
+				"total":      totalCount,
+				"page":       page,
+				"totalPages": tabs,
+			},
+		})
+	}
+}
+
+func GetFollowRequestsHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Handle pagination to determine which requests to list
+		page := blogservice.GetPageQuery(context)
+
+		requests, totalCount, err := blogservice.GetFollowRequests(context.Request.Context(), app, user.ID, page, utils.FOLLOW_LIST_PAGE_SIZE)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.FOLLOW_REQUESTS_PAGE,
+			HTMLData: &types.FollowRequestsPageData{
+				Requests:    requests,
+				CurrentPage: page,
+				Tabs:        blogservice.TotalPages(totalCount, utils.FOLLOW_LIST_PAGE_SIZE),
+			},
+			JSONData: gin.H{"requests": requests, "page": page, "total": totalCount},
+		})
+	}
+}
+
+func PostFollowRequestHandler(app *types.App, approve bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.RespondToFollowRequest(context.Request.Context(), app, user.ID, username, approve); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.Status(http.StatusOK)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/settings/follow-requests")
+	}
+}
+
+func PostBookmarkHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// An empty collection saves the post without filing it anywhere
+		collectionID, err := parseCollectionID(context.PostForm(utils.COLLECTION))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.SaveBookmark(context.Request.Context(), app, user.ID, postID, collectionID); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusOK, gin.H{"bookmarked": true, "collection": collectionID})
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/blogpost/"+strconv.Itoa(postID))
+	}
+}
+
+func PostRemoveBookmarkHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		postID, err := blogservice.ValidatePostIDInput(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.RemoveBookmark(context.Request.Context(), app, user.ID, postID); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusOK, gin.H{"bookmarked": false})
+			return
+		}
+
+		// Removing from the Saved page should land back on it
+		if context.PostForm("redirect") == "saved" {
+			context.Redirect(http.StatusFound, "/saved")
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/blogpost/"+strconv.Itoa(postID))
+	}
+}
+
+func GetSavedPageHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		collectionID, err := parseCollectionID(context.Query(utils.COLLECTION))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		collections, err := blogservice.GetBookmarkCollections(context.Request.Context(), app, user.ID)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Only the user's own collections can be listed
+		var collectionName string
+
+		if collectionID != 0 {
+			for _, collection := range collections {
+				if collection.ID == collectionID {
+					collectionName = collection.Name
+				}
+			}
+
+			if collectionName == "" {
+				utils.SendErrorResponse(context, http.StatusNotFound, "collection not found")
+				return
+			}
+		}
+
+		// Handle pagination to determine which bookmarks to list
+		page := blogservice.GetPageQuery(context)
+
+		posts, totalCount, err := blogservice.GetSavedPosts(context.Request.Context(), app, user.ID, collectionID, page, utils.SAVED_PAGE_SIZE)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		tabs := blogservice.TotalPages(totalCount, utils.SAVED_PAGE_SIZE)
+
+		previews := make([]types.SavedPreview, len(posts))
+
+		for i, post := range posts {
+			previews[i] = types.SavedPreview{
+				FeedPreview: toFeedPreview(&post.HomeFeedData),
+				SavedAt:     post.SavedAt,
+				Collection:  post.Collection,
+			}
+		}
+
+		// Bookmarks are private, so neither the page nor the JSON may be cached
+		context.Header("Cache-Control", "no-store")
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.SAVED_PAGE,
+			HTMLData: &types.SavedPageData{
+				Posts:        posts,
+				Collections:  collections,
+				CollectionID: collectionID,
+				Collection:   collectionName,
+				CanCreate:    len(collections) < utils.BOOKMARK_COLLECTIONS_MAX,
+				CurrentPage:  page,
+				Tabs:         tabs,
+			},
+			JSONData: gin.H{
+				"posts":       previews,
+				"collections": collections,
+				"collection":  collectionID,
+				"total":       totalCount,
+				"page":        page,
+				"totalPages":  tabs,
+			},
+		})
+	}
+}
+
+func PostBookmarkCollectionHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		collection, err := blogservice.CreateBookmarkCollection(context.Request.Context(), app, user.ID, context.PostForm("name"))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.JSON(http.StatusCreated, collection)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/saved?collection="+strconv.Itoa(collection.ID))
+	}
+}
+
+func PostDeleteBookmarkCollectionHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		collectionID, err := parseCollectionID(context.Param(utils.COLLECTION))
+
+		if err != nil || collectionID == 0 {
+			utils.SendErrorResponse(context, http.StatusBadRequest, "invalid collection")
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := blogservice.DeleteBookmarkCollection(context.Request.Context(), app, user.ID, collectionID); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		if utils.WantsJSON(context) {
+			context.Status(http.StatusOK)
+			return
+		}
+
+		context.Redirect(http.StatusFound, "/saved")
+	}
+}
+
+func GetHomeFeedHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Get the current user from the context
+		user := userservice.GetUserFromContext(context)
+
+		// Optional tag filter for the feed
+		tag := blogservice.GetTagQuery(context)
+
+		// API clients & infinite scroll page through the feed with a cursor
+		if utils.WantsJSON(context) {
+			cursor, err := blogservice.GetCursorQuery(context)
+
+			if err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			limit := blogservice.GetLimitQuery(context, app.PostsPerPage)
+
+			posts, nextCursor, err := blogservice.GetHomeFeedPostsAfter(context.Request.Context(), app, user.ID, tag, cursor, limit)
+
+			if err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			context.JSON(http.StatusOK, gin.H{"posts": toFeedPreviews(posts), "nextCursor": nextCursor})
+			return
+		}
+
+		// Handle pagination to determine which posts to retrieve
+		page := blogservice.GetPageQuery(context)
+
+		// Get the user's feed
+		posts, totalCount, err := blogservice.GetHomeFeedPosts(context.Request.Context(), app, user.ID, page, tag, app.PostsPerPage)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		tabs := blogservice.TotalPages(totalCount, app.PostsPerPage)
+
+		feedPage := &types.HomeFeedPage{
 			Posts:       posts,
 			CurrentPage: page,
-			Tabs:        (totalCount + utils.POST_LIMIT_PER_PAGE - 1) / utils.POST_LIMIT_PER_PAGE,
-		})
+			Tabs:        tabs,
+			Tag:         tag,
+		}
+
+		// Let infinite scroll continue from the last post on this page
+		if page < tabs && len(posts) > 0 {
+			feedPage.NextCursor = posts[len(posts)-1].Cursor
+		}
+
+		context.HTML(http.StatusOK, utils.FEED_PAGE, feedPage)
+	}
+}
+
+func RenderTagPageHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Validate the tag name from the URL
+		tag, err := blogservice.ValidateTagParam(context)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Check if the user is logged in, their blocks are hidden from the tag
+		user, isLoggedIn := userservice.IsUserLoggedIn(context)
+
+		// API clients & infinite scroll page through the tag with a cursor
+		if utils.WantsJSON(context) {
+			cursor, err := blogservice.GetCursorQuery(context)
+
+			if err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			limit := blogservice.GetLimitQuery(context, app.PostsPerPage)
+
+			posts, nextCursor, err := blogservice.GetPostsByTagAfter(context.Request.Context(), app, tag, user.ID, cursor, limit)
+
+			if err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+
+			context.JSON(http.StatusOK, gin.H{"tag": tag, "posts": toFeedPreviews(posts), "nextCursor": nextCursor})
+			return
+		}
+
+		// Handle pagination to determine which posts to retrieve
+		page := blogservice.GetPageQuery(context)
+
+		// Fetch the public posts carrying this tag
+		posts, totalCount, err := blogservice.GetPostsByTag(context.Request.Context(), app, tag, user.ID, page, app.PostsPerPage)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		tabs := blogservice.TotalPages(totalCount, app.PostsPerPage)
+
+		tagPage := &types.TagPageData{
+			Tag:         tag,
+			Posts:       posts,
+			IsLoggedIn:  isLoggedIn,
+			CurrentPage: page,
+			Tabs:        tabs,
+		}
+
+		// Let infinite scroll continue from the last post on this page
+		if page < tabs && len(posts) > 0 {
+			tagPage.NextCursor = posts[len(posts)-1].Cursor
+		}
+
+		context.HTML(http.StatusOK, utils.TAG_PAGE, tagPage)
+	}
+}
+
+func GetExplorePageHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Check if the user is logged in
+		user, isLoggedIn := userservice.IsUserLoggedIn(context)
+
+		// Read the optional time window & tag filters
+		window := blogservice.GetWindowQuery(context)
+		tag := blogservice.GetTagQuery(context)
+
+		// Handle pagination to determine which posts to retrieve
+		page := blogservice.GetPageQuery(context)
+
+		// Fetch the ranked posts from the trending cache
+		posts, totalCount, err := blogservice.GetTrendingPosts(context.Request.Context(), app, window, tag, user.ID, page, app.PostsPerPage)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		previews := make([]types.TrendingPreview, len(posts))
+
+		for i, p := range posts {
+			previews[i] = types.TrendingPreview{
+				FeedPreview:   toFeedPreview(&p.HomeFeedData),
+				LikesCount:    p.LikesCount,
+				CommentsCount: p.CommentsCount,
+			}
+		}
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.EXPLORE_PAGE,
+			JSONData: gin.H{"window": window, "tag": tag, "page": page, "posts": previews},
+			Data: &types.ExplorePageData{
+				Posts:       posts,
+				IsLoggedIn:  isLoggedIn,
+				Tag:         tag,
+				Window:      window,
+				CurrentPage: page,
+				Tabs:        blogservice.TotalPages(totalCount, app.PostsPerPage),
+			},
+		})
+	}
+}
+
+func GetUserFeedHandler(app *types.App, format string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Retrieve the username from the URL parameter
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
+		// Build the feed from the user's public posts only
+		feed, err := blogservice.BuildUserFeed(context.Request.Context(), app, app.SiteURL, username, format)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		writeFeed(context, feed, format)
+	}
+}
+
+func GetPublicFeedHandler(app *types.App, format string) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Build the site-wide feed from the newest public posts
+		feed, err := blogservice.BuildPublicFeed(context.Request.Context(), app, app.SiteURL, format)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		writeFeed(context, feed, format)
+	}
+}
+
+func writeFeed(context *gin.Context, feed *types.FeedData, format string) {
+	// Encode the feed in the requested format
+	render, contentType := blogservice.RenderRSS, utils.RSS_CONTENT_TYPE
+
+	if format == utils.FEED_FORMAT_ATOM {
+		render, contentType = blogservice.RenderAtom, utils.ATOM_CONTENT_TYPE
+	}
+
+	body, err := render(feed)
+
+	if err != nil {
+		utils.SendServiceError(context, err)
+		return
+	}
+
+	// Let feed readers revalidate cheaply instead of downloading the feed again
+	etag := utils.ComputeETag(body)
+	lastModified := blogservice.FeedLastModified(feed)
+
+	context.Header("ETag", etag)
+	context.Header("Cache-Control", "no-cache")
+
+	if !lastModified.IsZero() {
+		context.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	}
+
+	if utils.IsNotModified(context, etag, lastModified) {
+		context.Status(http.StatusNotModified)
+		return
+	}
+
+	context.Data(http.StatusOK, contentType, body)
+}
+
+func GetExportHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Retrieve the logged in user from context
+		user := userservice.GetUserFromContext(context)
+
+		// Stream the archive straight to the client as it is built
+		fileName := fmt.Sprintf("posto-%s-%s.zip", user.Username, app.Clock.Now().UTC().Format("2006-01-02"))
+
+		context.Header("Content-Type", "application/zip")
+		context.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", fileName))
+		context.Header("Cache-Control", "no-store")
+
+		allowLongRequest(context)
+
+		if err := archiveservice.WriteUserArchive(context.Request.Context(), app, context.Writer, user.Username); err != nil {
+			app.Logger.ErrorContext(context.Request.Context(), "Failed to export data", "username", user.Username, "error", err)
+
+			// Once bytes are sent the download can only be cut short
+			if !context.Writer.Written() {
+				context.Header("Content-Type", "")
+				context.Header("Content-Disposition", "")
+				utils.SendErrorResponse(context, http.StatusInternalServerError, "Failed to export your data. Please try again.")
+			}
+		}
+	}
+}
+
+func GetSettingsPageHandler(context *gin.Context) {
+	// Render the settings menu, it has no per-user data yet
+	context.HTML(http.StatusOK, utils.SETTINGS_PAGE, nil)
+}
+
+func PostMediaUploadHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Cap the upload before reading any of it
+		context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, utils.MEDIA_MAX_UPLOAD_BYTES+1<<20)
+
+		file, _, err := context.Request.FormFile("file")
+
+		if err != nil {
+			utils.SendServiceError(context, utils.Validation("Please choose an image of at most %d MB.", utils.MEDIA_MAX_UPLOAD_BYTES>>20))
+			return
+		}
+
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, utils.MEDIA_MAX_UPLOAD_BYTES+1))
+
+		if err != nil || len(data) > utils.MEDIA_MAX_UPLOAD_BYTES {
+			utils.SendServiceError(context, utils.TooLarge("Images may be at most %d MB.", utils.MEDIA_MAX_UPLOAD_BYTES>>20))
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Images for private posts are encrypted from the start
+		isPublic := !blogservice.IsEncryptedVisibility(context.DefaultPostForm("visibility", utils.VISIBILITY_PRIVATE))
+
+		media, err := mediaservice.UploadMedia(context.Request.Context(), app, user.ID, data, isPublic)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.JSON(http.StatusCreated, media)
+	}
+}
+
+func GetMediaHandler(app *types.App, thumbnail bool) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Anonymous visitors can only see unencrypted media
+		user, _ := userservice.IsUserLoggedIn(context)
+
+		data, media, err := mediaservice.ReadMedia(context.Request.Context(), app, context.Param("token"), thumbnail, user.ID)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Public media never changes under its token, private media must not sit in shared caches
+		if media.IsEncrypted || media.IsRestricted {
+			context.Header("Cache-Control", "private, no-store")
+		} else {
+			context.Header("Cache-Control", "public, max-age=31536000, immutable")
+		}
+
+		context.Header("X-Content-Type-Options", "nosniff")
+		context.Header("Content-Security-Policy", "default-src 'none'")
+
+		context.Data(http.StatusOK, media.ContentType, data)
+	}
+}
+
+func GetProfileSettingsHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Get user info from the context (set in middleware)
+		user := userservice.GetUserFromContext(context)
+
+		profile, err := userservice.GetUserProfile(context.Request.Context(), app, user.Username)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		context.HTML(http.StatusOK, utils.PROFILE_PAGE, toProfileFormData(user.Username, profile))
+	}
+}
+
+func PostProfileSettingsHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Cap the upload before reading any of it
+		context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, utils.AVATAR_MAX_UPLOAD_BYTES+1<<20)
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		if err := userservice.UpdateUserProfile(context.Request.Context(), app, user.ID, context.PostForm("displayName"), context.PostForm("bio"), context.PostForm("links"), context.PostForm("isPrivate") == "true"); err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// A new avatar replaces the old one, otherwise it may be removed
+		if file, _, err := context.Request.FormFile("avatar"); err == nil {
+			defer file.Close()
+
+			data, err := io.ReadAll(io.LimitReader(file, utils.AVATAR_MAX_UPLOAD_BYTES+1))
+
+			if err != nil || len(data) > utils.AVATAR_MAX_UPLOAD_BYTES {
+				utils.SendErrorResponse(context, http.StatusRequestEntityTooLarge, fmt.Sprintf("Avatars may be at most %d MB.", utils.AVATAR_MAX_UPLOAD_BYTES>>20))
+				return
+			}
+
+			if err := userservice.SaveAvatar(context.Request.Context(), app, user.ID, data); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+		} else if context.PostForm("removeAvatar") == "true" {
+			if err := userservice.RemoveAvatar(context.Request.Context(), app, user.ID); err != nil {
+				utils.SendServiceError(context, err)
+				return
+			}
+		}
+
+		profile, err := userservice.GetUserProfile(context.Request.Context(), app, user.Username)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		formData := toProfileFormData(user.Username, profile)
+		formData.Saved = true
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.PROFILE_PAGE,
+			HTMLData: formData,
+			JSONData: gin.H{"profile": profile},
+		})
+	}
+}
+
+func GetAvatarHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		username := strings.ToLower(context.Param(utils.USERNAME))
+
+		data, contentType, modifiedAt, err := userservice.GetAvatar(context.Request.Context(), app, username)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Avatar URLs carry a version, so browsers may keep them for a long time
+		etag := utils.ComputeETag(data)
+
+		context.Header("ETag", etag)
+		context.Header("Cache-Control", "public, max-age=86400")
+		context.Header("X-Content-Type-Options", "nosniff")
+
+		if !modifiedAt.IsZero() {
+			context.Header("Last-Modified", modifiedAt.UTC().Format(http.TimeFormat))
+		}
+
+		if utils.IsNotModified(context, etag, modifiedAt) {
+			context.Status(http.StatusNotModified)
+			return
+		}
+
+		context.Data(http.StatusOK, contentType, data)
+	}
+}
+
+func GetImportPageHandler(context *gin.Context) {
+	// Get user info from the context (set in middleware)
+	user := userservice.GetUserFromContext(context)
+
+	context.HTML(http.StatusOK, utils.IMPORT_PAGE, types.ImportPageData{
+		Username: utils.CapitalizeFirstLetter(user.Username),
+	})
+}
+
+func PostImportHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		allowLongRequest(context)
+
+		// Cap the upload before reading any of it
+		context.Request.Body = http.MaxBytesReader(context.Writer, context.Request.Body, utils.IMPORT_MAX_UPLOAD_BYTES+1<<20)
+
+		file, header, err := context.Request.FormFile("file")
+
+		if err != nil {
+			utils.SendErrorResponse(context, http.StatusBadRequest, fmt.Sprintf("Please choose a file of at most %d MB to import.", utils.IMPORT_MAX_UPLOAD_BYTES>>20))
+			return
+		}
+
+		defer file.Close()
+
+		data, err := io.ReadAll(io.LimitReader(file, utils.IMPORT_MAX_UPLOAD_BYTES+1))
+
+		if err != nil || len(data) > utils.IMPORT_MAX_UPLOAD_BYTES {
+			utils.SendErrorResponse(context, http.StatusRequestEntityTooLarge, fmt.Sprintf("Import files may be at most %d MB.", utils.IMPORT_MAX_UPLOAD_BYTES>>20))
+			return
+		}
+
+		// Posts that don't say otherwise get the visibility chosen in the form
+		defaultVisibility := context.DefaultPostForm("visibility", utils.VISIBILITY_PUBLIC)
+
+		if !blogservice.IsValidVisibility(defaultVisibility) {
+			utils.SendErrorResponse(context, http.StatusBadRequest, "Invalid visibility.")
+			return
+		}
+
+		items, err := archiveservice.ParseImportFile(header.Filename, data, defaultVisibility)
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Import every post, collecting an outcome for each one
+		pageData := types.ImportPageData{
+			Username: utils.CapitalizeFirstLetter(user.Username),
+			Results:  archiveservice.ImportPosts(context.Request.Context(), app, user.ID, items),
+		}
+
+		for _, result := range pageData.Results {
+			if result.Error != "" {
+				pageData.Failed++
+			} else {
+				pageData.Imported++
+			}
+		}
+
+		context.Negotiate(http.StatusOK, gin.Negotiate{
+			Offered:  utils.OFFERED_FORMATS,
+			HTMLName: utils.IMPORT_PAGE,
+			HTMLData: pageData,
+			JSONData: gin.H{
+				"imported": pageData.Imported,
+				"failed":   pageData.Failed,
+				"results":  pageData.Results,
+			},
+		})
+	}
+}
+
+func GetDeleteAccountPageHandler(context *gin.Context) {
+	// Get user info from the context (set in middleware)
+	user := userservice.GetUserFromContext(context)
+
+	context.HTML(http.StatusOK, utils.DELETE_ACCOUNT_PAGE, types.DeleteAccountPageData{
+		Username: utils.CapitalizeFirstLetter(user.Username),
+	})
+}
+
+func PostDeleteAccountHandler(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Get user info from context
+		user := userservice.GetUserFromContext(context)
+
+		// Schedule the deletion once the password has been confirmed
+		deletionDate, err := userservice.RequestAccountDeletion(context.Request.Context(), app, user.ID, user.Username, context.PostForm(utils.PASSWORD))
+
+		if err != nil {
+			utils.SendServiceError(context, err)
+			return
+		}
+
+		// End this session, logging in again is what cancels the deletion
+		if err := userservice.LogoutUserSession(context, app); err != nil {
+			app.Logger.ErrorContext(context.Request.Context(), "Failed to log out user after deletion request", "user_id", user.ID, "error", err)
+		}
+
+		context.HTML(http.StatusOK, utils.DELETE_ACCOUNT_PAGE, types.DeleteAccountPageData{
+			Username:     utils.CapitalizeFirstLetter(user.Username),
+			Scheduled:    true,
+			DeletionDate: deletionDate.Format("January 2, 2006"),
+		})
+	}
+}
+
+func allowLongRequest(context *gin.Context) {
+	// Imports & exports can outlast the server's usual read & write timeouts
+	deadline := time.Now().Add(utils.DB_BATCH_TIMEOUT_SEC * time.Second)
+	controller := http.NewResponseController(context.Writer)
+
+	controller.SetReadDeadline(deadline)
+	controller.SetWriteDeadline(deadline)
+}
+
+func parseCollectionID(raw string) (int, error) {
+	if raw == "" {
+		return 0, nil
+	}
+
+	id, err := strconv.Atoi(raw)
+
+	if err != nil || id < 0 {
+		return 0, utils.Validation("invalid collection")
+	}
+
+	return id, nil
+}
+
+func toBlogPreviews(posts []*types.BlogPostData) []types.BlogPreview {
+	previews := make([]types.BlogPreview, len(posts))
+
+	for i, p := range posts {
+		previews[i] = types.BlogPreview{
+			ID:        p.ID,
+			Title:     p.Title,
+			Content:   p.Content,
+			CreatedAt: p.CreatedAt,
+			Tags:      p.Tags,
+		}
+	}
+
+	return previews
+}
+
+func toProfileFormData(username string, profile *types.UserProfile) types.ProfileFormData {
+	formData := types.ProfileFormData{
+		Username:  utils.CapitalizeFirstLetter(username),
+		Bio:       profile.Bio,
+		Links:     strings.Join(profile.Links, "\n"),
+		AvatarURL: profile.AvatarURL,
+		IsPrivate: profile.IsPrivate,
+	}
+
+	// Leave the field empty when the name only falls back to the username
+	if profile.DisplayName != utils.CapitalizeFirstLetter(profile.Username) {
+		formData.DisplayName = profile.DisplayName
+	}
+
+	return formData
+}
+
+func toFeedPreviews(posts []*types.HomeFeedData) []types.FeedPreview {
+	previews := make([]types.FeedPreview, len(posts))
+
+	for i, p := range posts {
+		previews[i] = toFeedPreview(p)
+	}
+
+	return previews
+}
+
+func toFeedPreview(post *types.HomeFeedData) types.FeedPreview {
+	return types.FeedPreview{
+		ID:          post.ID,
+		Title:       post.Title,
+		Content:     post.Content,
+		Username:    post.Username,
+		DisplayName: post.DisplayName,
+		CreatedAt:   post.CreatedAt,
+		Tags:        post.Tags,
 	}
 }
@@ -1,22 +1,44 @@
 package api
 
 import (
-	"App/internal/cache"
+	"App/internal/logging"
+	"App/internal/tracing"
 	"App/internal/types"
 	"App/internal/userservice"
 	"App/internal/utils"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/didip/tollbooth"
 	"github.com/didip/tollbooth/limiter"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
 )
 
-var blockedIPs = make(map[string]time.Time)        // In-memory blocklist
-var ipLimiters = make(map[string]*limiter.Limiter) // rate limiter store per IP
+var contentSecurityPolicy = strings.Join([]string{
+	"default-src 'self'",
+	"script-src 'self' 'nonce-%s' https://cdn.jsdelivr.net https://use.fontawesome.com",
+	// Templates still use style attributes, so inline styles stay allowed
+	"style-src 'self' 'unsafe-inline' https://cdn.jsdelivr.net https://cdnjs.cloudflare.com https://fonts.googleapis.com",
+	"font-src 'self' data: https://cdn.jsdelivr.net https://cdnjs.cloudflare.com https://fonts.gstatic.com",
+	"img-src 'self' data: blob:",
+	"connect-src 'self'",
+	"object-src 'none'",
+	"base-uri 'self'",
+	"form-action 'self'",
+	"frame-ancestors 'none'",
+	"report-uri " + utils.CSP_REPORT_PATH,
+	"report-to csp",
+}, "; ")
 
 func RequireAuth(app *types.App) gin.HandlerFunc {
 	return func(context *gin.Context) {
@@ -24,16 +46,16 @@ func RequireAuth(app *types.App) gin.HandlerFunc {
 		user, err := authenticateUser(app, context)
 
 		if err != nil {
-			userservice.HandleAuthenticationError(context, err)
+			userservice.HandleAuthenticationError(context, app, err)
 			return
 		}
 
 		// Check if the user key exists in the cache
-		if validKey := cache.HasUserKey(user.ID); !validKey {
+		if validKey := app.KeyCache.Has(user.ID); !validKey {
 			// User key not found in cache, log out the user session
-			userservice.LogoutUserSession(context, app.SessionStore)
+			userservice.LogoutUserSession(context, app)
 
-			userservice.HandleAuthenticationError(context, fmt.Errorf("user key not found in cache for user ID: %d", user.ID))
+			userservice.HandleAuthenticationError(context, app, fmt.Errorf("user key not found in cache for user ID: %d", user.ID))
 			return
 		}
 
@@ -50,10 +72,10 @@ func OptionalAuth(app *types.App) gin.HandlerFunc {
 		// Attempt to authenticate user
 		if user, err := authenticateUser(app, context); err == nil {
 			// Check if the user key exists in the cache
-			if validKey := cache.HasUserKey(user.ID); !validKey {
+			if validKey := app.KeyCache.Has(user.ID); !validKey {
 				// User key not found in cache, log out the user session
-				if err := userservice.LogoutUserSession(context, app.SessionStore); err == nil {
-					log.Printf("OptionalAuth: encryption key missing for user %d; session logged out", user.ID)
+				if err := userservice.LogoutUserSession(context, app); err == nil {
+					app.Logger.InfoContext(context.Request.Context(), "Encryption key missing, session logged out", "user_id", user.ID)
 				}
 
 			} else {
@@ -79,64 +101,284 @@ func authenticateUser(app *types.App, context *gin.Context) (types.User, error)
 	user, ok := session.Values[utils.USER].(types.User)
 
 	if !ok || !userservice.IsValidUser(user) {
-		return types.User{}, fmt.Errorf("error validating user, username: %s", user.Username)
+		return types.User{}, fmt.Errorf("error validating session user")
 	}
 
 	// Validate user in the database
-	if !userservice.CheckUserExists(user, app.Database) {
+	if !userservice.CheckUserExists(context.Request.Context(), user, app) {
 		return types.User{}, fmt.Errorf("error validating user when checking session stored user")
 	}
 
 	return user, nil
 }
 
-func BlockSuspiciousIPsAndRateLimit(c *gin.Context) {
-	// Grab client IP
-	ip := c.ClientIP()
+func RequestID() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		// Keep the ID a proxy in front of us assigned, otherwise start a new one
+		requestID := context.GetHeader(utils.REQUEST_ID_HEADER)
+
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+
+		// Hand the ID to the services through the request context & echo it back to the client
+		context.Request = context.Request.WithContext(logging.WithRequestID(context.Request.Context(), requestID))
+		context.Header(utils.REQUEST_ID_HEADER, requestID)
+
+		context.Next()
+	}
+}
+
+func RequestLogger(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+
+		context.Next()
+
+		status := context.Writer.Status()
+
+		level := slog.LevelInfo
+
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+
+		attrs := []slog.Attr{
+			slog.String("method", context.Request.Method),
+			slog.String("route", routeName(context)),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(context.Writer.Size(), 0)),
+			slog.String("client_ip", context.ClientIP()),
+		}
+
+		// Server errors carry the cause, client errors only at debug level because their messages can name users
+		if err := context.Errors.Last(); err != nil {
+			if status >= http.StatusInternalServerError {
+				attrs = append(attrs, slog.String("error", err.Error()))
+			} else {
+				app.Logger.DebugContext(context.Request.Context(), "Request rejected", "status", status, "error", err.Err)
+			}
+		}
+
+		app.Logger.LogAttrs(context.Request.Context(), level, "Request handled", attrs...)
+	}
+}
+
+func TraceRequests() gin.HandlerFunc {
+	return func(context *gin.Context) {
+		ctx, span := tracing.StartRequest(context.Request, routeName(context))
+		defer span.End()
+
+		span.SetAttributes(attribute.String(utils.REQUEST_ID_ATTR, logging.RequestID(ctx)))
+
+		// Services pick the span up from the request context & hang their own spans off it
+		context.Request = context.Request.WithContext(ctx)
+
+		context.Next()
+
+		status := context.Writer.Status()
+		span.SetAttributes(semconv.HTTPResponseStatusCode(status))
+
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+	}
+}
+
+func RecordMetrics(app *types.App) gin.HandlerFunc {
+	return func(context *gin.Context) {
+		start := time.Now()
+
+		context.Next()
+
+		app.Metrics.ObserveRequest(context.Request.Method, routeName(context), context.Writer.Status(), time.Since(start))
+	}
+}
+
+func routeName(context *gin.Context) string {
+	// Use the route pattern rather than the raw path, which can carry usernames & share tokens
+	route := context.FullPath()
+
+	if route == "" {
+		return "unmatched"
+	}
+
+	return route
+}
+
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > utils.REQUEST_ID_MAX_LENGTH {
+		return false
+	}
+
+	// Only allow characters that are safe to put in headers & log lines
+	for _, r := range requestID {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return false
+		}
+	}
 
-	// Check if the IP is currently blocked
-	if blockTime, blocked := blockedIPs[ip]; blocked {
-		// Check if the block has expired
-		if time.Now().Before(blockTime) {
-			// Block the IP from further processing
-			c.JSON(403, gin.H{"error": "Access denied. Your IP is blocked."})
+	return true
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 16)
+
+	// crypto/rand never fails on supported platforms
+	rand.Read(bytes)
+
+	return hex.EncodeToString(bytes)
+}
+
+type RateLimiter struct {
+	mutex      sync.Mutex                  // Guards blockedIPs & ipLimiters across requests
+	blockedIPs map[string]time.Time        // In-memory blocklist
+	ipLimiters map[string]*limiter.Limiter // rate limiter store per IP
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{blockedIPs: make(map[string]time.Time), ipLimiters: make(map[string]*limiter.Limiter)}
+}
+
+func BlockSuspiciousIPsAndRateLimit(app *types.App, rateLimiter *RateLimiter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Grab client IP
+		ip := c.ClientIP()
+
+		rateLimiter.mutex.Lock()
+
+		// Check if the IP is currently blocked
+		if blockTime, blocked := rateLimiter.blockedIPs[ip]; blocked {
+			// Check if the block has expired
+			if time.Now().Before(blockTime) {
+				rateLimiter.mutex.Unlock()
+
+				// Block the IP from further processing
+				c.JSON(403, gin.H{"error": "Access denied. Your IP is blocked."})
+				c.Abort()
+				return
+			} else {
+				// If Block expired remove out of blocked list
+				delete(rateLimiter.blockedIPs, ip)
+			}
+		}
+
+		// Retrieve or create a limiter for this IP
+		lim, exists := rateLimiter.ipLimiters[ip]
+
+		// If a limiter doesn't exist create one and set to Client IP
+		if !exists {
+			// Create a new limiter set for a minute for designated number of requests
+			lim = tollbooth.NewLimiter(utils.REQUEST_LIMIT, &limiter.ExpirableOptions{
+				DefaultExpirationTTL: time.Minute,
+			})
+
+			// Store the limiter for future requests
+			rateLimiter.ipLimiters[ip] = lim
+		}
+
+		rateLimiter.mutex.Unlock()
+
+		// Check rate limit for this IP
+		if httpError := tollbooth.LimitByRequest(lim, c.Writer, c.Request); httpError != nil {
+			// Log and block the IP if rate limit exceeded
+			app.Logger.WarnContext(c.Request.Context(), "Suspicious activity detected (rate limit exceeded)", "client_ip", ip)
+
+			// Add to in-memory block list with expiration time
+			rateLimiter.mutex.Lock()
+			rateLimiter.blockedIPs[ip] = time.Now().Add(utils.EXPIRATION_TIME * time.Hour)
+			rateLimiter.mutex.Unlock()
+
+			app.Metrics.RateLimitBlocked()
+
+			c.JSON(httpError.StatusCode, gin.H{"error": "Access denied. Rate limit exceeded."})
 			c.Abort()
 			return
-		} else {
-			// If Block expired remove out of blocked list
-			delete(blockedIPs, ip)
 		}
+
+		// Proceed with the request if within rate limits
+		c.Next()
 	}
+}
 
-	// Retrieve or create a limiter for this IP
-	lim, exists := ipLimiters[ip]
+func (l *RateLimiter) BlockedIPCount() int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
 
-	// If a limiter doesn't exist create one and set to Client IP
-	if !exists {
-		// Create a new limiter set for a minute for designated number of requests
-		lim = tollbooth.NewLimiter(utils.REQUEST_LIMIT, &limiter.ExpirableOptions{
-			DefaultExpirationTTL: time.Minute,
-		})
+	// Expired blocks are only removed when the IP comes back, so skip them here
+	count := 0
+	now := time.Now()
 
-		// Store the limiter for future requests
-		ipLimiters[ip] = lim
+	for _, blockTime := range l.blockedIPs {
+		if now.Before(blockTime) {
+			count++
+		}
 	}
 
-	// Check rate limit for this IP
-	if httpError := tollbooth.LimitByRequest(lim, c.Writer, c.Request); httpError != nil {
-		// Log and block the IP if rate limit exceeded
-		log.Printf("Suspicious activity detected from IP: %s (rate limit exceeded)", ip)
+	return count
+}
 
-		// Add to in-memory block list with expiration time
-		blockedIPs[ip] = time.Now().Add(utils.EXPIRATION_TIME * time.Hour)
+func SecurityHeaders(reportOnly bool) gin.HandlerFunc {
+	cspHeader := "Content-Security-Policy"
 
-		c.JSON(httpError.StatusCode, gin.H{"error": "Access denied. Rate limit exceeded."})
-		c.Abort()
-		return
+	// Report-only mode lets violations be collected before the policy starts blocking anything
+	if reportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
 	}
 
-	// Proceed with the request if within rate limits
-	c.Next()
+	return func(context *gin.Context) {
+		nonce := newNonce()
+
+		context.Header(cspHeader, fmt.Sprintf(contentSecurityPolicy, nonce))
+		context.Header("Reporting-Endpoints", fmt.Sprintf("csp=%q", utils.CSP_REPORT_PATH))
+		context.Header("X-Content-Type-Options", "nosniff")
+		context.Header("X-Frame-Options", "DENY")
+		context.Header("Referrer-Policy", "strict-origin-when-cross-origin")
+		context.Header("Permissions-Policy", "camera=(), microphone=(), geolocation=(), payment=(), usb=()")
+		context.Header("Cross-Origin-Opener-Policy", "same-origin")
+
+		// The HTML renderer reads the nonce back from the writer to fill in the script tags
+		context.Writer = &nonceWriter{ResponseWriter: context.Writer, nonce: nonce}
+
+		context.Next()
+	}
+}
+
+type nonceWriter struct {
+	gin.ResponseWriter
+	nonce string
+}
+
+func (w *nonceWriter) Unwrap() http.ResponseWriter {
+	// Lets http.ResponseController reach the connection, e.g. to extend deadlines
+	return w.ResponseWriter
+}
+
+func newNonce() string {
+	bytes := make([]byte, 16)
+
+	// crypto/rand never fails on supported platforms
+	rand.Read(bytes)
+
+	// URL-safe characters pass through html/template's attribute escaping unchanged
+	return base64.RawURLEncoding.EncodeToString(bytes)
+}
+
+func StrictTransportSecurity(maxAge int) gin.HandlerFunc {
+	value := fmt.Sprintf("max-age=%d; includeSubDomains", maxAge)
+
+	return func(context *gin.Context) {
+		// Browsers ignore the header on plain HTTP, so only send it over TLS
+		if context.Request.TLS != nil {
+			context.Header("Strict-Transport-Security", value)
+		}
+
+		context.Next()
+	}
 }
 
 func CORSMiddleware(allowedOrigins []string) gin.HandlerFunc {
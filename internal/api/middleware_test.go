@@ -0,0 +1,83 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"App/internal/tracing"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTraceRequests(t *testing.T) {
+	exporter := tracetest.NewInMemoryExporter()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter))
+	defer provider.Shutdown(context.Background())
+
+	otel.SetTracerProvider(provider)
+
+	gin.SetMode(gin.TestMode)
+
+	router := gin.New()
+	router.Use(RequestID(), TraceRequests())
+
+	router.GET("/blogpost/:ID", func(context *gin.Context) {
+		// Stand in for a service call made with the request context
+		_, span := tracing.Start(context.Request.Context(), "blogservice.GetBlogPostData")
+		span.End()
+
+		context.Status(http.StatusOK)
+	})
+
+	router.GET("/broken", func(context *gin.Context) {
+		context.Status(http.StatusInternalServerError)
+	})
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/blogpost/42", nil))
+
+	spans := exporter.GetSpans()
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the service span & the request span", len(spans))
+	}
+
+	service, request := spans[0], spans[1]
+
+	if request.Name != "GET /blogpost/:ID" {
+		t.Errorf("request span name: got %q, want the route pattern", request.Name)
+	}
+
+	if service.Parent.SpanID() != request.SpanContext.SpanID() {
+		t.Errorf("service span is not a child of the request span")
+	}
+
+	if !hasIntAttribute(request, "http.response.status_code", http.StatusOK) {
+		t.Errorf("request span is missing its status code: %v", request.Attributes)
+	}
+
+	exporter.Reset()
+
+	router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/broken", nil))
+
+	spans = exporter.GetSpans()
+
+	if len(spans) != 1 || spans[0].Status.Code != codes.Error {
+		t.Fatalf("server errors should mark the request span as failed, got %+v", spans)
+	}
+}
+
+func hasIntAttribute(span tracetest.SpanStub, key string, value int) bool {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key && attr.Value.AsInt64() == int64(value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,64 @@
+package api
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+
+	"github.com/gin-gonic/gin/render"
+)
+
+func CSPNonce() string {
+	// Only lets the templates parse, every render swaps in a func that returns its request's nonce
+	return ""
+}
+
+type HTMLRender struct {
+	Template *template.Template
+}
+
+func (r HTMLRender) Instance(name string, data any) render.Render {
+	return nonceHTML{template: r.Template, name: name, data: data}
+}
+
+type nonceHTML struct {
+	template *template.Template
+	name     string
+	data     any
+}
+
+func (h nonceHTML) Render(w http.ResponseWriter) error {
+	h.WriteContentType(w)
+
+	// Pages rendered outside SecurityHeaders get no nonce, so their scripts only load by host
+	nonce := ""
+
+	if writer, ok := w.(*nonceWriter); ok {
+		nonce = writer.nonce
+	}
+
+	// The shared templates are never executed, so each render gets a copy whose cspNonce is this request's
+	tmpl, err := h.template.Clone()
+
+	if err != nil {
+		return err
+	}
+
+	tmpl.Funcs(template.FuncMap{"cspNonce": func() string { return nonce }})
+
+	// Render the whole page first so a template error never leaves half a page behind
+	var page bytes.Buffer
+
+	if err := tmpl.ExecuteTemplate(&page, h.name, h.data); err != nil {
+		return err
+	}
+
+	_, err = w.Write(page.Bytes())
+	return err
+}
+
+func (h nonceHTML) WriteContentType(w http.ResponseWriter) {
+	if header := w.Header(); header.Get("Content-Type") == "" {
+		header.Set("Content-Type", "text/html; charset=utf-8")
+	}
+}
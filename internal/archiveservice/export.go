@@ -0,0 +1,321 @@
+package archiveservice
+
+import (
+	"App/internal/blogservice"
+	"App/internal/types"
+	"App/internal/utils"
+	"archive/zip"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+func WriteUserArchive(ctx context.Context, app *types.App, w io.Writer, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Look up the account being exported
+	var userID int
+	var createdAt []byte
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectUserProfileForExportQuery, username).Scan(&userID, &username, &createdAt); err == sql.ErrNoRows {
+		return utils.NotFound("user not found")
+	} else if err != nil {
+		app.Logger.ErrorContext(ctx, "Error fetching user for export", "username", username, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to fetch user for export")
+	}
+
+	// Entries are compressed & flushed one at a time so the archive is never held in memory
+	archive := zip.NewWriter(w)
+
+	postCount, err := writePosts(ctx, app, archive, userID)
+
+	if err != nil {
+		return err
+	}
+
+	profile := types.ExportProfile{
+		Username:   username,
+		CreatedAt:  formatExportDate(createdAt),
+		ExportedAt: app.Clock.Now().UTC().Format(time.RFC3339),
+		PostCount:  postCount,
+	}
+
+	if err := writeJSONFile(archive, "profile.json", profile); err != nil {
+		return err
+	}
+
+	if err := writeComments(ctx, app, archive, userID); err != nil {
+		return err
+	}
+
+	if err := writeLikes(ctx, app, archive, userID); err != nil {
+		return err
+	}
+
+	if err := writeFollows(ctx, app, archive, "followers.json", utils.SelectFollowersForExportQuery, userID); err != nil {
+		return err
+	}
+
+	if err := writeFollows(ctx, app, archive, "following.json", utils.SelectFollowingForExportQuery, userID); err != nil {
+		return err
+	}
+
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("error finishing archive: %w", err)
+	}
+
+	return nil
+}
+
+func writePosts(ctx context.Context, app *types.App, archive *zip.Writer, userID int) (int, error) {
+	// Public tags live in their own table, load them all up front
+	publicTags, err := getPublicTagsByUser(ctx, app, userID)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Without the owner's key private posts can only be exported as ciphertext
+	canDecrypt := app.KeyCache.Has(userID)
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPostsForExportQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying posts for export", "user_id", userID, "error", err)
+		return 0, utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	defer rows.Close()
+
+	count := 0
+
+	for rows.Next() {
+		post := &types.ExportPost{}
+		var createdAt []byte
+		var encryptedTags sql.NullString
+
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.Visibility, &encryptedTags); err != nil {
+			return 0, fmt.Errorf("error scanning post: %w", err)
+		}
+
+		post.CreatedAt = formatExportDate(createdAt)
+
+		switch {
+		case !blogservice.IsEncryptedVisibility(post.Visibility):
+			post.Tags = publicTags[post.ID]
+		case canDecrypt:
+			if post.Title, post.Content, err = blogservice.DecryptBlogPost(app, post.Title, post.Content, userID, post.Visibility); err != nil {
+				return 0, fmt.Errorf("encryption error: failed to decrypt blog post %d", post.ID)
+			}
+
+			if post.Tags, err = blogservice.DecryptTags(app, encryptedTags, userID); err != nil {
+				return 0, fmt.Errorf("encryption error: failed to decrypt tags for blog post %d", post.ID)
+			}
+		default:
+			post.Encrypted = true
+			post.EncryptedTags = encryptedTags.String
+		}
+
+		// Date the entry like the post so archive tools show when it was written
+		entry, err := archive.CreateHeader(&zip.FileHeader{
+			Name:     PostFileName(post),
+			Method:   zip.Deflate,
+			Modified: blogservice.ParseDate(createdAt),
+		})
+
+		if err != nil {
+			return 0, fmt.Errorf("error adding post %d to archive: %w", post.ID, err)
+		}
+
+		if _, err := io.WriteString(entry, FormatPostMarkdown(post)); err != nil {
+			return 0, fmt.Errorf("error writing post %d to archive: %w", post.ID, err)
+		}
+
+		count++
+	}
+
+	if err := rows.Err(); err != nil {
+		return 0, fmt.Errorf("error iterating posts: %w", err)
+	}
+
+	return count, nil
+}
+
+func getPublicTagsByUser(ctx context.Context, app *types.App, userID int) (map[int][]string, error) {
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPublicTagsByUserQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying tags for export", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to retrieve tags")
+	}
+
+	defer rows.Close()
+
+	tags := make(map[int][]string)
+
+	for rows.Next() {
+		var postID int
+		var tag string
+
+		if err := rows.Scan(&postID, &tag); err != nil {
+			return nil, fmt.Errorf("error scanning tags")
+		}
+
+		tags[postID] = append(tags[postID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating tags")
+	}
+
+	return tags, nil
+}
+
+func writeComments(ctx context.Context, app *types.App, archive *zip.Writer, userID int) error {
+	rows, err := app.Database.QueryContext(ctx, utils.SelectCommentsByUserQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying comments for export", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to retrieve comments")
+	}
+
+	return writeJSONArray(archive, "comments.json", rows, func(rows *sql.Rows) (any, error) {
+		comment := types.ExportComment{}
+		var createdAt []byte
+
+		if err := rows.Scan(&comment.ID, &comment.PostID, &comment.Comment, &createdAt); err != nil {
+			return nil, err
+		}
+
+		comment.CreatedAt = formatExportDate(createdAt)
+		return comment, nil
+	})
+}
+
+func writeLikes(ctx context.Context, app *types.App, archive *zip.Writer, userID int) error {
+	rows, err := app.Database.QueryContext(ctx, utils.SelectLikesByUserQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying likes for export", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to retrieve likes")
+	}
+
+	return writeJSONArray(archive, "likes.json", rows, func(rows *sql.Rows) (any, error) {
+		like := types.ExportLike{}
+		var createdAt []byte
+
+		if err := rows.Scan(&like.PostID, &like.PostAuthor, &createdAt); err != nil {
+			return nil, err
+		}
+
+		like.CreatedAt = formatExportDate(createdAt)
+		return like, nil
+	})
+}
+
+func writeFollows(ctx context.Context, app *types.App, archive *zip.Writer, name string, query string, userID int) error {
+	rows, err := app.Database.QueryContext(ctx, query, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying for export", "list", name, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to retrieve follows")
+	}
+
+	return writeJSONArray(archive, name, rows, func(rows *sql.Rows) (any, error) {
+		follow := types.ExportFollow{}
+		var createdAt []byte
+
+		if err := rows.Scan(&follow.Username, &createdAt); err != nil {
+			return nil, err
+		}
+
+		follow.CreatedAt = formatExportDate(createdAt)
+		return follow, nil
+	})
+}
+
+func writeJSONArray(archive *zip.Writer, name string, rows *sql.Rows, scan func(*sql.Rows) (any, error)) error {
+	defer rows.Close()
+
+	entry, err := archive.Create(name)
+
+	if err != nil {
+		return fmt.Errorf("error adding %s to archive: %w", name, err)
+	}
+
+	// Write one element at a time instead of building the whole slice
+	if _, err := io.WriteString(entry, "["); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+
+	separator := "\n  "
+
+	for rows.Next() {
+		item, err := scan(rows)
+
+		if err != nil {
+			return fmt.Errorf("error scanning %s: %w", name, err)
+		}
+
+		encoded, err := json.MarshalIndent(item, "  ", "  ")
+
+		if err != nil {
+			return fmt.Errorf("error encoding %s: %w", name, err)
+		}
+
+		if _, err := io.WriteString(entry, separator+string(encoded)); err != nil {
+			return fmt.Errorf("error writing %s: %w", name, err)
+		}
+
+		separator = ",\n  "
+	}
+
+	if err := rows.Err(); err != nil {
+		return fmt.Errorf("error iterating %s: %w", name, err)
+	}
+
+	// Empty arrays stay on one line
+	closing := "\n]\n"
+
+	if separator == "\n  " {
+		closing = "]\n"
+	}
+
+	if _, err := io.WriteString(entry, closing); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func writeJSONFile(archive *zip.Writer, name string, value any) error {
+	entry, err := archive.Create(name)
+
+	if err != nil {
+		return fmt.Errorf("error adding %s to archive: %w", name, err)
+	}
+
+	encoder := json.NewEncoder(entry)
+	encoder.SetIndent("", "  ")
+
+	if err := encoder.Encode(value); err != nil {
+		return fmt.Errorf("error writing %s: %w", name, err)
+	}
+
+	return nil
+}
+
+func formatExportDate(createdAt []byte) string {
+	// Archives use unambiguous UTC timestamps rather than the site's display format
+	date := blogservice.ParseDate(createdAt)
+
+	if date.IsZero() {
+		return ""
+	}
+
+	return date.UTC().Format(time.RFC3339)
+}
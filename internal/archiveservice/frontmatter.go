@@ -0,0 +1,87 @@
+package archiveservice
+
+import (
+	"App/internal/types"
+	"App/internal/utils"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var slugPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func FormatPostMarkdown(post *types.ExportPost) string {
+	var builder strings.Builder
+
+	// Front matter values are written as JSON strings, which YAML parses as-is
+	builder.WriteString("---\n")
+	fmt.Fprintf(&builder, "title: %s\n", quote(post.Title))
+	fmt.Fprintf(&builder, "date: %s\n", post.CreatedAt)
+	fmt.Fprintf(&builder, "visibility: %s\n", post.Visibility)
+
+	if len(post.Tags) > 0 {
+		quotedTags := make([]string, len(post.Tags))
+
+		for i, tag := range post.Tags {
+			quotedTags[i] = quote(tag)
+		}
+
+		fmt.Fprintf(&builder, "tags: [%s]\n", strings.Join(quotedTags, ", "))
+	}
+
+	// Private posts that couldn't be decrypted keep their ciphertext & say so
+	if post.Encrypted {
+		builder.WriteString("encrypted: true\n")
+
+		if post.EncryptedTags != "" {
+			fmt.Fprintf(&builder, "encrypted_tags: %s\n", quote(post.EncryptedTags))
+		}
+	}
+
+	builder.WriteString("---\n\n")
+	builder.WriteString(post.Content)
+
+	if !strings.HasSuffix(post.Content, "\n") {
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+func PostFileName(post *types.ExportPost) string {
+	// Encrypted titles make meaningless slugs, so fall back to the ID alone
+	slug := "post"
+
+	if !post.Encrypted {
+		if titleSlug := Slugify(post.Title); titleSlug != "" {
+			slug = titleSlug
+		}
+	}
+
+	return fmt.Sprintf("posts/%05d-%s.md", post.ID, slug)
+}
+
+func Slugify(title string) string {
+	slug := strings.Trim(slugPattern.ReplaceAllString(strings.ToLower(title), "-"), "-")
+
+	// Keep file names short enough for every file system
+	if len(slug) > 60 {
+		slug = strings.TrimRight(slug[:60], "-")
+	}
+
+	return slug
+}
+
+func quote(value string) string {
+	encoded, _ := json.Marshal(value)
+	return string(encoded)
+}
+
+func visibilityFor(isPublic bool, defaultVisibility string) string {
+	if isPublic {
+		return defaultVisibility
+	}
+
+	return utils.VISIBILITY_PRIVATE
+}
@@ -0,0 +1,302 @@
+package archiveservice
+
+import (
+	"App/internal/blogservice"
+	"App/internal/types"
+	"App/internal/utils"
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// Site scaffolding that never holds posts
+var ignoredImportDirs = map[string]bool{
+	"node_modules": true,
+	"themes":       true,
+	"layouts":      true,
+	"static":       true,
+	"public":       true,
+	"resources":    true,
+}
+
+func ParseImportFile(name string, data []byte, defaultVisibility string) ([]*types.ImportItem, error) {
+	// Pick the parser from the file extension
+	switch strings.ToLower(path.Ext(name)) {
+	case ".md", ".markdown":
+		return []*types.ImportItem{ParseMarkdownPost(name, data, defaultVisibility)}, nil
+	case ".xml":
+		if !IsWXR(data) {
+			return nil, utils.Validation("%s is not a WordPress export file", name)
+		}
+
+		return ParseWXR(name, data, defaultVisibility)
+	case ".zip":
+		archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+
+		if err != nil {
+			return nil, utils.Validation("invalid ZIP file %s: %v", name, err)
+		}
+
+		return parseImportFS(archive, defaultVisibility)
+	default:
+		return nil, utils.Validation("unsupported file %s: upload a .md, .xml or .zip file", name)
+	}
+}
+
+func ParseImportPath(root string, defaultVisibility string) ([]*types.ImportItem, error) {
+	info, err := os.Stat(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Folders are walked like an uploaded ZIP of a static site
+	if info.IsDir() {
+		return parseImportFS(os.DirFS(root), defaultVisibility)
+	}
+
+	if info.Size() > utils.IMPORT_MAX_UPLOAD_BYTES {
+		return nil, fmt.Errorf("%s is larger than %d MB", root, utils.IMPORT_MAX_UPLOAD_BYTES>>20)
+	}
+
+	data, err := os.ReadFile(root)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return ParseImportFile(path.Base(root), data, defaultVisibility)
+}
+
+func parseImportFS(fsys fs.FS, defaultVisibility string) ([]*types.ImportItem, error) {
+	var items []*types.ImportItem
+
+	err := fs.WalkDir(fsys, ".", func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		base := entry.Name()
+
+		// Skip hidden folders & anything Jekyll or Hugo use for layout rather than posts
+		if entry.IsDir() {
+			isScaffolding := strings.HasPrefix(base, "_") && base != "_posts" && base != "_drafts"
+
+			if name != "." && (strings.HasPrefix(base, ".") || isScaffolding || ignoredImportDirs[base]) {
+				return fs.SkipDir
+			}
+
+			return nil
+		}
+
+		// Hugo list pages & repository docs aren't posts either
+		if strings.HasPrefix(base, "_") || strings.HasPrefix(base, ".") || strings.EqualFold(base, "README.md") {
+			return nil
+		}
+
+		extension := strings.ToLower(path.Ext(base))
+
+		if extension != ".md" && extension != ".markdown" && extension != ".xml" {
+			return nil
+		}
+
+		data, err := readImportFile(fsys, name)
+
+		if err != nil {
+			items = append(items, &types.ImportItem{Source: name, Err: err})
+			return nil
+		}
+
+		switch extension {
+		case ".xml":
+			// Sitemaps & other feeds can sit next to the posts
+			if !IsWXR(data) {
+				return nil
+			}
+
+			wxrItems, err := ParseWXR(name, data, defaultVisibility)
+
+			if err != nil {
+				items = append(items, &types.ImportItem{Source: name, Err: err})
+				return nil
+			}
+
+			items = append(items, wxrItems...)
+		default:
+			item := ParseMarkdownPost(name, data, defaultVisibility)
+
+			// Jekyll drafts were never published
+			if strings.HasPrefix(name, "_drafts/") || strings.Contains(name, "/_drafts/") {
+				item.Visibility = utils.VISIBILITY_PRIVATE
+			}
+
+			items = append(items, item)
+		}
+
+		if len(items) > utils.IMPORT_MAX_ITEMS {
+			return utils.Validation("too many posts: at most %d can be imported at once", utils.IMPORT_MAX_ITEMS)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func readImportFile(fsys fs.FS, name string) ([]byte, error) {
+	file, err := fsys.Open(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	// Guard against archives that expand far beyond their upload size
+	data, err := io.ReadAll(io.LimitReader(file, utils.IMPORT_MAX_UPLOAD_BYTES+1))
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) > utils.IMPORT_MAX_UPLOAD_BYTES {
+		return nil, fmt.Errorf("file is larger than %d MB", utils.IMPORT_MAX_UPLOAD_BYTES>>20)
+	}
+
+	return data, nil
+}
+
+func ImportPosts(ctx context.Context, app *types.App, userID int, items []*types.ImportItem) []*types.ImportResult {
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	if len(items) > utils.IMPORT_MAX_ITEMS {
+		items = items[:utils.IMPORT_MAX_ITEMS]
+	}
+
+	// Insert oldest first so post IDs follow the original publishing order
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].CreatedAt.Before(items[j].CreatedAt)
+	})
+
+	results := make([]*types.ImportResult, 0, len(items))
+
+	for _, item := range items {
+		result := &types.ImportResult{Source: item.Source, Title: item.Title}
+		results = append(results, result)
+
+		if err := prepareImportItem(app, item, userID); err != nil {
+			result.Error = err.Error()
+			continue
+		}
+
+		postID, err := blogservice.ImportBlogPostIntoDB(ctx, app, &types.CreateBlogPost{
+			BlogPostBase: types.BlogPostBase{
+				Title:      item.Title,
+				Visibility: item.Visibility,
+				Content:    item.Content,
+				Tags:       item.Tags,
+			},
+			UserID: userID,
+		}, item.CreatedAt)
+
+		// Only the user-facing part of the error ends up in the results
+		if err != nil {
+			_, result.Error = utils.ErrorStatus(err)
+			continue
+		}
+
+		result.PostID = postID
+	}
+
+	return results
+}
+
+func prepareImportItem(app *types.App, item *types.ImportItem, userID int) error {
+	// Report parse failures against the item they came from
+	if item.Err != nil {
+		return item.Err
+	}
+
+	if item.Title == "" {
+		return fmt.Errorf("post has no title")
+	}
+
+	if item.Content == "" {
+		return fmt.Errorf("post has no content")
+	}
+
+	if len(item.Content) > utils.IMPORT_CONTENT_MAX_LENGTH {
+		return fmt.Errorf("post is longer than %d characters", utils.IMPORT_CONTENT_MAX_LENGTH)
+	}
+
+	// Private posts can only be encrypted with the owner's key
+	if blogservice.IsEncryptedVisibility(item.Visibility) && !app.KeyCache.Has(userID) {
+		return fmt.Errorf("private posts can only be imported with the owner's password")
+	}
+
+	// Old titles may run past the editor's limit, trim them rather than fail
+	item.Title = truncateToBytes(item.Title, utils.BLOG_TITLE_MAX_LENGTH)
+	item.Tags = normalizeImportTags(item.Tags)
+
+	if item.CreatedAt.IsZero() {
+		item.CreatedAt = app.Clock.Now().UTC()
+	}
+
+	return nil
+}
+
+func normalizeImportTags(rawTags []string) []string {
+	tags := []string{}
+	seen := make(map[string]bool)
+
+	// Other platforms allow spaces & capitals in tags, fold them into Posto tags
+	for _, rawTag := range rawTags {
+		tag := Slugify(blogservice.NormalizeTag(rawTag))
+
+		if len(tag) > utils.TAG_MAX_LENGTH {
+			tag = strings.TrimRight(tag[:utils.TAG_MAX_LENGTH], "-")
+		}
+
+		if tag == "" || seen[tag] || !blogservice.IsValidTag(tag) {
+			continue
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+
+		if len(tags) == utils.TAG_MAX_PER_POST {
+			break
+		}
+	}
+
+	return tags
+}
+
+func truncateToBytes(value string, maxBytes int) string {
+	if len(value) <= maxBytes {
+		return value
+	}
+
+	// Never cut a multi-byte character in half
+	cut := maxBytes
+
+	for cut > 0 && !utf8.RuneStart(value[cut]) {
+		cut--
+	}
+
+	return strings.TrimSpace(value[:cut])
+}
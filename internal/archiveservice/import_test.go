@@ -0,0 +1,383 @@
+package archiveservice
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"App/internal/types"
+	"App/internal/utils"
+)
+
+func TestParseImportFile(t *testing.T) {
+	markdown := []byte("---\ntitle: Hello\n---\nText\n")
+	wxr := []byte(wxrHeader + `<item><title>From WordPress</title><wp:status>publish</wp:status></item>` + wxrFooter)
+
+	tests := []struct {
+		name              string
+		fileName          string
+		data              []byte
+		defaultVisibility string
+		titles            []string
+		visibility        string
+		wantErr           bool
+	}{
+		{"markdown", "hello.md", markdown, utils.VISIBILITY_PUBLIC, []string{"Hello"}, utils.VISIBILITY_PUBLIC, false},
+		{"markdown extension", "hello.MARKDOWN", markdown, utils.VISIBILITY_FOLLOWERS, []string{"Hello"}, utils.VISIBILITY_FOLLOWERS, false},
+		{"wordpress export", "export.xml", wxr, utils.VISIBILITY_UNLISTED, []string{"From WordPress"}, utils.VISIBILITY_UNLISTED, false},
+		{"zip of a site", "site.zip", zipOf(t, map[string]string{"_posts/2020-01-01-hello.md": string(markdown)}), utils.VISIBILITY_PUBLIC, []string{"Hello"}, utils.VISIBILITY_PUBLIC, false},
+		{"other xml", "sitemap.xml", []byte(`<urlset></urlset>`), utils.VISIBILITY_PUBLIC, nil, "", true},
+		{"broken zip", "site.zip", []byte("not a zip"), utils.VISIBILITY_PUBLIC, nil, "", true},
+		{"unsupported extension", "notes.txt", []byte("Text"), utils.VISIBILITY_PUBLIC, nil, "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := ParseImportFile(test.fileName, test.data, test.defaultVisibility)
+
+			if test.wantErr {
+				if !errors.Is(err, utils.ErrValidation) {
+					t.Fatalf("ParseImportFile: got %v, want a validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("ParseImportFile: %v", err)
+			}
+
+			if titles := itemTitles(items); !slices.Equal(titles, test.titles) {
+				t.Fatalf("titles: got %q, want %q", titles, test.titles)
+			}
+
+			for _, item := range items {
+				if item.Visibility != test.visibility {
+					t.Errorf("%s visibility: got %q, want %q", item.Source, item.Visibility, test.visibility)
+				}
+			}
+		})
+	}
+}
+
+func TestParseImportFSWalksLikeAStaticSite(t *testing.T) {
+	fsys := fstest.MapFS{
+		"README.md":                         {Data: []byte("# About this repo\n")},
+		"_config.yml":                       {Data: []byte("title: Blog\n")},
+		"_posts/2020-01-01-first.md":        {Data: []byte("First\n")},
+		"_drafts/unfinished.md":             {Data: []byte("---\ntitle: Unfinished\nvisibility: public\n---\nLater\n")},
+		"_layouts/post.md":                  {Data: []byte("{{ content }}\n")},
+		"content/post/second/index.md":      {Data: []byte("Second\n")},
+		"content/post/_index.md":            {Data: []byte("List page\n")},
+		"themes/ananke/exampleSite/post.md": {Data: []byte("Theme sample\n")},
+		"node_modules/pkg/CHANGELOG.md":     {Data: []byte("Changes\n")},
+		".github/ISSUE_TEMPLATE.md":         {Data: []byte("Issue\n")},
+		"static/sitemap.xml":                {Data: []byte("<urlset></urlset>")},
+		"sitemap.xml":                       {Data: []byte("<urlset></urlset>")},
+		"wordpress.xml":                     {Data: []byte(wxrHeader + `<item><title>Imported</title><wp:status>draft</wp:status></item>` + wxrFooter)},
+	}
+
+	items, err := parseImportFS(fsys, utils.VISIBILITY_PUBLIC)
+
+	if err != nil {
+		t.Fatalf("parseImportFS: %v", err)
+	}
+
+	visibilities := make(map[string]string)
+
+	for _, item := range items {
+		if item.Err != nil {
+			t.Errorf("%s: %v", item.Source, item.Err)
+		}
+
+		visibilities[item.Title] = item.Visibility
+	}
+
+	want := map[string]string{
+		"First":      utils.VISIBILITY_PUBLIC,
+		"Unfinished": utils.VISIBILITY_PRIVATE,
+		"Second":     utils.VISIBILITY_PUBLIC,
+		"Imported":   utils.VISIBILITY_PRIVATE,
+	}
+
+	if len(visibilities) != len(want) {
+		t.Fatalf("titles: got %q, want the four posts", itemTitles(items))
+	}
+
+	for title, visibility := range want {
+		if visibilities[title] != visibility {
+			t.Errorf("%s visibility: got %q, want %q", title, visibilities[title], visibility)
+		}
+	}
+}
+
+func TestParseImportFSCapsItems(t *testing.T) {
+	tests := []struct {
+		count   int
+		wantErr bool
+	}{
+		{utils.IMPORT_MAX_ITEMS, false},
+		{utils.IMPORT_MAX_ITEMS + 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(fmt.Sprint(test.count), func(t *testing.T) {
+			fsys := fstest.MapFS{}
+
+			for i := range test.count {
+				fsys[fmt.Sprintf("posts/post-%04d.md", i)] = &fstest.MapFile{Data: []byte("Text\n")}
+			}
+
+			items, err := parseImportFS(fsys, utils.VISIBILITY_PUBLIC)
+
+			if test.wantErr {
+				if !errors.Is(err, utils.ErrValidation) {
+					t.Fatalf("parseImportFS: got %v, want a validation error", err)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("parseImportFS: %v", err)
+			}
+
+			if len(items) != test.count {
+				t.Fatalf("got %d items, want %d", len(items), test.count)
+			}
+		})
+	}
+}
+
+func TestPrepareImportItem(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	created := time.Date(2015, 3, 2, 0, 0, 0, 0, time.UTC)
+	longTitle := strings.Repeat("é", utils.BLOG_TITLE_MAX_LENGTH)
+
+	tests := []struct {
+		name      string
+		item      types.ImportItem
+		hasKey    bool
+		title     string
+		tags      []string
+		createdAt time.Time
+		wantErr   string
+	}{
+		{
+			name:      "public post",
+			item:      types.ImportItem{Title: "Hello", Content: "Text", Visibility: utils.VISIBILITY_PUBLIC, CreatedAt: created, Tags: []string{"Go Lang", "go-lang", "Travel"}},
+			title:     "Hello",
+			tags:      []string{"go-lang", "travel"},
+			createdAt: created,
+		},
+		{
+			name:      "missing date uses the clock",
+			item:      types.ImportItem{Title: "Undated", Content: "Text", Visibility: utils.VISIBILITY_PUBLIC},
+			title:     "Undated",
+			tags:      []string{},
+			createdAt: now,
+		},
+		{
+			name:      "long title is trimmed on a character boundary",
+			item:      types.ImportItem{Title: longTitle, Content: "Text", Visibility: utils.VISIBILITY_PUBLIC, CreatedAt: created},
+			title:     strings.Repeat("é", utils.BLOG_TITLE_MAX_LENGTH/2),
+			tags:      []string{},
+			createdAt: created,
+		},
+		{
+			name:      "private post with the owner's key",
+			item:      types.ImportItem{Title: "Diary", Content: "Text", Visibility: utils.VISIBILITY_PRIVATE, CreatedAt: created},
+			hasKey:    true,
+			title:     "Diary",
+			tags:      []string{},
+			createdAt: created,
+		},
+		{
+			name:    "parse error",
+			item:    types.ImportItem{Err: errors.New("invalid front matter")},
+			wantErr: "invalid front matter",
+		},
+		{
+			name:    "missing title",
+			item:    types.ImportItem{Content: "Text", Visibility: utils.VISIBILITY_PUBLIC},
+			wantErr: "post has no title",
+		},
+		{
+			name:    "missing content",
+			item:    types.ImportItem{Title: "Empty", Visibility: utils.VISIBILITY_PUBLIC},
+			wantErr: "post has no content",
+		},
+		{
+			name:    "oversized content",
+			item:    types.ImportItem{Title: "Huge", Content: strings.Repeat("a", utils.IMPORT_CONTENT_MAX_LENGTH+1), Visibility: utils.VISIBILITY_PUBLIC},
+			wantErr: fmt.Sprintf("post is longer than %d characters", utils.IMPORT_CONTENT_MAX_LENGTH),
+		},
+		{
+			name:    "private post without the owner's key",
+			item:    types.ImportItem{Title: "Diary", Content: "Text", Visibility: utils.VISIBILITY_PRIVATE},
+			wantErr: "private posts can only be imported with the owner's password",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			app := newImportApp(now)
+
+			if test.hasKey {
+				app.KeyCache.(*fakeKeyCache).users[1] = true
+			}
+
+			item := test.item
+			err := prepareImportItem(app, &item, 1)
+
+			if test.wantErr != "" {
+				if err == nil || err.Error() != test.wantErr {
+					t.Fatalf("prepareImportItem: got %v, want %q", err, test.wantErr)
+				}
+
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("prepareImportItem: %v", err)
+			}
+
+			if item.Title != test.title {
+				t.Errorf("Title: got %q, want %q", item.Title, test.title)
+			}
+
+			if !slices.Equal(item.Tags, test.tags) {
+				t.Errorf("Tags: got %q, want %q", item.Tags, test.tags)
+			}
+
+			if !item.CreatedAt.Equal(test.createdAt) {
+				t.Errorf("CreatedAt: got %v, want %v", item.CreatedAt, test.createdAt)
+			}
+		})
+	}
+}
+
+func TestImportPostsCapsItems(t *testing.T) {
+	items := make([]*types.ImportItem, utils.IMPORT_MAX_ITEMS+5)
+
+	// Untitled posts fail before reaching the database
+	for i := range items {
+		items[i] = &types.ImportItem{Source: fmt.Sprintf("post-%d.md", i), Content: "Text"}
+	}
+
+	results := ImportPosts(context.Background(), newImportApp(time.Now()), 1, items)
+
+	if len(results) != utils.IMPORT_MAX_ITEMS {
+		t.Fatalf("got %d results, want %d", len(results), utils.IMPORT_MAX_ITEMS)
+	}
+
+	for _, result := range results {
+		if result.Error != "post has no title" || result.PostID != 0 {
+			t.Fatalf("%s: got error %q & post %d", result.Source, result.Error, result.PostID)
+		}
+	}
+}
+
+func TestNormalizeImportTags(t *testing.T) {
+	rawTags := []string{"Go", "go", "Web Development", "  ", "#hashtag", strings.Repeat("x", utils.TAG_MAX_LENGTH+10)}
+
+	for i := range utils.TAG_MAX_PER_POST {
+		rawTags = append(rawTags, fmt.Sprintf("extra%d", i))
+	}
+
+	tags := normalizeImportTags(rawTags)
+
+	if len(tags) != utils.TAG_MAX_PER_POST {
+		t.Fatalf("got %d tags, want %d", len(tags), utils.TAG_MAX_PER_POST)
+	}
+
+	if tags[0] != "go" || tags[1] != "web-development" {
+		t.Fatalf("tags: got %q", tags)
+	}
+
+	for _, tag := range tags {
+		if len(tag) > utils.TAG_MAX_LENGTH {
+			t.Errorf("tag %q is longer than %d", tag, utils.TAG_MAX_LENGTH)
+		}
+	}
+}
+
+func itemTitles(items []*types.ImportItem) []string {
+	var titles []string
+
+	for _, item := range items {
+		titles = append(titles, item.Title)
+	}
+
+	return titles
+}
+
+func zipOf(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	archive := zip.NewWriter(&buffer)
+
+	for name, content := range files {
+		file, err := archive.Create(name)
+
+		if err != nil {
+			t.Fatalf("Create: %v", err)
+		}
+
+		if _, err := file.Write([]byte(content)); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	return buffer.Bytes()
+}
+
+func newImportApp(now time.Time) *types.App {
+	return &types.App{KeyCache: &fakeKeyCache{users: map[int]bool{}}, Clock: fixedClock{now}}
+}
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c fixedClock) Now() time.Time {
+	return c.now
+}
+
+// Only records who has a key, import never reads the key itself
+type fakeKeyCache struct {
+	users map[int]bool
+}
+
+func (c *fakeKeyCache) DeriveAndCache(userID int, password string, salt []byte) error {
+	c.users[userID] = true
+	return nil
+}
+
+func (c *fakeKeyCache) Get(userID int) ([]byte, error) {
+	return nil, errors.New("no key in tests")
+}
+
+func (c *fakeKeyCache) Has(userID int) bool {
+	return c.users[userID]
+}
+
+func (c *fakeKeyCache) Remove(userID int) {
+	delete(c.users, userID)
+}
+
+func (c *fakeKeyCache) Len() int {
+	return len(c.users)
+}
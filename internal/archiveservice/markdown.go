@@ -0,0 +1,244 @@
+package archiveservice
+
+import (
+	"App/internal/blogservice"
+	"App/internal/types"
+	"App/internal/utils"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Jekyll names posts like 2019-03-01-my-first-post.md
+var datedFileName = regexp.MustCompile(`^(\d{4}-\d{2}-\d{2})-(.+)$`)
+
+var importDateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05 -0700",
+	"2006-01-02 15:04:05 -07:00",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func ParseMarkdownPost(source string, data []byte, defaultVisibility string) *types.ImportItem {
+	item := &types.ImportItem{Source: source, Visibility: defaultVisibility}
+
+	// Normalise line endings & drop a byte order mark before looking for front matter
+	text := strings.ReplaceAll(strings.TrimPrefix(string(data), "\ufeff"), "\r\n", "\n")
+
+	meta, body, err := splitFrontMatter(text)
+
+	if err != nil {
+		item.Err = err
+		return item
+	}
+
+	// Posts exported without their owner's key can't be turned back into plain text
+	if isTrue(meta["encrypted"]) {
+		item.Err = fmt.Errorf("post is still encrypted, export it again while logged in to import it")
+		return item
+	}
+
+	item.Content = strings.TrimSpace(body)
+	item.Title = strings.TrimSpace(stringValue(meta["title"]))
+
+	// Fall back to a leading heading, then to the file name, for the title
+	if item.Title == "" {
+		if heading, rest, found := strings.Cut(item.Content, "\n"); strings.HasPrefix(heading, "# ") {
+			item.Title = strings.TrimSpace(strings.TrimPrefix(heading, "# "))
+
+			if found {
+				item.Content = strings.TrimSpace(rest)
+			} else {
+				item.Content = ""
+			}
+		}
+	}
+
+	fileDate, fileSlug := splitFileName(source)
+
+	if item.Title == "" {
+		item.Title = titleFromSlug(fileSlug)
+	}
+
+	// Prefer the front matter date, then the date in a Jekyll file name
+	item.CreatedAt, err = firstDate(meta["date"], meta["publishDate"], meta["published_at"])
+
+	if err != nil {
+		item.Err = err
+		return item
+	}
+
+	if item.CreatedAt.IsZero() && fileDate != "" {
+		item.CreatedAt, _ = time.Parse("2006-01-02", fileDate)
+	}
+
+	// Drafts & unpublished posts stay private
+	if visibility := strings.ToLower(stringValue(meta["visibility"])); visibility != "" {
+		if !blogservice.IsValidVisibility(visibility) {
+			item.Err = fmt.Errorf("unknown visibility %q", visibility)
+			return item
+		}
+
+		item.Visibility = visibility
+	}
+
+	if isTrue(meta["draft"]) || isTrue(meta["private"]) || isFalse(meta["published"]) {
+		item.Visibility = utils.VISIBILITY_PRIVATE
+	}
+
+	item.Tags = append(listValue(meta["tags"]), listValue(meta["categories"])...)
+
+	return item
+}
+
+func splitFrontMatter(text string) (map[string]any, string, error) {
+	meta := map[string]any{}
+
+	// YAML front matter is fenced by ---, Hugo's TOML front matter by +++
+	for _, fence := range []string{"---", "+++"} {
+		if !strings.HasPrefix(text, fence+"\n") {
+			continue
+		}
+
+		rest := text[len(fence)+1:]
+		var header, body string
+
+		// Allow an empty front matter block
+		if strings.HasPrefix(rest, fence) {
+			body = rest[len(fence):]
+		} else {
+			end := strings.Index(rest, "\n"+fence)
+
+			if end < 0 {
+				return nil, "", fmt.Errorf("front matter is missing its closing %s", fence)
+			}
+
+			header, body = rest[:end], rest[end+1+len(fence):]
+		}
+
+		body = strings.TrimPrefix(body, "\n")
+
+		var err error
+
+		if fence == "---" {
+			err = yaml.Unmarshal([]byte(header), &meta)
+		} else {
+			err = toml.Unmarshal([]byte(header), &meta)
+		}
+
+		if err != nil {
+			return nil, "", fmt.Errorf("invalid front matter: %v", err)
+		}
+
+		return meta, body, nil
+	}
+
+	return meta, text, nil
+}
+
+func splitFileName(source string) (string, string) {
+	// Hugo page bundles keep the post in index.md, so use the folder name instead
+	name := strings.TrimSuffix(path.Base(source), path.Ext(source))
+
+	if name == "index" {
+		name = path.Base(path.Dir(source))
+	}
+
+	if match := datedFileName.FindStringSubmatch(name); match != nil {
+		return match[1], match[2]
+	}
+
+	return "", name
+}
+
+func titleFromSlug(slug string) string {
+	title := strings.TrimSpace(strings.NewReplacer("-", " ", "_", " ").Replace(slug))
+
+	if title == "" || title == "." {
+		return ""
+	}
+
+	return strings.ToUpper(title[:1]) + title[1:]
+}
+
+func firstDate(values ...any) (time.Time, error) {
+	for _, value := range values {
+		if value == nil {
+			continue
+		}
+
+		date, err := parseImportDate(value)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		return date, nil
+	}
+
+	return time.Time{}, nil
+}
+
+func parseImportDate(value any) (time.Time, error) {
+	// YAML & TOML decoders hand back their own date types for unquoted dates
+	switch date := value.(type) {
+	case time.Time:
+		return date.UTC(), nil
+	case toml.LocalDateTime:
+		return date.AsTime(time.UTC), nil
+	case toml.LocalDate:
+		return date.AsTime(time.UTC), nil
+	case string:
+		for _, layout := range importDateLayouts {
+			if parsed, err := time.Parse(layout, strings.TrimSpace(date)); err == nil {
+				return parsed.UTC(), nil
+			}
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unrecognised date %v", value)
+}
+
+func stringValue(value any) string {
+	if value == nil {
+		return ""
+	}
+
+	return fmt.Sprint(value)
+}
+
+func listValue(value any) []string {
+	// Lists may be written as arrays or as a single comma or space separated string
+	switch list := value.(type) {
+	case []any:
+		values := make([]string, 0, len(list))
+
+		for _, entry := range list {
+			values = append(values, fmt.Sprint(entry))
+		}
+
+		return values
+	case string:
+		return strings.FieldsFunc(list, func(r rune) bool { return r == ',' || r == ' ' })
+	}
+
+	return nil
+}
+
+func isTrue(value any) bool {
+	flag, ok := value.(bool)
+	return ok && flag
+}
+
+func isFalse(value any) bool {
+	flag, ok := value.(bool)
+	return ok && !flag
+}
@@ -0,0 +1,220 @@
+package archiveservice
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"App/internal/utils"
+)
+
+func TestParseMarkdownPost(t *testing.T) {
+	tests := []struct {
+		name              string
+		source            string
+		data              string
+		defaultVisibility string
+		title             string
+		content           string
+		visibility        string
+		createdAt         time.Time
+		tags              []string
+		wantErr           bool
+	}{
+		{
+			name:              "yaml front matter",
+			source:            "posts/hello.md",
+			data:              "---\ntitle: Hello world\ndate: 2021-05-04T10:30:00Z\ntags: [go, web]\ncategories: notes\n---\n\nFirst post.\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Hello world",
+			content:           "First post.",
+			visibility:        utils.VISIBILITY_PUBLIC,
+			createdAt:         time.Date(2021, 5, 4, 10, 30, 0, 0, time.UTC),
+			tags:              []string{"go", "web", "notes"},
+		},
+		{
+			name:              "toml front matter",
+			source:            "content/post/hugo.md",
+			data:              "+++\ntitle = \"From Hugo\"\ndate = 2020-01-02\ntags = [\"hugo\"]\n+++\nBody text\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "From Hugo",
+			content:           "Body text",
+			visibility:        utils.VISIBILITY_PUBLIC,
+			createdAt:         time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+			tags:              []string{"hugo"},
+		},
+		{
+			name:              "default visibility",
+			source:            "posts/plain.md",
+			data:              "---\ntitle: Plain\n---\nText\n",
+			defaultVisibility: utils.VISIBILITY_UNLISTED,
+			title:             "Plain",
+			content:           "Text",
+			visibility:        utils.VISIBILITY_UNLISTED,
+		},
+		{
+			name:              "front matter visibility wins over the default",
+			source:            "posts/friends.md",
+			data:              "---\ntitle: Friends\nvisibility: Followers\n---\nText\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Friends",
+			content:           "Text",
+			visibility:        utils.VISIBILITY_FOLLOWERS,
+		},
+		{
+			name:              "draft",
+			source:            "posts/draft.md",
+			data:              "---\ntitle: Draft\ndraft: true\n---\nNot yet\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Draft",
+			content:           "Not yet",
+			visibility:        utils.VISIBILITY_PRIVATE,
+		},
+		{
+			name:              "unpublished",
+			source:            "posts/unpublished.md",
+			data:              "---\ntitle: Hidden\npublished: false\nvisibility: public\n---\nNot yet\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Hidden",
+			content:           "Not yet",
+			visibility:        utils.VISIBILITY_PRIVATE,
+		},
+		{
+			name:              "title from leading heading",
+			source:            "notes/untitled.md",
+			data:              "# Heading title\n\nThe body.\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Heading title",
+			content:           "The body.",
+			visibility:        utils.VISIBILITY_PUBLIC,
+		},
+		{
+			name:              "title and date from jekyll file name",
+			source:            "_posts/2019-03-01-my-first-post.md",
+			data:              "---\nlayout: post\n---\nHello\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "My first post",
+			content:           "Hello",
+			visibility:        utils.VISIBILITY_PUBLIC,
+			createdAt:         time.Date(2019, 3, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "title from hugo page bundle",
+			source:            "content/post/trip-notes/index.md",
+			data:              "Packed the bags.\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Trip notes",
+			content:           "Packed the bags.",
+			visibility:        utils.VISIBILITY_PUBLIC,
+		},
+		{
+			name:              "missing title",
+			source:            "index.md",
+			data:              "Just text.\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			content:           "Just text.",
+			visibility:        utils.VISIBILITY_PUBLIC,
+		},
+		{
+			name:              "crlf and byte order mark",
+			source:            "posts/windows.md",
+			data:              "\ufeff---\r\ntitle: Windows\r\n---\r\nLine one\r\nLine two\r\n",
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			title:             "Windows",
+			content:           "Line one\nLine two",
+			visibility:        utils.VISIBILITY_PUBLIC,
+		},
+		{
+			name:    "unclosed front matter",
+			source:  "posts/broken.md",
+			data:    "---\ntitle: Broken\nBody\n",
+			wantErr: true,
+		},
+		{
+			name:    "unknown visibility",
+			source:  "posts/odd.md",
+			data:    "---\ntitle: Odd\nvisibility: secret\n---\nText\n",
+			wantErr: true,
+		},
+		{
+			name:    "unrecognised date",
+			source:  "posts/when.md",
+			data:    "---\ntitle: When\ndate: last tuesday\n---\nText\n",
+			wantErr: true,
+		},
+		{
+			name:    "still encrypted",
+			source:  "posts/00001-post.md",
+			data:    "---\ntitle: \"c2VjcmV0\"\nencrypted: true\n---\nc2VjcmV0\n",
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			item := ParseMarkdownPost(test.source, []byte(test.data), test.defaultVisibility)
+
+			if test.wantErr {
+				if item.Err == nil {
+					t.Fatalf("Err: got nil, want an error")
+				}
+
+				return
+			}
+
+			if item.Err != nil {
+				t.Fatalf("Err: %v", item.Err)
+			}
+
+			if item.Source != test.source {
+				t.Errorf("Source: got %q, want %q", item.Source, test.source)
+			}
+
+			if item.Title != test.title {
+				t.Errorf("Title: got %q, want %q", item.Title, test.title)
+			}
+
+			if item.Content != test.content {
+				t.Errorf("Content: got %q, want %q", item.Content, test.content)
+			}
+
+			if item.Visibility != test.visibility {
+				t.Errorf("Visibility: got %q, want %q", item.Visibility, test.visibility)
+			}
+
+			if !item.CreatedAt.Equal(test.createdAt) {
+				t.Errorf("CreatedAt: got %v, want %v", item.CreatedAt, test.createdAt)
+			}
+
+			if !slices.Equal(item.Tags, test.tags) {
+				t.Errorf("Tags: got %q, want %q", item.Tags, test.tags)
+			}
+		})
+	}
+}
+
+func TestParseImportDate(t *testing.T) {
+	tests := []struct {
+		value any
+		want  time.Time
+	}{
+		{"2021-05-04T10:30:00+02:00", time.Date(2021, 5, 4, 8, 30, 0, 0, time.UTC)},
+		{"2021-05-04 10:30:00 -0700", time.Date(2021, 5, 4, 17, 30, 0, 0, time.UTC)},
+		{"2021-05-04 10:30", time.Date(2021, 5, 4, 10, 30, 0, 0, time.UTC)},
+		{" 2021-05-04 ", time.Date(2021, 5, 4, 0, 0, 0, 0, time.UTC)},
+		{time.Date(2021, 5, 4, 10, 30, 0, 0, time.FixedZone("CEST", 2*60*60)), time.Date(2021, 5, 4, 8, 30, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		got, err := parseImportDate(test.value)
+
+		if err != nil {
+			t.Errorf("parseImportDate(%v): %v", test.value, err)
+			continue
+		}
+
+		if !got.Equal(test.want) {
+			t.Errorf("parseImportDate(%v): got %v, want %v", test.value, got, test.want)
+		}
+	}
+}
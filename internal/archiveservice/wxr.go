@@ -0,0 +1,149 @@
+package archiveservice
+
+import (
+	"App/internal/types"
+	"App/internal/utils"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"golang.org/x/net/html"
+)
+
+type wxrDocument struct {
+	Channel struct {
+		Items []wxrItem `xml:"item"`
+	} `xml:"channel"`
+}
+
+type wxrItem struct {
+	Title        string        `xml:"title"`
+	Content      string        `xml:"http://purl.org/rss/1.0/modules/content/ encoded"`
+	PostID       string        `xml:"post_id"`
+	PostDate     string        `xml:"post_date"`
+	PostDateGMT  string        `xml:"post_date_gmt"`
+	Status       string        `xml:"status"`
+	PostType     string        `xml:"post_type"`
+	PostPassword string        `xml:"post_password"`
+	Categories   []wxrCategory `xml:"category"`
+}
+
+type wxrCategory struct {
+	Domain   string `xml:"domain,attr"`
+	Nicename string `xml:"nicename,attr"`
+	Name     string `xml:",chardata"`
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+func IsWXR(data []byte) bool {
+	// Every WordPress export declares the wp export namespace
+	return bytes.Contains(data, []byte("wordpress.org/export/"))
+}
+
+func ParseWXR(source string, data []byte, defaultVisibility string) ([]*types.ImportItem, error) {
+	var document wxrDocument
+
+	if err := xml.Unmarshal(data, &document); err != nil {
+		return nil, utils.Validation("invalid WordPress export %s: %v", source, err)
+	}
+
+	var items []*types.ImportItem
+
+	for i, entry := range document.Channel.Items {
+		// Pages, attachments & menu items are not blog posts
+		if entry.PostType != "" && entry.PostType != "post" {
+			continue
+		}
+
+		itemSource := fmt.Sprintf("%s#%d", source, i+1)
+
+		if entry.PostID != "" {
+			itemSource = fmt.Sprintf("%s#post-%s", source, entry.PostID)
+		}
+
+		item := &types.ImportItem{
+			Source:  itemSource,
+			Title:   strings.TrimSpace(entry.Title),
+			Content: htmlToText(entry.Content),
+			// Only published posts without a password were ever public
+			Visibility: visibilityFor(entry.Status == "publish" && entry.PostPassword == "", defaultVisibility),
+		}
+
+		// Drafts have a zeroed GMT date, so fall back to the local one
+		item.CreatedAt = parseWXRDate(entry.PostDateGMT)
+
+		if item.CreatedAt.IsZero() {
+			item.CreatedAt = parseWXRDate(entry.PostDate)
+		}
+
+		for _, category := range entry.Categories {
+			if category.Domain != "post_tag" && category.Domain != "category" {
+				continue
+			}
+
+			// Skip WordPress' default category, it carries no meaning
+			if category.Nicename == "uncategorized" {
+				continue
+			}
+
+			item.Tags = append(item.Tags, category.Name)
+		}
+
+		items = append(items, item)
+	}
+
+	return items, nil
+}
+
+func parseWXRDate(value string) time.Time {
+	date, err := time.Parse("2006-01-02 15:04:05", strings.TrimSpace(value))
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return date
+}
+
+func htmlToText(content string) string {
+	tokenizer := html.NewTokenizer(strings.NewReader(content))
+
+	var builder strings.Builder
+	skipDepth := 0
+
+	for {
+		tokenType := tokenizer.Next()
+
+		switch tokenType {
+		case html.ErrorToken:
+			// Collapse the blank lines left behind by nested block elements
+			return strings.TrimSpace(blankLines.ReplaceAllString(builder.String(), "\n\n"))
+		case html.TextToken:
+			if skipDepth == 0 {
+				builder.Write(tokenizer.Text())
+			}
+		case html.StartTagToken, html.EndTagToken, html.SelfClosingTagToken:
+			name, _ := tokenizer.TagName()
+			isStart := tokenType != html.EndTagToken
+
+			switch string(name) {
+			case "script", "style":
+				if isStart {
+					skipDepth++
+				} else if skipDepth > 0 {
+					skipDepth--
+				}
+			case "br":
+				builder.WriteString("\n")
+			case "p", "div", "h1", "h2", "h3", "h4", "h5", "h6", "blockquote", "pre", "ul", "ol", "table":
+				builder.WriteString("\n\n")
+			case "li", "tr":
+				builder.WriteString("\n")
+			}
+		}
+	}
+}
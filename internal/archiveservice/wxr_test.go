@@ -0,0 +1,187 @@
+package archiveservice
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"App/internal/utils"
+)
+
+const wxrHeader = `<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0"
+	xmlns:content="http://purl.org/rss/1.0/modules/content/"
+	xmlns:wp="http://wordpress.org/export/1.2/">
+<channel>
+`
+
+const wxrFooter = `</channel>
+</rss>
+`
+
+func TestParseWXR(t *testing.T) {
+	tests := []struct {
+		name              string
+		item              string
+		defaultVisibility string
+		source            string
+		title             string
+		content           string
+		visibility        string
+		createdAt         time.Time
+		tags              []string
+	}{
+		{
+			name: "published post",
+			item: `<item>
+				<title>Hello WordPress</title>
+				<content:encoded><![CDATA[<p>First paragraph.</p><p>Second<br/>line.</p><script>alert(1)</script>]]></content:encoded>
+				<wp:post_id>42</wp:post_id>
+				<wp:post_date>2018-07-01 12:00:00</wp:post_date>
+				<wp:post_date_gmt>2018-07-01 10:00:00</wp:post_date_gmt>
+				<wp:status>publish</wp:status>
+				<wp:post_type>post</wp:post_type>
+				<category domain="category" nicename="uncategorized"><![CDATA[Uncategorized]]></category>
+				<category domain="category" nicename="travel"><![CDATA[Travel]]></category>
+				<category domain="post_tag" nicename="summer"><![CDATA[Summer]]></category>
+				<category domain="post_format" nicename="aside"><![CDATA[Aside]]></category>
+			</item>`,
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			source:            "export.xml#post-42",
+			title:             "Hello WordPress",
+			content:           "First paragraph.\n\nSecond\nline.",
+			visibility:        utils.VISIBILITY_PUBLIC,
+			createdAt:         time.Date(2018, 7, 1, 10, 0, 0, 0, time.UTC),
+			tags:              []string{"Travel", "Summer"},
+		},
+		{
+			name: "default visibility",
+			item: `<item>
+				<title>Unlisted by default</title>
+				<content:encoded><![CDATA[Text]]></content:encoded>
+				<wp:status>publish</wp:status>
+			</item>`,
+			defaultVisibility: utils.VISIBILITY_UNLISTED,
+			source:            "export.xml#1",
+			title:             "Unlisted by default",
+			content:           "Text",
+			visibility:        utils.VISIBILITY_UNLISTED,
+		},
+		{
+			name: "draft",
+			item: `<item>
+				<title>Work in progress</title>
+				<content:encoded><![CDATA[<p>Later</p>]]></content:encoded>
+				<wp:post_id>7</wp:post_id>
+				<wp:post_date>2019-02-03 08:15:00</wp:post_date>
+				<wp:post_date_gmt>0000-00-00 00:00:00</wp:post_date_gmt>
+				<wp:status>draft</wp:status>
+				<wp:post_type>post</wp:post_type>
+			</item>`,
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			source:            "export.xml#post-7",
+			title:             "Work in progress",
+			content:           "Later",
+			visibility:        utils.VISIBILITY_PRIVATE,
+			createdAt:         time.Date(2019, 2, 3, 8, 15, 0, 0, time.UTC),
+		},
+		{
+			name: "password protected",
+			item: `<item>
+				<title>Members only</title>
+				<content:encoded><![CDATA[Secret]]></content:encoded>
+				<wp:status>publish</wp:status>
+				<wp:post_password>hunter2</wp:post_password>
+			</item>`,
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			source:            "export.xml#1",
+			title:             "Members only",
+			content:           "Secret",
+			visibility:        utils.VISIBILITY_PRIVATE,
+		},
+		{
+			name: "missing title",
+			item: `<item>
+				<title></title>
+				<content:encoded><![CDATA[A status update]]></content:encoded>
+				<wp:status>publish</wp:status>
+			</item>`,
+			defaultVisibility: utils.VISIBILITY_PUBLIC,
+			source:            "export.xml#1",
+			content:           "A status update",
+			visibility:        utils.VISIBILITY_PUBLIC,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := ParseWXR("export.xml", []byte(wxrHeader+test.item+wxrFooter), test.defaultVisibility)
+
+			if err != nil {
+				t.Fatalf("ParseWXR: %v", err)
+			}
+
+			if len(items) != 1 {
+				t.Fatalf("got %d items, want 1", len(items))
+			}
+
+			item := items[0]
+
+			if item.Source != test.source {
+				t.Errorf("Source: got %q, want %q", item.Source, test.source)
+			}
+
+			if item.Title != test.title {
+				t.Errorf("Title: got %q, want %q", item.Title, test.title)
+			}
+
+			if item.Content != test.content {
+				t.Errorf("Content: got %q, want %q", item.Content, test.content)
+			}
+
+			if item.Visibility != test.visibility {
+				t.Errorf("Visibility: got %q, want %q", item.Visibility, test.visibility)
+			}
+
+			if !item.CreatedAt.Equal(test.createdAt) {
+				t.Errorf("CreatedAt: got %v, want %v", item.CreatedAt, test.createdAt)
+			}
+
+			if !slices.Equal(item.Tags, test.tags) {
+				t.Errorf("Tags: got %q, want %q", item.Tags, test.tags)
+			}
+		})
+	}
+}
+
+func TestParseWXRSkipsPagesAndAttachments(t *testing.T) {
+	data := wxrHeader + `
+		<item><title>About</title><wp:post_type>page</wp:post_type></item>
+		<item><title>photo.jpg</title><wp:post_type>attachment</wp:post_type></item>
+		<item><title>Only post</title><wp:post_type>post</wp:post_type><wp:status>publish</wp:status></item>
+	` + wxrFooter
+
+	items, err := ParseWXR("export.xml", []byte(data), utils.VISIBILITY_PUBLIC)
+
+	if err != nil {
+		t.Fatalf("ParseWXR: %v", err)
+	}
+
+	if len(items) != 1 || items[0].Title != "Only post" {
+		t.Fatalf("got %d items, want only the post", len(items))
+	}
+
+	// Numbering follows the position in the export, pages included
+	if items[0].Source != "export.xml#3" {
+		t.Errorf("Source: got %q, want %q", items[0].Source, "export.xml#3")
+	}
+}
+
+func TestParseWXRRejectsInvalidXML(t *testing.T) {
+	_, err := ParseWXR("export.xml", []byte(wxrHeader+"<item><title>Cut off"), utils.VISIBILITY_PUBLIC)
+
+	if !errors.Is(err, utils.ErrValidation) {
+		t.Fatalf("ParseWXR: got %v, want a validation error", err)
+	}
+}
@@ -1,166 +1,390 @@
 package blogservice
 
 import (
+	"App/internal/tracing"
 	"App/internal/types"
 	"App/internal/utils"
+	"context"
 	"database/sql"
 	"fmt"
-	"log"
 	"sync"
+	"time"
 )
 
-func InsertBlogPostIntoDB(db *sql.DB, postData *types.CreateBlogPost) error {
+// Extra writes that must commit or roll back together with a post, e.g. attaching its images
+type PostTxFunc func(ctx context.Context, tx *sql.Tx, postID int) error
+
+func InsertBlogPostIntoDB(ctx context.Context, app *types.App, postData *types.CreateBlogPost, withTx PostTxFunc) (int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.InsertBlogPostIntoDB")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// New posts are dated by the database
+	return insertBlogPost(ctx, app, postData, withTx, utils.InsertPostQuery)
+}
+
+func ImportBlogPostIntoDB(ctx context.Context, app *types.App, postData *types.CreateBlogPost, createdAt time.Time) (int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.ImportBlogPostIntoDB")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Imported posts keep the date they were originally published
+	return insertBlogPost(ctx, app, postData, nil, utils.InsertImportedPostQuery, createdAt.UTC().Format(dbTimeLayout))
+}
+
+func insertBlogPost(ctx context.Context, app *types.App, postData *types.CreateBlogPost, withTx PostTxFunc, query string, extra ...any) (int, error) {
 	// Encrypt blog content if needed
-	title, content, err := EncryptBlogPost(postData.Title, postData.Content, postData.UserID, postData.IsPublic)
+	title, content, err := EncryptBlogPost(app, postData.Title, postData.Content, postData.UserID, postData.Visibility)
 
 	if err != nil {
-		log.Printf("Failed to encrypt blog post title and content: %v", err)
-		return fmt.Errorf("encryption error: failed to encrypt blog post title and content")
+		app.Logger.ErrorContext(ctx, "Failed to encrypt blog post title and content", "error", err)
+		return 0, utils.Internal(err, "encryption error: failed to encrypt blog post title and content")
 	}
 
-	// Execute the SQL query
-	if result, err := db.Exec(utils.InsertPostQuery, title, content, postData.UserID, postData.IsPublic); err != nil {
-		log.Printf("SQL execution error while inserting blog post: %v", err)
-		return fmt.Errorf("database error: failed to insert blog post")
+	// Encrypt tags for private posts
+	encryptedTags, err := EncryptTags(app, postData.Tags, postData.UserID, postData.Visibility)
 
-	} else if rowsAffected, err := result.RowsAffected(); err != nil {
-		log.Printf("Error retrieving affected rows for blog post insertion: %v", err)
-		return fmt.Errorf("database error: unable to confirm blog post creation")
+	if err != nil {
+		return 0, utils.Internal(err, "encryption error: failed to encrypt blog post tags")
+	}
 
-	} else if rowsAffected == 0 {
-		log.Printf("No rows affected while inserting blog post. .Post data: %+v", postData)
-		return fmt.Errorf("blog post creation unsuccessful")
+	// Insert the post and its tags together
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for blog post insertion", "error", err)
+		return 0, utils.DatabaseError(ctx, err, "failed to insert blog post")
 	}
 
-	// Return nil if inserting post into DB was successful
-	return nil
+	defer tx.Rollback()
+
+	// Execute the SQL query with any extra columns requested by the caller
+	args := append([]any{title, content, postData.UserID, postData.Visibility, encryptedTags}, extra...)
+	result, err := tx.ExecContext(ctx, query, args...)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while inserting blog post", "error", err)
+		return 0, utils.DatabaseError(ctx, err, "failed to insert blog post")
+	}
+
+	postID, err := result.LastInsertId()
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error retrieving ID for blog post insertion", "error", err)
+		return 0, utils.DatabaseError(ctx, err, "unable to confirm blog post creation")
+	}
+
+	if err := replacePostTags(ctx, app, tx, int(postID), postData.UserID, postData.Tags, postData.Visibility); err != nil {
+		return 0, err
+	}
+
+	// The first revision is the post as it was created
+	if err := saveRevision(ctx, app, tx, int(postID), nil); err != nil {
+		return 0, err
+	}
+
+	if withTx != nil {
+		if err := withTx(ctx, tx, int(postID)); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit blog post insertion", "error", err)
+		return 0, utils.DatabaseError(ctx, err, "failed to insert blog post")
+	}
+
+	// Return the new post ID if inserting post into DB was successful
+	return int(postID), nil
 }
 
-func UpdateBlogPostInDB(db *sql.DB, postData *types.UpdateBlogPost) error {
+func UpdateBlogPostInDB(ctx context.Context, app *types.App, postData *types.UpdateBlogPost, withTx PostTxFunc) error {
+	ctx, span := tracing.Start(ctx, "blogservice.UpdateBlogPostInDB")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	// Encrypt blog content if needed
-	title, content, err := EncryptBlogPost(postData.Title, postData.Content, postData.UserID, postData.IsPublic)
+	title, content, err := EncryptBlogPost(app, postData.Title, postData.Content, postData.UserID, postData.Visibility)
+
+	if err != nil {
+		return utils.Internal(err, "encryption error: failed to encrypt blog post title and content")
+	}
+
+	// Encrypt tags for private posts
+	encryptedTags, err := EncryptTags(app, postData.Tags, postData.UserID, postData.Visibility)
 
 	if err != nil {
-		return fmt.Errorf("encryption error: failed to encrypt blog post title and content")
+		return utils.Internal(err, "encryption error: failed to encrypt blog post tags")
+	}
+
+	// Update the post and its tags together
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for blog post update", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update blog post")
+	}
+
+	defer tx.Rollback()
+
+	// Make sure the post belongs to the user before touching its tags
+	var isOwner bool
+	if err := tx.QueryRowContext(ctx, utils.CheckPostOwnerQuery, postData.ID, postData.UserID).Scan(&isOwner); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while checking owner of blog post", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update blog post")
+	}
+
+	if !isOwner {
+		return utils.NotFound("post not found")
+	}
+
+	// Keep the version being replaced if it predates revision history
+	if err := saveOriginalRevision(ctx, app, tx, postData.ID); err != nil {
+		return err
 	}
 
 	// Execute the SQL query to update blog post
-	_, err = db.Exec(utils.UpdatePostQuery, title, content, postData.IsPublic, postData.ID, postData.UserID)
+	_, err = tx.ExecContext(ctx, utils.UpdatePostQuery, title, content, postData.Visibility, encryptedTags, postData.ID, postData.UserID)
 
 	if err != nil {
-		log.Printf("SQL execution error while updating blog post ID %d: %v", postData.ID, err)
-		return fmt.Errorf("database error: failed to update blog post")
+		app.Logger.ErrorContext(ctx, "SQL execution error while updating blog post", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update blog post")
+	}
+
+	if err := replacePostTags(ctx, app, tx, postData.ID, postData.UserID, postData.Tags, postData.Visibility); err != nil {
+		return err
+	}
+
+	// Every update is kept as a new revision
+	if err := saveRevision(ctx, app, tx, postData.ID, app.Clock.Now().UTC().Format(dbTimeLayout)); err != nil {
+		return err
+	}
+
+	// Earlier versions mustn't stay readable in the database once the post is private
+	if IsEncryptedVisibility(postData.Visibility) {
+		if err := encryptRevisions(ctx, app, tx, postData.ID, postData.UserID); err != nil {
+			return err
+		}
+	}
+
+	// Share links follow the post's latest version & are dropped once anyone can read it
+	if err := syncShareLinks(ctx, app, tx, postData); err != nil {
+		return err
+	}
+
+	if withTx != nil {
+		if err := withTx(ctx, tx, postData.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit blog post update", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update blog post")
 	}
 
 	// Return nil if update in DB was successful
 	return nil
 }
 
-func DeleteBlogPostFromDB(db *sql.DB, postID int, userID int) error {
+func DeleteBlogPostFromDB(ctx context.Context, app *types.App, postID int, userID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.DeleteBlogPostFromDB")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	// Execute the SQL query
-	if result, err := db.Exec(utils.DeletePostQuery, postID, userID); err != nil {
-		log.Printf("SQL execution error while deleting blog post ID %d by UserID %d: %v", postID, userID, err)
-		return fmt.Errorf("database error: failed to delete blog post")
+	if result, err := app.Database.ExecContext(ctx, utils.DeletePostQuery, postID, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while deleting blog post", "post_id", postID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete blog post")
 
 	} else if rowsAffected, err := result.RowsAffected(); err != nil {
-		log.Printf("Error retrieving affected rows for blog post deletion ID %d by UserID %d: %v", postID, userID, err)
-		return fmt.Errorf("database error: unable to confirm blog post deletion")
+		app.Logger.ErrorContext(ctx, "Error retrieving affected rows for blog post deletion", "post_id", postID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "unable to confirm blog post deletion")
 
 	} else if rowsAffected == 0 {
-		log.Printf("No rows affected while deleting blog post ID %d by UserID %d", postID, userID)
-		return fmt.Errorf("blog post deletion unsuccessful: no matching post or unauthorized")
+		app.Logger.WarnContext(ctx, "No rows affected while deleting blog post", "post_id", postID, "user_id", userID)
+		return utils.NotFound("post not found")
 	}
 
 	// Return nil if deletion was successful
 	return nil
 }
 
-func GetBlogPostsByUser(db *sql.DB, username string, isOwner bool, page, userID int) ([]*types.BlogPostData, int, error) {
+func GetBlogPostsByUser(ctx context.Context, app *types.App, username string, isOwner bool, page, userID int, tag string, limit int) ([]*types.BlogPostData, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetBlogPostsByUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	// Check if user exists in the database
 	var exists bool
-	if err := db.QueryRow(utils.UserExistsQuery, username).Scan(&exists); err != nil || !exists {
-		return nil, 0, fmt.Errorf("user %s does not exist or an error occurred", username)
+	if err := app.Database.QueryRowContext(ctx, utils.UserExistsQuery, username).Scan(&exists); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while checking user exists", "username", username, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to check user exists")
+	} else if !exists {
+		return nil, 0, utils.NotFound("user not found")
 	}
 
 	// Calculate pagination offset based on the post limit
-	limit := utils.POST_LIMIT_PER_PAGE
 	offset := (page - 1) * limit
 
+	// The owner's tag filter includes private posts, matched on the hashes of their tags
+	if isOwner && tag != "" {
+		return getOwnPostsByTag(ctx, app, userID, tag, limit, offset)
+	}
+
 	// Execute the query to retrieve blog posts from the user
-	rows, err := db.Query(utils.SelectPostsByUsername, username, userID, limit, offset)
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPostsByUsername, username, userID, userID, userID, userID, userID, userID, tag, tag, limit, offset)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while retrieving user posts", "username", username, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	var totalCount int
+
+	// Scan the posts along with the windowed total count
+	posts, err := scanUserPosts(ctx, app, rows, userID, &totalCount)
 
 	if err != nil {
-		return nil, 0, fmt.Errorf("error querying posts for user %s: %w", username, err)
+		return nil, 0, err
 	}
 
+	return posts, totalCount, nil
+}
+
+func scanUserPosts(ctx context.Context, app *types.App, rows *sql.Rows, userID int, extra ...any) ([]*types.BlogPostData, error) {
 	defer rows.Close()
 
 	// Prepare the slice for the results
 	var posts []*types.BlogPostData
-	var totalCount int
+	var publicIDs []int
 
 	// Iterate over the rows to build the posts slice
 	for rows.Next() {
 		post := &types.BlogPostData{}
 		var createdAt []byte
+		var encryptedTags sql.NullString
+
+		// Scan the row into the post struct & any extra columns requested by the caller
+		dest := append([]any{&post.ID, &post.Title, &post.Content, &createdAt, &post.Visibility, &encryptedTags}, extra...)
 
-		// Scan the row into the post struct
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.IsPublic, &totalCount); err != nil {
-			return nil, 0, fmt.Errorf("error scanning post for user %s: %w", username, err)
+		if err := rows.Scan(dest...); err != nil {
+			return nil, utils.DatabaseError(ctx, err, "error scanning post")
 		}
 
 		// Decrypt the content and title if needed
-		title, content, err := DecryptBlogPost(post.Title, post.Content, userID, post.IsPublic)
+		title, content, err := DecryptBlogPost(app, post.Title, post.Content, userID, post.Visibility)
 
 		if err != nil {
-			return nil, 0, fmt.Errorf("encryption error: failed to decrypt blog post title and content")
+			return nil, utils.Internal(err, "encryption error: failed to decrypt blog post title and content")
+		}
+
+		// Private posts carry their own encrypted tags, the rest are looked up below
+		if !IsEncryptedVisibility(post.Visibility) {
+			publicIDs = append(publicIDs, post.ID)
+		} else if post.Tags, err = DecryptTags(app, encryptedTags, userID); err != nil {
+			return nil, utils.Internal(err, "encryption error: failed to decrypt blog post tags")
 		}
 
 		// Assign decrypted title and content to the post
 		post.Content = TruncateString(content, utils.BLOG_POST_PREVIEW_LENGTH)
 		post.Title = title
 
-		// Format the creation date for the UI
+		// Format the creation date for the UI & remember its position for cursors
 		post.CreatedAt = FormatDate(createdAt)
+		post.PublishedAt = ParseDate(createdAt)
+		post.Cursor = EncodeCursor(createdAt, post.ID)
 
 		// Append the post to the results slice
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating posts for user %s: %w", username, err)
+		return nil, utils.DatabaseError(ctx, err, "error iterating posts")
 	}
 
-	return posts, totalCount, nil
+	// Attach tags to the posts on this page that aren't private
+	tags, err := GetTagsForPosts(ctx, app, publicIDs)
+
+	if err != nil {
+		return nil, err
+	}
+
+	for _, post := range posts {
+		if !IsEncryptedVisibility(post.Visibility) {
+			post.Tags = tags[post.ID]
+		}
+	}
+
+	return posts, nil
 }
 
-func GetBlogPostData(db *sql.DB, postID int, userID int, isLoggedIn bool) (*types.BlogPostPageData, error) {
+func GetBlogPostData(ctx context.Context, app *types.App, postID int, userID int, isLoggedIn bool) (*types.BlogPostPageData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetBlogPostData")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	var pageData = &types.BlogPostPageData{
 		Post: &types.BlogPostData{},
 	}
 	var createdAt []byte
+	var encryptedTags sql.NullString
 	var postUserID int
+	var avatarUpdatedAt []byte
 
 	// Execute the query to retrieve blog post by ID
-	if err := db.QueryRow(utils.SelectPostDetailsQuery, postID, userID).Scan(
+	if err := app.Database.QueryRowContext(ctx, utils.SelectPostDetailsQuery, postID, userID, userID, userID, userID, userID, userID).Scan(
 		&pageData.Post.ID, &pageData.Post.Title, &pageData.Post.Content,
-		&createdAt, &pageData.Post.IsPublic, &postUserID, &pageData.Username,
+		&createdAt, &pageData.Post.Visibility, &encryptedTags, &postUserID, &pageData.Username,
+		&pageData.DisplayName, &avatarUpdatedAt,
 	); err != nil {
-		return pageData, fmt.Errorf("post not found or access denied")
+		if err == sql.ErrNoRows {
+			return pageData, utils.NotFound("post not found")
+		}
+
+		app.Logger.ErrorContext(ctx, "SQL query error while loading blog post", "post_id", postID, "error", err)
+		return pageData, utils.DatabaseError(ctx, err, "failed to retrieve blog post")
 	}
 
 	// Decrypt the content if needed
-	title, content, err := DecryptBlogPost(pageData.Post.Title, pageData.Post.Content, userID, pageData.Post.IsPublic)
+	title, content, err := DecryptBlogPost(app, pageData.Post.Title, pageData.Post.Content, userID, pageData.Post.Visibility)
 
 	if err != nil {
-		return nil, fmt.Errorf("encryption error: failed to decrypt blog post title and content")
+		return nil, utils.Internal(err, "encryption error: failed to decrypt blog post title and content")
 	}
 
 	// Assign decrypted title and content to the post data
 	pageData.Post.Title = title
 	pageData.Post.Content = content
 
-	// Format the username & created date
+	// Load the post's tags from the table or its encrypted column
+	if !IsEncryptedVisibility(pageData.Post.Visibility) {
+		tags, err := GetTagsForPosts(ctx, app, []int{postID})
+
+		if err != nil {
+			return nil, err
+		}
+
+		pageData.Post.Tags = tags[postID]
+	} else if pageData.Post.Tags, err = DecryptTags(app, encryptedTags, userID); err != nil {
+		return nil, utils.Internal(err, "encryption error: failed to decrypt blog post tags")
+	}
+
+	// Format the author's name, avatar & created date
+	pageData.AvatarURL = utils.AvatarURL(pageData.Username, avatarUpdatedAt)
+	pageData.DisplayName = utils.DisplayName(pageData.DisplayName, pageData.Username)
 	pageData.Username = utils.CapitalizeFirstLetter(pageData.Username)
 	pageData.Post.CreatedAt = FormatDate(createdAt)
 
@@ -178,38 +402,38 @@ func GetBlogPostData(db *sql.DB, postID int, userID int, isLoggedIn bool) (*type
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		likesCount, likesErr = GetLikesCount(db, postID)
+		likesCount, likesErr = GetLikesCount(ctx, app, postID)
 	}()
 
 	if isLoggedIn {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			hasLiked, likedErr = HasUserLikedPost(db, postID, userID)
+			hasLiked, likedErr = HasUserLikedPost(ctx, app, postID, userID)
 		}()
 	}
 
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		comments, commentsErr = GetCommentsForBlogPost(db, postID)
+		comments, commentsErr = GetCommentsForBlogPost(ctx, app, postID, userID)
 	}()
 
 	wg.Wait()
 
 	if likesErr != nil {
-		log.Printf("Error fetching likes count for post %d: %v", postID, likesErr)
-		return nil, fmt.Errorf("database error: failed to retrieve likes count")
+		app.Logger.ErrorContext(ctx, "Error fetching likes count", "post_id", postID, "error", likesErr)
+		return nil, utils.DatabaseError(ctx, likesErr, "failed to retrieve likes count")
 	}
 
 	if isLoggedIn && likedErr != nil {
-		log.Printf("Error checking if user %d liked post %d: %v", userID, postID, likedErr)
-		return nil, fmt.Errorf("database error: failed to check like status")
+		app.Logger.ErrorContext(ctx, "Error checking if user liked post", "user_id", userID, "post_id", postID, "error", likedErr)
+		return nil, utils.DatabaseError(ctx, likedErr, "failed to check like status")
 	}
 
 	if commentsErr != nil {
-		log.Printf("Error fetching comments for post %d: %v", postID, commentsErr)
-		return nil, fmt.Errorf("database error: failed to retrieve comments")
+		app.Logger.ErrorContext(ctx, "Error fetching comments", "post_id", postID, "error", commentsErr)
+		return nil, utils.DatabaseError(ctx, commentsErr, "failed to retrieve comments")
 	}
 
 	// Final assignments after concurrent operations
@@ -220,55 +444,90 @@ func GetBlogPostData(db *sql.DB, postID int, userID int, isLoggedIn bool) (*type
 	return pageData, nil
 }
 
-func GetPostDataOnEdit(db *sql.DB, formData *types.BlogPostFormData, postID, userID int) error {
+func GetPostDataOnEdit(ctx context.Context, app *types.App, formData *types.BlogPostFormData, postID, userID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.GetPostDataOnEdit")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	var encryptedTags sql.NullString
+
 	// Execute SQL query to retrieve existing post data for edit page
-	if err := db.QueryRow(utils.SelectEditPostQuery, postID, userID).Scan(&formData.Title, &formData.Content, &formData.IsPublic); err != nil {
+	if err := app.Database.QueryRowContext(ctx, utils.SelectEditPostQuery, postID, userID).Scan(&formData.Title, &formData.Content, &formData.Visibility, &encryptedTags); err != nil {
 		if err == sql.ErrNoRows {
-			return fmt.Errorf("post not found or unauthorized")
+			return utils.NotFound("post not found")
 		}
 
-		return fmt.Errorf("database error occurred while accessing the post")
+		return utils.DatabaseError(ctx, err, "failed to access the post")
 	}
 
 	// Decrypt the content if needed
-	title, content, err := DecryptBlogPost(formData.Title, formData.Content, userID, formData.IsPublic)
+	title, content, err := DecryptBlogPost(app, formData.Title, formData.Content, userID, formData.Visibility)
 
 	if err != nil {
-		return fmt.Errorf("encryption error: failed to decrypt blog post title and content")
+		return utils.Internal(err, "encryption error: failed to decrypt blog post title and content")
 	}
 
 	// Assign decrypted/raw title and content to the form data
 	formData.Title = title
 	formData.Content = content
 
+	// Load the post's tags from the table or its encrypted column
+	if !IsEncryptedVisibility(formData.Visibility) {
+		tags, err := GetTagsForPosts(ctx, app, []int{postID})
+
+		if err != nil {
+			return err
+		}
+
+		formData.Tags = tags[postID]
+	} else if formData.Tags, err = DecryptTags(app, encryptedTags, userID); err != nil {
+		return utils.Internal(err, "encryption error: failed to decrypt blog post tags")
+	}
+
 	// Return nil if retrieving post data for edit was successful
 	return nil
 }
 
-func InsertCommentIntoDB(db *sql.DB, commentData *types.CreateComment) error {
+func InsertCommentIntoDB(ctx context.Context, app *types.App, commentData *types.CreateComment) error {
+	ctx, span := tracing.Start(ctx, "blogservice.InsertCommentIntoDB")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	// Execute the SQL query to insert a comment
-	if result, err := db.Exec(utils.InsertCommentQuery, commentData.PostID, commentData.UserID, commentData.Comment); err != nil {
-		log.Printf("SQL execution error while inserting comment: %v", err)
-		return fmt.Errorf("database error: failed to insert comment")
+	if result, err := app.Database.ExecContext(ctx, utils.InsertCommentQuery, commentData.UserID, commentData.Comment, commentData.PostID,
+		commentData.UserID, commentData.UserID, commentData.UserID, commentData.UserID, commentData.UserID, commentData.UserID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while inserting comment", "error", err)
+		return utils.DatabaseError(ctx, err, "failed to insert comment")
 
 	} else if rowsAffected, err := result.RowsAffected(); err != nil {
-		log.Printf("Error retrieving affected rows for comment insertion: %v", err)
-		return fmt.Errorf("database error: unable to confirm comment creation")
+		app.Logger.ErrorContext(ctx, "Error retrieving affected rows for comment insertion", "error", err)
+		return utils.DatabaseError(ctx, err, "unable to confirm comment creation")
 
 	} else if rowsAffected == 0 {
-		log.Printf("No rows affected while inserting comment. Comment data: %+v", commentData)
-		return fmt.Errorf("comment creation unsuccessful")
+		app.Logger.WarnContext(ctx, "No rows affected while inserting comment", "post_id", commentData.PostID, "user_id", commentData.UserID)
+		return utils.NotFound("post not found")
 	}
 
 	// Return nil if inserting comment into DB was successful
 	return nil
 }
 
-func GetCommentsForBlogPost(db *sql.DB, postID int) ([]*types.Comment, error) {
-	// Query to get comments for a post, joined with user table to get usernames
-	rows, err := db.Query(utils.SelectCommentsForPostQuery, postID)
+func GetCommentsForBlogPost(ctx context.Context, app *types.App, postID int, viewerID int) ([]*types.Comment, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetCommentsForBlogPost")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Query to get comments for a post, joined with user table to get usernames & skipping blocked users
+	rows, err := app.Database.QueryContext(ctx, utils.SelectCommentsForPostQuery, postID, viewerID, viewerID)
 	if err != nil {
-		return nil, fmt.Errorf("error querying comments for post: %d", postID)
+		app.Logger.ErrorContext(ctx, "SQL query error while retrieving comments", "post_id", postID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "error querying comments for post %d", postID)
 	}
 	defer rows.Close()
 
@@ -278,14 +537,17 @@ func GetCommentsForBlogPost(db *sql.DB, postID int) ([]*types.Comment, error) {
 		comment := &types.Comment{}
 		var createdAt []byte
 		var username string
+		var avatarUpdatedAt []byte
 
 		// Scan the row into the comment struct
-		if err := rows.Scan(&comment.ID, &comment.Content, &createdAt, &username); err != nil {
-			return nil, fmt.Errorf("error scanning comment for post: %d", postID)
+		if err := rows.Scan(&comment.ID, &comment.Content, &createdAt, &username, &comment.DisplayName, &avatarUpdatedAt); err != nil {
+			return nil, utils.DatabaseError(ctx, err, "error scanning comment for post %d", postID)
 		}
 
-		// Format the creation date
+		// Format the creation date & the commenter's name
 		comment.CreatedAt = FormatDate(createdAt)
+		comment.AvatarURL = utils.AvatarURL(username, avatarUpdatedAt)
+		comment.DisplayName = utils.DisplayName(comment.DisplayName, username)
 		comment.Username = utils.CapitalizeFirstLetter(username)
 
 		// Add comment to array of comments
@@ -293,22 +555,28 @@ func GetCommentsForBlogPost(db *sql.DB, postID int) ([]*types.Comment, error) {
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("error iterating comments for post: %d", postID)
+		return nil, utils.DatabaseError(ctx, err, "error iterating comments for post %d", postID)
 	}
 
 	return comments, nil
 }
 
-func ToggleLikeOnPost(db *sql.DB, postID int, userID int) (bool, error) {
+func ToggleLikeOnPost(ctx context.Context, app *types.App, postID int, userID int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.ToggleLikeOnPost")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	// Check if the user has already liked the post
 	var exists bool
-	err := db.QueryRow(utils.CheckUserLikedQuery, userID, postID).Scan(&exists)
+	err := app.Database.QueryRowContext(ctx, utils.CheckUserLikedQuery, userID, postID).Scan(&exists)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			exists = false
 		} else {
-			log.Printf("Error checking if user %d has liked post %d: %v", userID, postID, err)
-			return false, fmt.Errorf("database error: failed to check like status")
+			app.Logger.ErrorContext(ctx, "Error checking if user has liked post", "user_id", userID, "post_id", postID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to check like status")
 		}
 	}
 
@@ -316,149 +584,114 @@ func ToggleLikeOnPost(db *sql.DB, postID int, userID int) (bool, error) {
 
 	if !exists {
 		// If not liked, add a like
-		result, err = db.Exec(utils.InsertLikeQuery, userID, postID)
+		result, err = app.Database.ExecContext(ctx, utils.InsertLikeQuery, userID, postID, userID, userID, userID, userID, userID, userID)
 		if err != nil {
-			log.Printf("Error adding like for post %d by user %d: %v", postID, userID, err)
-			return false, fmt.Errorf("database error: failed to add like")
+			app.Logger.ErrorContext(ctx, "Error adding like", "post_id", postID, "user_id", userID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to add like")
 		}
 	} else {
 		// If already liked, remove the like
-		result, err = db.Exec(utils.DeleteLikeQuery, userID, postID)
+		result, err = app.Database.ExecContext(ctx, utils.DeleteLikeQuery, userID, postID)
 		if err != nil {
-			log.Printf("Error removing like for post %d by user %d: %v", postID, userID, err)
-			return false, fmt.Errorf("database error: failed to remove like")
+			app.Logger.ErrorContext(ctx, "Error removing like", "post_id", postID, "user_id", userID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to remove like")
 		}
 	}
 
 	// Validate that the operation affected rows
 	rowsAffected, err := result.RowsAffected()
 	if err != nil {
-		log.Printf("Error retrieving affected rows for like operation on post %d by user %d: %v", postID, userID, err)
-		return false, fmt.Errorf("database error: unable to confirm like operation")
+		app.Logger.ErrorContext(ctx, "Error retrieving affected rows for like operation", "post_id", postID, "user_id", userID, "error", err)
+		return false, utils.DatabaseError(ctx, err, "unable to confirm like operation")
 	}
 	if rowsAffected == 0 {
-		log.Printf("No rows affected during like operation for post %d by user %d", postID, userID)
-		return false, fmt.Errorf("like operation unsuccessful")
+		app.Logger.WarnContext(ctx, "No rows affected during like operation", "post_id", postID, "user_id", userID)
+		return false, utils.NotFound("post not found")
 	}
 
 	return !exists, nil
 }
 
-func GetLikesCount(db *sql.DB, postID int) (int, error) {
+func GetLikesCount(ctx context.Context, app *types.App, postID int) (int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetLikesCount")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	var count int
 
 	// Execute the SQL query to count likes for the post
-	if err := db.QueryRow(utils.CountLikesQuery, postID).Scan(&count); err != nil {
-		return 0, fmt.Errorf("error counting likes")
+	if err := app.Database.QueryRowContext(ctx, utils.CountLikesQuery, postID).Scan(&count); err != nil {
+		return 0, utils.DatabaseError(ctx, err, "error counting likes")
 	}
 
 	return count, nil
 }
 
-func HasUserLikedPost(db *sql.DB, postID, userID int) (bool, error) {
+func HasUserLikedPost(ctx context.Context, app *types.App, postID, userID int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.HasUserLikedPost")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
 	var exists bool
 
 	// Execute the SQL query to check if the user has liked the post
-	if err := db.QueryRow(utils.CheckUserLikedQuery, userID, postID).Scan(&exists); err != nil {
-		return false, fmt.Errorf("error checking like status")
+	if err := app.Database.QueryRowContext(ctx, utils.CheckUserLikedQuery, userID, postID).Scan(&exists); err != nil {
+		return false, utils.DatabaseError(ctx, err, "error checking like status")
 	}
 
 	return exists, nil
 }
 
-func ToggleFollowUser(db *sql.DB, followerID int, followingUsername string) error {
-	// Retrieve the user ID of the user being followed
-	var followingID int
-	err := db.QueryRow(utils.GetUserIDQuery, followingUsername).Scan(&followingID)
-	if err != nil {
-		if err == sql.ErrNoRows {
-			return fmt.Errorf("user with username '%s' not found", followingUsername)
-		}
-		log.Printf("Database error: Failed to retrieve user ID for username '%s': %v", followingUsername, err)
-		return fmt.Errorf("database error: Failed to retrieve user ID")
-	}
-
-	// Check if the user is already following the other user
-	var exists int
-	err = db.QueryRow(utils.CheckFollowQuery, followerID, followingID).Scan(&exists)
-	if err != nil {
-		log.Printf("Database error: Failed to check follow status for user %d -> %d: %v", followerID, followingID, err)
-		return fmt.Errorf("database error: Failed to check follow status")
-	}
+func GetHomeFeedPosts(ctx context.Context, app *types.App, userID int, page int, tag string, limit int) ([]*types.HomeFeedData, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetHomeFeedPosts")
+	defer span.End()
 
-	var result sql.Result
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
 
-	if exists == 0 {
-		// If not following, add a follow
-		result, err = db.Exec(utils.InsertFollowQuery, followerID, followingID)
-		if err != nil {
-			log.Printf("Database error: Failed to add follow for user %d -> %d: %v", followerID, followingID, err)
-			return fmt.Errorf("database error: Failed to add follow")
-		}
-	} else {
-		// If already following, remove the follow
-		result, err = db.Exec(utils.DeleteFollowQuery, followerID, followingID)
-		if err != nil {
-			log.Printf("Database error: Failed to remove follow for user %d -> %d: %v", followerID, followingID, err)
-			return fmt.Errorf("database error: Failed to remove follow")
-		}
-	}
+	// Execute the query to retrieve blog posts from user
+	offset := (page - 1) * limit
 
-	// Validate that the operation affected rows
-	rowsAffected, err := result.RowsAffected()
+	rows, err := app.Database.QueryContext(ctx, utils.SelectHomeFeedPostsQuery, userID, tag, tag, limit, offset)
 	if err != nil {
-		log.Printf("Database error: Failed to retrieve affected rows for follow operation: %v", err)
-		return fmt.Errorf("database error: unable to confirm follow operation")
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to query posts for user %d", userID)
 	}
-	if rowsAffected == 0 {
-		log.Printf("No rows affected during follow operation for user %d -> %d", followerID, followingID)
-		return fmt.Errorf("follow operation unsuccessful: no matching follow or unauthorized")
-	}
-
-	return nil
-}
 
-func IsFollowingUser(db *sql.DB, followerID int, followingUsername string) (bool, error) {
-	var followingID int
-
-	// Execute the SQL query to retrieve the user ID of the user being followed
-	db.QueryRow(utils.GetUserIDQuery, followingUsername).Scan(&followingID)
-
-	var exists int
+	var totalCount int
 
-	// Execute the SQL query to check if the user is following the other user
-	err := db.QueryRow(utils.CheckFollowQuery, followerID, followingID).Scan(&exists)
+	posts, err := scanFeedPosts(rows, &totalCount)
 
 	if err != nil {
-		log.Printf("Database error: Failed to check follow status for user %d -> %s: %v", followerID, followingUsername, err)
-		return false, fmt.Errorf("database error: Failed to check follow status")
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to read posts for user %d", userID)
 	}
 
-	// Return true if the user is following, false otherwise
-	return exists == 1, nil
-}
+	// Attach the tags of every post on the page
+	if err := attachFeedTags(ctx, app, posts); err != nil {
+		return nil, 0, err
+	}
 
-func GetHomeFeedPosts(db *sql.DB, userID int, page int) ([]*types.HomeFeedData, int, error) {
-	// Execute the query to retrieve blog posts from user
-	limit := utils.POST_LIMIT_PER_PAGE
-	offset := (page - 1) * limit
+	// Return arr of posts & null if successful
+	return posts, totalCount, nil
+}
 
-	rows, err := db.Query(utils.SelectHomeFeedPostsQuery, userID, limit, offset)
-	if err != nil {
-		return nil, 0, fmt.Errorf("error querying posts for user %d: %w", userID, err)
-	}
+func scanFeedPosts(rows *sql.Rows, extra ...any) ([]*types.HomeFeedData, error) {
 	defer rows.Close()
 
 	// Collect the results
 	var posts []*types.HomeFeedData
-	var totalCount int = 0
 	for rows.Next() {
 		post := &types.HomeFeedData{}
 		var createdAt []byte
 
-		// Scan the row into the post struct
-		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.IsPublic, &post.Username, &totalCount); err != nil {
-			return nil, 0, fmt.Errorf("error scanning post for user %d: %w", userID, err)
+		// Scan the row into the post struct & any extra columns requested by the caller
+		dest := append([]any{&post.ID, &post.Title, &post.Content, &createdAt, &post.Visibility, &post.Username, &post.DisplayName}, extra...)
+
+		if err := rows.Scan(dest...); err != nil {
+			return nil, fmt.Errorf("error scanning post: %w", err)
 		}
 
 		// Limit content length to 100 characters and add dots
@@ -466,19 +699,21 @@ func GetHomeFeedPosts(db *sql.DB, userID int, page int) ([]*types.HomeFeedData,
 			post.Content = post.Content[:100] + utils.DOTS_STRING
 		}
 
+		post.DisplayName = utils.DisplayName(post.DisplayName, post.Username)
 		post.Username = utils.CapitalizeFirstLetter(post.Username)
 
-		// Format the creation date for the UI
+		// Format the creation date for the UI & remember its position for cursors
 		post.CreatedAt = FormatDate(createdAt)
+		post.PublishedAt = ParseDate(createdAt)
+		post.Cursor = EncodeCursor(createdAt, post.ID)
 
 		// Add posts to array of posts
 		posts = append(posts, post)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, 0, fmt.Errorf("error iterating posts for user %d: %w", userID, err)
+		return nil, fmt.Errorf("error iterating posts: %w", err)
 	}
 
-	// Return arr of posts & null if successful
-	return posts, totalCount, nil
+	return posts, nil
 }
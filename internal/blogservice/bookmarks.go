@@ -0,0 +1,299 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+func SaveBookmark(ctx context.Context, app *types.App, userID, postID, collectionID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.SaveBookmark")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	var bookmarkable bool
+
+	// Only posts the user can read, and that aren't private, can be saved
+	if err := app.Database.QueryRowContext(ctx, utils.SelectBookmarkablePostQuery, postID, userID, userID, userID, userID, userID, userID).Scan(&bookmarkable); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while checking post for bookmarking", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save post")
+	}
+
+	if !bookmarkable {
+		return utils.NotFound("post not found")
+	}
+
+	// Zero means the bookmark isn't in any collection
+	var collection any
+
+	if collectionID != 0 {
+		if err := checkCollectionOwner(ctx, app, collectionID, userID); err != nil {
+			return err
+		}
+
+		collection = collectionID
+	}
+
+	if _, err := app.Database.ExecContext(ctx, utils.UpsertBookmarkQuery, userID, postID, collection, collection); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while saving bookmark", "post_id", postID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save post")
+	}
+
+	return nil
+}
+
+func RemoveBookmark(ctx context.Context, app *types.App, userID, postID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.RemoveBookmark")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	result, err := app.Database.ExecContext(ctx, utils.DeleteBookmarkQuery, userID, postID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while removing bookmark", "post_id", postID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to remove bookmark")
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return utils.NotFound("bookmark not found")
+	}
+
+	return nil
+}
+
+func GetBookmark(ctx context.Context, app *types.App, userID, postID int) (bool, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetBookmark")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	var collectionID int
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectBookmarkQuery, userID, postID).Scan(&collectionID); err != nil {
+		if err == sql.ErrNoRows {
+			return false, 0, nil
+		}
+
+		app.Logger.ErrorContext(ctx, "SQL query error while loading bookmark", "post_id", postID, "user_id", userID, "error", err)
+		return false, 0, utils.DatabaseError(ctx, err, "failed to check bookmark")
+	}
+
+	return true, collectionID, nil
+}
+
+func GetSavedPosts(ctx context.Context, app *types.App, userID, collectionID, page, limit int) ([]*types.SavedPost, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetSavedPosts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Calculate pagination offset based on the page size
+	offset := (page - 1) * limit
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectSavedPostsQuery, userID, collectionID, collectionID, limit, offset)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading saved posts", "user_id", userID, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to load saved posts")
+	}
+
+	defer rows.Close()
+
+	var posts []*types.SavedPost
+	var totalCount int
+
+	for rows.Next() {
+		post := &types.SavedPost{}
+		var createdAt, savedAt []byte
+
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.Visibility,
+			&post.Username, &post.DisplayName, &savedAt, &post.Collection, &totalCount); err != nil {
+			app.Logger.ErrorContext(ctx, "Error scanning saved post", "user_id", userID, "error", err)
+			return nil, 0, utils.DatabaseError(ctx, err, "failed to load saved posts")
+		}
+
+		// Limit content length for the preview & format the author's name
+		post.Content = TruncateString(post.Content, utils.BLOG_POST_PREVIEW_LENGTH)
+		post.DisplayName = utils.DisplayName(post.DisplayName, post.Username)
+		post.Username = utils.CapitalizeFirstLetter(post.Username)
+		post.CreatedAt = FormatDate(createdAt)
+		post.SavedAt = FormatDate(savedAt)
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating saved posts", "user_id", userID, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to load saved posts")
+	}
+
+	// Attach the tags of every post on the page
+	feedPosts := make([]*types.HomeFeedData, len(posts))
+
+	for i, post := range posts {
+		feedPosts[i] = &post.HomeFeedData
+	}
+
+	if err := attachFeedTags(ctx, app, feedPosts); err != nil {
+		return nil, 0, err
+	}
+
+	return posts, totalCount, nil
+}
+
+func GetBookmarkCollections(ctx context.Context, app *types.App, userID int) ([]*types.BookmarkCollection, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetBookmarkCollections")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectBookmarkCollectionsQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading collections", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load collections")
+	}
+
+	defer rows.Close()
+
+	var collections []*types.BookmarkCollection
+
+	for rows.Next() {
+		collection := &types.BookmarkCollection{}
+
+		if err := rows.Scan(&collection.ID, &collection.Name); err != nil {
+			app.Logger.ErrorContext(ctx, "Error scanning collection", "user_id", userID, "error", err)
+			return nil, utils.DatabaseError(ctx, err, "failed to load collections")
+		}
+
+		collections = append(collections, collection)
+	}
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating collections", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load collections")
+	}
+
+	return collections, nil
+}
+
+func CreateBookmarkCollection(ctx context.Context, app *types.App, userID int, name string) (*types.BookmarkCollection, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.CreateBookmarkCollection")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Collapse runs of spaces so "Read  later" & "Read later" are the same collection
+	name = strings.Join(strings.Fields(name), " ")
+
+	if name == "" || utf8.RuneCountInString(name) > utils.BOOKMARK_COLLECTION_MAX_LENGTH {
+		return nil, utils.Validation("collection names must be between 1 and %d characters", utils.BOOKMARK_COLLECTION_MAX_LENGTH)
+	}
+
+	var count int
+
+	if err := app.Database.QueryRowContext(ctx, utils.CountBookmarkCollectionsQuery, userID).Scan(&count); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while counting collections", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to create collection")
+	}
+
+	if count >= utils.BOOKMARK_COLLECTIONS_MAX {
+		return nil, utils.Validation("you can have at most %d collections", utils.BOOKMARK_COLLECTIONS_MAX)
+	}
+
+	var exists bool
+
+	if err := app.Database.QueryRowContext(ctx, utils.CheckBookmarkCollectionNameQuery, userID, name).Scan(&exists); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while checking collection name", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to create collection")
+	}
+
+	if exists {
+		return nil, utils.Conflict("you already have a collection called %q", name)
+	}
+
+	result, err := app.Database.ExecContext(ctx, utils.InsertBookmarkCollectionQuery, userID, name)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while creating collection", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to create collection")
+	}
+
+	id, err := result.LastInsertId()
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to read new collection ID", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to create collection")
+	}
+
+	return &types.BookmarkCollection{ID: int(id), Name: name}, nil
+}
+
+func DeleteBookmarkCollection(ctx context.Context, app *types.App, userID, collectionID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.DeleteBookmarkCollection")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for collection deletion", "collection_id", collectionID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete collection")
+	}
+
+	defer tx.Rollback()
+
+	// Keep the bookmarks, they just stop belonging to a collection
+	if _, err := tx.ExecContext(ctx, utils.ClearBookmarkCollectionQuery, collectionID, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while emptying collection", "collection_id", collectionID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete collection")
+	}
+
+	result, err := tx.ExecContext(ctx, utils.DeleteBookmarkCollectionQuery, collectionID, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while deleting collection", "collection_id", collectionID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete collection")
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return utils.NotFound("collection not found")
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit collection deletion", "collection_id", collectionID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete collection")
+	}
+
+	return nil
+}
+
+func checkCollectionOwner(ctx context.Context, app *types.App, collectionID, userID int) error {
+	var owned bool
+
+	if err := app.Database.QueryRowContext(ctx, utils.CheckBookmarkCollectionOwnerQuery, collectionID, userID).Scan(&owned); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while checking collection", "collection_id", collectionID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to check collection")
+	}
+
+	if !owned {
+		return utils.NotFound("collection not found")
+	}
+
+	return nil
+}
@@ -0,0 +1,210 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func GetLatestPublicPosts(ctx context.Context, app *types.App, limit int) ([]*types.HomeFeedData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetLatestPublicPosts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Execute the query to retrieve the newest public posts across every user
+	rows, err := app.Database.QueryContext(ctx, utils.SelectLatestPublicPostsQuery, limit)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying latest public posts", "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	posts, err := scanFeedPosts(rows)
+
+	if err != nil {
+		return nil, utils.DatabaseError(ctx, err, "failed to read latest public posts")
+	}
+
+	// Attach the tags of every post in the feed
+	if err := attachFeedTags(ctx, app, posts); err != nil {
+		return nil, err
+	}
+
+	return posts, nil
+}
+
+func BuildUserFeed(ctx context.Context, app *types.App, baseURL string, username string, format string) (*types.FeedData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.BuildUserFeed")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Use an anonymous viewer so only public posts are ever returned
+	posts, _, err := GetBlogPostsByUser(ctx, app, username, false, 1, 0, "", utils.FEED_MAX_ITEMS)
+
+	if err != nil {
+		return nil, err
+	}
+
+	author := utils.CapitalizeFirstLetter(username)
+
+	feed := &types.FeedData{
+		Title:       fmt.Sprintf("%s on %s", author, utils.SITE_NAME),
+		Description: fmt.Sprintf("The latest public posts from %s", author),
+		Link:        baseURL + "/profile/" + username,
+		SelfLink:    baseURL + "/profile/" + username + "/" + format,
+	}
+
+	for _, post := range posts {
+		// Guard against a restricted post ever slipping into a public feed
+		if post.Visibility != utils.VISIBILITY_PUBLIC {
+			continue
+		}
+
+		feed.Items = append(feed.Items, newFeedItem(baseURL, &post.BlogPostBase, post.ID, author, post.PublishedAt))
+	}
+
+	return feed, nil
+}
+
+func BuildPublicFeed(ctx context.Context, app *types.App, baseURL string, format string) (*types.FeedData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.BuildPublicFeed")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	posts, err := GetLatestPublicPosts(ctx, app, utils.FEED_MAX_ITEMS)
+
+	if err != nil {
+		return nil, err
+	}
+
+	feed := &types.FeedData{
+		Title:       utils.SITE_NAME,
+		Description: fmt.Sprintf("The latest public posts on %s", utils.SITE_NAME),
+		Link:        baseURL + "/explore",
+		SelfLink:    baseURL + "/" + format,
+	}
+
+	for _, post := range posts {
+		feed.Items = append(feed.Items, newFeedItem(baseURL, &post.BlogPostBase, post.ID, post.Username, post.PublishedAt))
+	}
+
+	return feed, nil
+}
+
+func newFeedItem(baseURL string, post *types.BlogPostBase, postID int, author string, publishedAt time.Time) *types.FeedItem {
+	return &types.FeedItem{
+		ID:          postID,
+		Title:       post.Title,
+		Summary:     post.Content,
+		Link:        baseURL + "/blogpost/" + strconv.Itoa(postID),
+		Author:      author,
+		Tags:        post.Tags,
+		PublishedAt: publishedAt,
+	}
+}
+
+func FeedLastModified(feed *types.FeedData) time.Time {
+	// Feeds are ordered newest first, but don't rely on it
+	var lastModified time.Time
+
+	for _, item := range feed.Items {
+		if item.PublishedAt.After(lastModified) {
+			lastModified = item.PublishedAt
+		}
+	}
+
+	return lastModified
+}
+
+func RenderRSS(feed *types.FeedData) ([]byte, error) {
+	channel := types.RSSChannel{
+		Title:       feed.Title,
+		Link:        feed.Link,
+		Description: feed.Description,
+		AtomLink:    types.AtomLink{Href: feed.SelfLink, Rel: "self", Type: "application/rss+xml"},
+	}
+
+	if lastModified := FeedLastModified(feed); !lastModified.IsZero() {
+		channel.LastBuildDate = lastModified.Format(time.RFC1123Z)
+	}
+
+	for _, item := range feed.Items {
+		channel.Items = append(channel.Items, &types.RSSItem{
+			Title:       item.Title,
+			Link:        item.Link,
+			GUID:        types.RSSGUID{IsPermaLink: true, Value: item.Link},
+			Description: item.Summary,
+			Author:      item.Author,
+			Categories:  item.Tags,
+			PubDate:     item.PublishedAt.Format(time.RFC1123Z),
+		})
+	}
+
+	return marshalFeed(&types.RSS{
+		Version: "2.0",
+		AtomNS:  "http://www.w3.org/2005/Atom",
+		DCNS:    "http://purl.org/dc/elements/1.1/",
+		Channel: channel,
+	})
+}
+
+func RenderAtom(feed *types.FeedData) ([]byte, error) {
+	// Atom requires an updated date even when the feed is empty
+	updated := FeedLastModified(feed)
+
+	if updated.IsZero() {
+		updated = time.Unix(0, 0)
+	}
+
+	atom := &types.AtomFeed{
+		Title:    feed.Title,
+		Subtitle: feed.Description,
+		ID:       feed.Link,
+		Updated:  updated.UTC().Format(time.RFC3339),
+		Links: []types.AtomLink{
+			{Href: feed.Link, Rel: "alternate", Type: "text/html"},
+			{Href: feed.SelfLink, Rel: "self", Type: "application/atom+xml"},
+		},
+	}
+
+	for _, item := range feed.Items {
+		entry := &types.AtomEntry{
+			Title:     item.Title,
+			ID:        item.Link,
+			Link:      types.AtomLink{Href: item.Link, Rel: "alternate", Type: "text/html"},
+			Published: item.PublishedAt.UTC().Format(time.RFC3339),
+			Updated:   item.PublishedAt.UTC().Format(time.RFC3339),
+			Author:    types.AtomAuthor{Name: item.Author},
+			Summary:   item.Summary,
+		}
+
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, types.AtomCategory{Term: tag})
+		}
+
+		atom.Entries = append(atom.Entries, entry)
+	}
+
+	return marshalFeed(atom)
+}
+
+func marshalFeed(feed any) ([]byte, error) {
+	body, err := xml.MarshalIndent(feed, "", "  ")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to build feed: %w", err)
+	}
+
+	return append([]byte(xml.Header), body...), nil
+}
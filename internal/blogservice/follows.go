@@ -0,0 +1,324 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"database/sql"
+	"time"
+)
+
+var followListQueries = map[string]string{
+	utils.FOLLOWERS_LIST: utils.SelectFollowersQuery,
+	utils.FOLLOWING_LIST: utils.SelectFollowingQuery,
+}
+
+func GetUserRelationship(ctx context.Context, app *types.App, viewerID int, username string) (*types.UserRelationship, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetUserRelationship")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	relationship := &types.UserRelationship{}
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectUserRelationshipQuery, viewerID, viewerID, viewerID, viewerID, viewerID, viewerID, username).Scan(
+		&relationship.UserID, &relationship.IsPrivate, &relationship.IsFollowing, &relationship.IsRequested,
+		&relationship.IsBlocked, &relationship.IsBlockedBy, &relationship.IsMuted, &relationship.FollowsYou,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("user not found")
+		}
+
+		app.Logger.ErrorContext(ctx, "Database error: Failed to load relationship", "viewer_id", viewerID, "username", username, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "Failed to check follow status")
+	}
+
+	return relationship, nil
+}
+
+func ToggleFollowUser(ctx context.Context, app *types.App, followerID int, followingUsername string) (string, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.ToggleFollowUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	relationship, err := GetUserRelationship(ctx, app, followerID, followingUsername)
+
+	if err != nil {
+		return "", err
+	}
+
+	if relationship.UserID == followerID {
+		return "", utils.Validation("you can't follow yourself")
+	}
+
+	// Blocking either way ends the follow & stops a new one
+	if relationship.IsBlocked || relationship.IsBlockedBy {
+		return "", utils.Forbidden("you can't follow this user")
+	}
+
+	followingID := relationship.UserID
+
+	switch {
+	case relationship.IsFollowing:
+		// If already following, remove the follow
+		if _, err := app.Database.ExecContext(ctx, utils.DeleteFollowQuery, followerID, followingID); err != nil {
+			app.Logger.ErrorContext(ctx, "Database error: Failed to remove follow", "follower_id", followerID, "following_id", followingID, "error", err)
+			return "", utils.DatabaseError(ctx, err, "Failed to remove follow")
+		}
+
+		return utils.FOLLOW_STATUS_NONE, nil
+
+	case relationship.IsRequested:
+		// A second click withdraws a request that is still waiting
+		if _, err := app.Database.ExecContext(ctx, utils.DeleteFollowRequestQuery, followerID, followingID); err != nil {
+			app.Logger.ErrorContext(ctx, "Database error: Failed to withdraw follow request", "follower_id", followerID, "following_id", followingID, "error", err)
+			return "", utils.DatabaseError(ctx, err, "Failed to withdraw follow request")
+		}
+
+		return utils.FOLLOW_STATUS_NONE, nil
+
+	case relationship.IsPrivate:
+		// Private accounts approve their followers first
+		if _, err := app.Database.ExecContext(ctx, utils.InsertFollowRequestQuery, followerID, followingID); err != nil {
+			app.Logger.ErrorContext(ctx, "Database error: Failed to request follow", "follower_id", followerID, "following_id", followingID, "error", err)
+			return "", utils.DatabaseError(ctx, err, "Failed to request follow")
+		}
+
+		return utils.FOLLOW_STATUS_PENDING, nil
+	}
+
+	// If not following, add a follow
+	if _, err := app.Database.ExecContext(ctx, utils.InsertFollowQuery, followerID, followingID); err != nil {
+		app.Logger.ErrorContext(ctx, "Database error: Failed to add follow", "follower_id", followerID, "following_id", followingID, "error", err)
+		return "", utils.DatabaseError(ctx, err, "Failed to add follow")
+	}
+
+	return utils.FOLLOW_STATUS_ACTIVE, nil
+}
+
+func GetFollowRequests(ctx context.Context, app *types.App, userID int, page int, limit int) ([]*types.FollowUser, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetFollowRequests")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Calculate pagination offset based on the page size
+	offset := (page - 1) * limit
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectFollowRequestsQuery, userID, limit, offset)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading follow requests", "user_id", userID, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to load follow requests")
+	}
+
+	return scanFollowUsers(ctx, app, rows)
+}
+
+func RespondToFollowRequest(ctx context.Context, app *types.App, userID int, requesterUsername string, approve bool) error {
+	ctx, span := tracing.Start(ctx, "blogservice.RespondToFollowRequest")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	var requesterID int
+
+	if err := app.Database.QueryRowContext(ctx, utils.GetUserIDQuery, requesterUsername).Scan(&requesterID); err != nil {
+		return utils.NotFound("follow request not found")
+	}
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for follow request", "requester_id", requesterID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to answer follow request")
+	}
+
+	defer tx.Rollback()
+
+	// Removing the request first makes sure it was really waiting on this user
+	result, err := tx.ExecContext(ctx, utils.DeleteFollowRequestQuery, requesterID, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while removing follow request", "requester_id", requesterID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to answer follow request")
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return utils.NotFound("follow request not found")
+	}
+
+	if approve {
+		if _, err := tx.ExecContext(ctx, utils.InsertFollowQuery, requesterID, userID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while approving follow request", "requester_id", requesterID, "user_id", userID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to approve follow request")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit follow request", "requester_id", requesterID, "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to answer follow request")
+	}
+
+	return nil
+}
+
+func ToggleBlockUser(ctx context.Context, app *types.App, blockerID int, username string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.ToggleBlockUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	relationship, err := GetUserRelationship(ctx, app, blockerID, username)
+
+	if err != nil {
+		return false, err
+	}
+
+	if relationship.UserID == blockerID {
+		return false, utils.Validation("you can't block yourself")
+	}
+
+	blockedID := relationship.UserID
+
+	if relationship.IsBlocked {
+		if _, err := app.Database.ExecContext(ctx, utils.DeleteBlockQuery, blockerID, blockedID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while unblocking user", "blocker_id", blockerID, "blocked_id", blockedID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to unblock user")
+		}
+
+		return false, nil
+	}
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for block", "blocker_id", blockerID, "blocked_id", blockedID, "error", err)
+		return false, utils.DatabaseError(ctx, err, "failed to block user")
+	}
+
+	defer tx.Rollback()
+
+	// The block also ends follows & pending requests in both directions
+	steps := []struct {
+		query string
+		args  []any
+	}{
+		{utils.InsertBlockQuery, []any{blockerID, blockedID}},
+		{utils.DeleteFollowsBetweenQuery, []any{blockerID, blockedID, blockedID, blockerID}},
+		{utils.DeleteFollowRequestsBetweenQuery, []any{blockerID, blockedID, blockedID, blockerID}},
+	}
+
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while blocking user", "blocker_id", blockerID, "blocked_id", blockedID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to block user")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit block", "blocker_id", blockerID, "blocked_id", blockedID, "error", err)
+		return false, utils.DatabaseError(ctx, err, "failed to block user")
+	}
+
+	return true, nil
+}
+
+func ToggleMuteUser(ctx context.Context, app *types.App, muterID int, username string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.ToggleMuteUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	relationship, err := GetUserRelationship(ctx, app, muterID, username)
+
+	if err != nil {
+		return false, err
+	}
+
+	if relationship.UserID == muterID {
+		return false, utils.Validation("you can't mute yourself")
+	}
+
+	if relationship.IsMuted {
+		if _, err := app.Database.ExecContext(ctx, utils.DeleteMuteQuery, muterID, relationship.UserID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while unmuting user", "muter_id", muterID, "user_id", relationship.UserID, "error", err)
+			return false, utils.DatabaseError(ctx, err, "failed to unmute user")
+		}
+
+		return false, nil
+	}
+
+	if _, err := app.Database.ExecContext(ctx, utils.InsertMuteQuery, muterID, relationship.UserID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while muting user", "muter_id", muterID, "user_id", relationship.UserID, "error", err)
+		return false, utils.DatabaseError(ctx, err, "failed to mute user")
+	}
+
+	return true, nil
+}
+
+func GetFollowList(ctx context.Context, app *types.App, userID int, list string, viewerID int, page int, limit int) ([]*types.FollowUser, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetFollowList")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	query, ok := followListQueries[list]
+
+	if !ok {
+		return nil, 0, utils.Validation("invalid follow list")
+	}
+
+	// Calculate pagination offset based on the page size
+	offset := (page - 1) * limit
+
+	rows, err := app.Database.QueryContext(ctx, query, viewerID, viewerID, userID, viewerID, viewerID, limit, offset)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading follow list", "list", list, "user_id", userID, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to load %s", list)
+	}
+
+	return scanFollowUsers(ctx, app, rows)
+}
+
+func scanFollowUsers(ctx context.Context, app *types.App, rows *sql.Rows) ([]*types.FollowUser, int, error) {
+	defer rows.Close()
+
+	var users []*types.FollowUser
+	var totalCount int
+
+	for rows.Next() {
+		user := &types.FollowUser{}
+		var avatarUpdatedAt, since []byte
+
+		if err := rows.Scan(&user.Username, &user.DisplayName, &avatarUpdatedAt, &since, &user.FollowsYou, &user.IsFollowing, &totalCount); err != nil {
+			app.Logger.ErrorContext(ctx, "Error scanning follow list", "error", err)
+			return nil, 0, utils.DatabaseError(ctx, err, "failed to load users")
+		}
+
+		// Format the user's name, avatar & the date they followed
+		user.AvatarURL = utils.AvatarURL(user.Username, avatarUpdatedAt)
+		user.DisplayName = utils.DisplayName(user.DisplayName, user.Username)
+		user.Username = utils.CapitalizeFirstLetter(user.Username)
+		user.Since = FormatDate(since)
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating follow list", "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to load users")
+	}
+
+	return users, totalCount, nil
+}
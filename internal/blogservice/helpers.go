@@ -1,14 +1,13 @@
 package blogservice
 
 import (
-	"App/internal/cache"
+	"App/internal/types"
 	"App/internal/utils"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
 	"encoding/base64"
 	"fmt"
-	"log"
 	"strconv"
 	"time"
 
@@ -29,7 +28,7 @@ func GetPostIDAndMode(context *gin.Context) (int, bool, error) {
 		return id, true, nil
 	}
 
-	return 0, false, fmt.Errorf("invalid post ID")
+	return 0, false, utils.Validation("invalid post ID")
 }
 
 func GetPageQuery(ctx *gin.Context) int {
@@ -52,44 +51,46 @@ func ValidatePostIDInput(context *gin.Context) (int, error) {
 	id, isValidID := IsValidPostID(context.Param(utils.ID))
 
 	if !isValidID {
-		return id, fmt.Errorf("invalid post ID")
+		return id, utils.Validation("invalid post ID")
 	}
 
 	// Return post ID if valid
 	return id, nil
 }
 
-func ValidatePostInputs(title string, isPublic string, content string) error {
-	// Validate public status (isPublic)
-	if !IsValidPriority(isPublic) {
-		return fmt.Errorf("invalid public status: must be 'true' or 'false'")
+func ValidatePostInputs(title string, visibility string, content string) error {
+	// Validate the visibility option
+	if !IsValidVisibility(visibility) {
+		return utils.Validation("invalid visibility: must be 'public', 'unlisted', 'followers' or 'private'")
 	}
 
 	// Validate title length
 	if !utils.IsValidInputLength(title, utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH) {
-		log.Printf("invalid title length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH)
-		return fmt.Errorf("invalid title length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH)
+		return utils.Validation("invalid title length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH)
 	}
 
 	// Validate content length
 	if !utils.IsValidInputLength(content, utils.BLOG_POST_MIN_LENGTH, utils.BLOG_CONTENT_MAX_LENGTH) {
-		return fmt.Errorf("invalid content length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_CONTENT_MAX_LENGTH)
+		return utils.Validation("invalid content length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_CONTENT_MAX_LENGTH)
 	}
 
 	// Return nil if all validations pass
 	return nil
 }
 
-func ConvertIsPublicToBool(isPublic string) bool {
-	// Convert IsPublic string to bool & return
-	isPublicBool, _ := strconv.ParseBool(isPublic)
+func IsValidVisibility(visibility string) bool {
+	// Check if the visibility is one of the supported options
+	switch visibility {
+	case utils.VISIBILITY_PUBLIC, utils.VISIBILITY_UNLISTED, utils.VISIBILITY_FOLLOWERS, utils.VISIBILITY_PRIVATE:
+		return true
+	}
 
-	return isPublicBool
+	return false
 }
 
-func IsValidPriority(isPublic string) bool {
-	// Check if isPublic string is valid
-	return isPublic == "true" || isPublic == "false"
+func IsEncryptedVisibility(visibility string) bool {
+	// Only private posts are encrypted, followers never hold the owner's key
+	return visibility == utils.VISIBILITY_PRIVATE
 }
 
 func IsValidPostID(postID string) (int, bool) {
@@ -98,7 +99,6 @@ func IsValidPostID(postID string) (int, bool) {
 
 	// Check if ID is clean
 	if err != nil || id <= 0 {
-		log.Printf("Invalid post ID: %s", postID)
 		return -1, false
 	}
 
@@ -108,7 +108,7 @@ func IsValidPostID(postID string) (int, bool) {
 
 func IsValidComment(comment string) error {
 	if !utils.IsValidInputLength(comment, utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH) {
-		return fmt.Errorf("invalid comment length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH)
+		return utils.Validation("invalid comment length: must be between %d and %d", utils.BLOG_POST_MIN_LENGTH, utils.BLOG_TITLE_MAX_LENGTH)
 	}
 
 	return nil
@@ -123,7 +123,6 @@ func FormatDate(createdAt []byte) string {
 	timeUTC, err := time.Parse("2006-01-02 15:04:05", string(createdAt))
 
 	if err != nil {
-		log.Printf("Failed to parse time: %v", err)
 		return ""
 	}
 
@@ -131,7 +130,6 @@ func FormatDate(createdAt []byte) string {
 	loc, err := time.LoadLocation("America/New_York")
 
 	if err != nil {
-		log.Printf("Failed to load location: %v", err)
 		return ""
 	}
 
@@ -142,25 +140,36 @@ func FormatDate(createdAt []byte) string {
 	return timeEST.Format("January 2, 2006 03:04 PM")
 }
 
-func EncryptBlogPost(title string, content string, userID int, isPublic bool) (string, string, error) {
-	// If post is public, return title and content as is
-	if isPublic {
+func ParseDate(createdAt []byte) time.Time {
+	// Stored dates are UTC, a zero time means the date was unreadable
+	timeUTC, err := time.Parse(dbTimeLayout, string(createdAt))
+
+	if err != nil {
+		return time.Time{}
+	}
+
+	return timeUTC
+}
+
+func EncryptBlogPost(app *types.App, title string, content string, userID int, visibility string) (string, string, error) {
+	// If post isn't private, return title and content as is
+	if !IsEncryptedVisibility(visibility) {
 		return title, content, nil
 	}
 
 	// If post is private, encrypt the title or leave raw
-	encryptedTitle, err := encryptContent(title, userID, isPublic)
+	encryptedTitle, err := encryptContent(app, title, userID, false)
 
 	if err != nil {
-		log.Printf("Failed to encrypt title: %v", err)
+		app.Logger.Error("Failed to encrypt title", "error", err)
 		return "", "", fmt.Errorf("failed to encrypt title")
 	}
 
 	// If post is private, encrypt the content or leave raw
-	encyptedContent, err := encryptContent(content, userID, isPublic)
+	encyptedContent, err := encryptContent(app, content, userID, false)
 
 	if err != nil {
-		log.Printf("Failed to encrypt content: %v", err)
+		app.Logger.Error("Failed to encrypt content", "error", err)
 		return "", "", fmt.Errorf("failed to encrypt content")
 	}
 
@@ -168,25 +177,25 @@ func EncryptBlogPost(title string, content string, userID int, isPublic bool) (s
 	return encryptedTitle, encyptedContent, nil
 }
 
-func DecryptBlogPost(title string, content string, userID int, isPublic bool) (string, string, error) {
-	// If post is public, return title and content as is
-	if isPublic {
+func DecryptBlogPost(app *types.App, title string, content string, userID int, visibility string) (string, string, error) {
+	// If post isn't private, return title and content as is
+	if !IsEncryptedVisibility(visibility) {
 		return title, content, nil
 	}
 
 	// If post is private, decrypt the title or leave raw
-	decryptedTitle, err := decryptContent(title, userID, isPublic)
+	decryptedTitle, err := decryptContent(app, title, userID, false)
 
 	if err != nil {
-		log.Printf("Failed to decrypt title: %v", err)
+		app.Logger.Error("Failed to decrypt title", "error", err)
 		return "", "", fmt.Errorf("failed to decrypt title")
 	}
 
 	// If post is private, decrypt the content or leave raw
-	decryptedContent, err := decryptContent(content, userID, isPublic)
+	decryptedContent, err := decryptContent(app, content, userID, false)
 
 	if err != nil {
-		log.Printf("Failed to decrypt content: %v", err)
+		app.Logger.Error("Failed to decrypt content", "error", err)
 		return "", "", fmt.Errorf("failed to decrypt content")
 	}
 
@@ -194,37 +203,39 @@ func DecryptBlogPost(title string, content string, userID int, isPublic bool) (s
 	return decryptedTitle, decryptedContent, nil
 }
 
-func encryptContent(data string, userID int, isPublic bool) (string, error) {
+func encryptContent(app *types.App, data string, userID int, isPublic bool) (string, error) {
 	// Get the user's encryption key
-	key, err := cache.GetUserKey(userID)
+	key, err := app.KeyCache.Get(userID)
 
 	if err != nil {
-		log.Printf("Failed to retrieve user key: %v", err)
-		return "", fmt.Errorf("failed to retrieve user key")
+		return "", fmt.Errorf("failed to retrieve user key: %w", err)
 	}
 
+	return encryptWithKey(app, data, key)
+}
+
+func encryptWithKey(app *types.App, data string, key []byte) (string, error) {
+	defer app.Metrics.ObserveCrypto(utils.CRYPTO_POST_ENCRYPT, time.Now())
+
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key)
 
 	if err != nil {
-		log.Printf("Failed to create AES cipher: %v", err)
-		return "", fmt.Errorf("failed to create AES cipher")
+		return "", fmt.Errorf("failed to create AES cipher: %w", err)
 	}
 
 	// Create a GCM instance
 	gcm, err := cipher.NewGCM(block)
 
 	if err != nil {
-		log.Printf("Failed to create GCM: %v", err)
-		return "", fmt.Errorf("failed to create GCM")
+		return "", fmt.Errorf("failed to create GCM: %w", err)
 	}
 
 	// Generate a random nonce
 	nonce := make([]byte, gcm.NonceSize())
 
 	if _, err := rand.Read(nonce); err != nil {
-		log.Printf("Failed to generate nonce: %v", err)
-		return "", fmt.Errorf("failed to generate nonce")
+		return "", fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
 	// Encrypt the content
@@ -234,43 +245,44 @@ func encryptContent(data string, userID int, isPublic bool) (string, error) {
 	return base64.StdEncoding.EncodeToString(ciphertext), nil
 }
 
-func decryptContent(content string, userID int, isPublic bool) (string, error) {
-	// Decode the base64 string back to bytes
-	ciphertext, err := base64.StdEncoding.DecodeString(content)
+func decryptContent(app *types.App, content string, userID int, isPublic bool) (string, error) {
+	// Get the user's encryption key
+	key, err := app.KeyCache.Get(userID)
 
 	if err != nil {
-		log.Printf("Failed to decode base64 content: %v", err)
-		return "", fmt.Errorf("failed to decode encrypted content")
+		return "", fmt.Errorf("failed to retrieve user key: %w", err)
 	}
 
-	// Get the user's encryption key
-	key, err := cache.GetUserKey(userID)
+	return decryptWithKey(app, content, key)
+}
+
+func decryptWithKey(app *types.App, content string, key []byte) (string, error) {
+	defer app.Metrics.ObserveCrypto(utils.CRYPTO_POST_DECRYPT, time.Now())
+
+	// Decode the base64 string back to bytes
+	ciphertext, err := base64.StdEncoding.DecodeString(content)
 
 	if err != nil {
-		log.Printf("Failed to retrieve user key: %v", err)
-		return "", fmt.Errorf("failed to retrieve user key")
+		return "", fmt.Errorf("failed to decode encrypted content: %w", err)
 	}
 
 	// Create a new AES cipher block
 	block, err := aes.NewCipher(key)
 
 	if err != nil {
-		log.Printf("Failed to create AES cipher: %v", err)
-		return "", fmt.Errorf("failed to create AES cipher")
+		return "", fmt.Errorf("failed to create AES cipher: %w", err)
 	}
 
 	// Create a GCM instance
 	gcm, err := cipher.NewGCM(block)
 
 	if err != nil {
-		log.Printf("Failed to create GCM: %v", err)
-		return "", fmt.Errorf("failed to create GCM")
+		return "", fmt.Errorf("failed to create GCM: %w", err)
 	}
 
 	// Extract the nonce from the ciphertext
 	nonceSize := gcm.NonceSize()
 	if len(ciphertext) < nonceSize {
-		log.Printf("Ciphertext too short, expected at least %d bytes", nonceSize)
 		return "", fmt.Errorf("invalid encrypted content")
 	}
 
@@ -280,8 +292,7 @@ func decryptContent(content string, userID int, isPublic bool) (string, error) {
 	// Decrypt the content
 	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
 	if err != nil {
-		log.Printf("Failed to decrypt content: %v", err)
-		return "", fmt.Errorf("failed to decrypt content")
+		return "", fmt.Errorf("failed to decrypt content: %w", err)
 	}
 
 	// Return the decrypted string
@@ -0,0 +1,194 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"encoding/base64"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const dbTimeLayout = "2006-01-02 15:04:05"
+
+// Starting point for the first page, newer than any stored post
+var firstPageCursor = types.PostCursor{CreatedAt: "9999-12-31 23:59:59", ID: math.MaxInt32}
+
+func EncodeCursor(createdAt []byte, id int) string {
+	// Cursors are opaque to clients, they only need to hand them back
+	return base64.RawURLEncoding.EncodeToString([]byte(string(createdAt) + "|" + strconv.Itoa(id)))
+}
+
+func DecodeCursor(cursor string) (types.PostCursor, error) {
+	// An empty cursor means start from the newest post
+	if cursor == "" {
+		return firstPageCursor, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+
+	if err != nil {
+		return types.PostCursor{}, utils.Validation("invalid cursor")
+	}
+
+	createdAt, rawID, found := strings.Cut(string(raw), "|")
+
+	if !found {
+		return types.PostCursor{}, utils.Validation("invalid cursor")
+	}
+
+	// Validate both halves before they reach the database
+	if _, err := time.Parse(dbTimeLayout, createdAt); err != nil {
+		return types.PostCursor{}, utils.Validation("invalid cursor")
+	}
+
+	id, err := strconv.Atoi(rawID)
+
+	if err != nil || id <= 0 {
+		return types.PostCursor{}, utils.Validation("invalid cursor")
+	}
+
+	return types.PostCursor{CreatedAt: createdAt, ID: id}, nil
+}
+
+func GetCursorQuery(ctx *gin.Context) (types.PostCursor, error) {
+	// Parse the optional cursor query parameter
+	return DecodeCursor(ctx.Query(utils.CURSOR))
+}
+
+func GetLimitQuery(ctx *gin.Context, fallback int) int {
+	// Parse the optional page size & default to the configured size if invalid
+	limit, err := strconv.Atoi(ctx.Query(utils.LIMIT))
+
+	if err != nil || limit < 1 {
+		return fallback
+	}
+
+	if limit > utils.MAX_POSTS_PER_PAGE {
+		return utils.MAX_POSTS_PER_PAGE
+	}
+
+	return limit
+}
+
+func TotalPages(totalCount, limit int) int {
+	// Round up so a partial last page still gets a tab
+	return (totalCount + limit - 1) / limit
+}
+
+func GetBlogPostsByUserAfter(ctx context.Context, app *types.App, username string, isOwner bool, userID int, tag string, cursor types.PostCursor, limit int) ([]*types.BlogPostData, string, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetBlogPostsByUserAfter")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Check if user exists in the database
+	var exists bool
+	if err := app.Database.QueryRowContext(ctx, utils.UserExistsQuery, username).Scan(&exists); err != nil {
+		return nil, "", utils.DatabaseError(ctx, err, "failed to check user exists")
+	} else if !exists {
+		return nil, "", utils.NotFound("user not found")
+	}
+
+	var posts []*types.BlogPostData
+	var err error
+
+	if isOwner && tag != "" {
+		// Private posts are matched on the hashes of their tags, fetching one extra row like the rest
+		if posts, err = getOwnPostsByTagAfter(ctx, app, userID, tag, cursor, limit+1); err != nil {
+			return nil, "", err
+		}
+	} else {
+		// Fetch one extra row to find out whether another page exists
+		rows, err := app.Database.QueryContext(ctx, utils.SelectPostsByUsernameAfterQuery, username, userID, userID, userID, userID, userID, userID, tag, tag,
+			cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit+1)
+
+		if err != nil {
+			app.Logger.ErrorContext(ctx, "SQL query error while retrieving user posts", "username", username, "error", err)
+			return nil, "", utils.DatabaseError(ctx, err, "failed to retrieve posts")
+		}
+
+		if posts, err = scanUserPosts(ctx, app, rows, userID); err != nil {
+			return nil, "", err
+		}
+	}
+
+	// Trim the extra row & hand back the cursor of the last post shown
+	if len(posts) > limit {
+		posts = posts[:limit]
+		return posts, posts[limit-1].Cursor, nil
+	}
+
+	return posts, "", nil
+}
+
+func GetHomeFeedPostsAfter(ctx context.Context, app *types.App, userID int, tag string, cursor types.PostCursor, limit int) ([]*types.HomeFeedData, string, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetHomeFeedPostsAfter")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Fetch one extra row to find out whether another page exists
+	rows, err := app.Database.QueryContext(ctx, utils.SelectHomeFeedPostsAfterQuery, userID, tag, tag,
+		cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit+1)
+
+	if err != nil {
+		return nil, "", utils.DatabaseError(ctx, err, "failed to query posts for user %d", userID)
+	}
+
+	posts, err := scanFeedPosts(rows)
+
+	if err != nil {
+		return nil, "", utils.DatabaseError(ctx, err, "failed to read posts for user %d", userID)
+	}
+
+	return finishFeedPage(ctx, app, posts, limit)
+}
+
+func GetPostsByTagAfter(ctx context.Context, app *types.App, tag string, viewerID int, cursor types.PostCursor, limit int) ([]*types.HomeFeedData, string, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetPostsByTagAfter")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Fetch one extra row to find out whether another page exists
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPostsByTagAfterQuery, tag, viewerID, viewerID, viewerID, viewerID,
+		cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit+1)
+
+	if err != nil {
+		return nil, "", utils.DatabaseError(ctx, err, "failed to query posts for tag %s", tag)
+	}
+
+	posts, err := scanFeedPosts(rows)
+
+	if err != nil {
+		return nil, "", utils.DatabaseError(ctx, err, "failed to read posts for tag %s", tag)
+	}
+
+	return finishFeedPage(ctx, app, posts, limit)
+}
+
+func finishFeedPage(ctx context.Context, app *types.App, posts []*types.HomeFeedData, limit int) ([]*types.HomeFeedData, string, error) {
+	var nextCursor string
+
+	// Trim the extra row & remember the cursor of the last post shown
+	if len(posts) > limit {
+		posts = posts[:limit]
+		nextCursor = posts[limit-1].Cursor
+	}
+
+	// Attach the tags of every post on the page
+	if err := attachFeedTags(ctx, app, posts); err != nil {
+		return nil, "", err
+	}
+
+	return posts, nextCursor, nil
+}
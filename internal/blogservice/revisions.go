@@ -0,0 +1,279 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"database/sql"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+)
+
+func saveRevision(ctx context.Context, app *types.App, tx *sql.Tx, postID int, createdAt any) error {
+	// Snapshot the post as stored, then drop the oldest revisions past the limit
+	if _, err := tx.ExecContext(ctx, utils.InsertPostRevisionQuery, createdAt, postID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while saving revision", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save post revision")
+	}
+
+	if _, err := tx.ExecContext(ctx, utils.PrunePostRevisionsQuery, postID, postID, utils.POST_MAX_REVISIONS); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while pruning revisions", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save post revision")
+	}
+
+	return nil
+}
+
+func saveOriginalRevision(ctx context.Context, app *types.App, tx *sql.Tx, postID int) error {
+	// Posts written before revisions existed keep their original version as the first one
+	var count int
+
+	if err := tx.QueryRowContext(ctx, utils.CountPostRevisionsQuery, postID).Scan(&count); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while counting revisions", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save post revision")
+	}
+
+	if count > 0 {
+		return nil
+	}
+
+	return saveRevision(ctx, app, tx, postID, nil)
+}
+
+func encryptRevisions(ctx context.Context, app *types.App, tx *sql.Tx, postID, userID int) error {
+	// A post going private takes its earlier, readable versions with it
+	rows, err := tx.QueryContext(ctx, utils.SelectPlainPostRevisionsQuery, postID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading revisions to encrypt", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update post history")
+	}
+
+	// Read every revision first, the connection can't run updates while rows are open
+	var revisions []*types.PostRevision
+	var revisionTags []sql.NullString
+
+	for rows.Next() {
+		revision := &types.PostRevision{}
+		var tags sql.NullString
+
+		if err := rows.Scan(&revision.ID, &revision.Title, &revision.Content, &tags); err != nil {
+			rows.Close()
+			app.Logger.ErrorContext(ctx, "Error scanning revision to encrypt", "post_id", postID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update post history")
+		}
+
+		revisions = append(revisions, revision)
+		revisionTags = append(revisionTags, tags)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating revisions to encrypt", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update post history")
+	}
+
+	for i, revision := range revisions {
+		title, content, err := EncryptBlogPost(app, revision.Title, revision.Content, userID, utils.VISIBILITY_PRIVATE)
+
+		if err != nil {
+			return utils.Internal(err, "encryption error: failed to update post history")
+		}
+
+		var tags []string
+
+		if revisionTags[i].Valid && revisionTags[i].String != "" {
+			tags = strings.Split(revisionTags[i].String, ",")
+		}
+
+		encryptedTags, err := EncryptTags(app, tags, userID, utils.VISIBILITY_PRIVATE)
+
+		if err != nil {
+			return utils.Internal(err, "encryption error: failed to update post history")
+		}
+
+		// The revision is marked private so it is decrypted again when the history is read
+		if _, err := tx.ExecContext(ctx, utils.UpdatePostRevisionQuery, title, content, utils.VISIBILITY_PRIVATE, encryptedTags, revision.ID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while encrypting revision", "revision_id", revision.ID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update post history")
+		}
+	}
+
+	return nil
+}
+
+func GetPostRevisions(ctx context.Context, app *types.App, postID, userID int) ([]*types.PostRevision, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetPostRevisions")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Only the owner's posts match, so other users see no history at all
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPostRevisionsQuery, postID, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading revisions", "post_id", postID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load post history")
+	}
+
+	defer rows.Close()
+
+	var revisions []*types.PostRevision
+
+	for rows.Next() {
+		revision := &types.PostRevision{}
+		var tags sql.NullString
+		var createdAt []byte
+
+		if err := rows.Scan(&revision.ID, &revision.Title, &revision.Content, &revision.Visibility, &tags, &createdAt); err != nil {
+			app.Logger.ErrorContext(ctx, "Error scanning revision", "post_id", postID, "error", err)
+			return nil, utils.DatabaseError(ctx, err, "failed to load post history")
+		}
+
+		// Private revisions are encrypted with the owner's key like the post itself
+		if revision.Title, revision.Content, err = DecryptBlogPost(app, revision.Title, revision.Content, userID, revision.Visibility); err != nil {
+			return nil, utils.Internal(err, "encryption error: failed to decrypt post history")
+		}
+
+		if !IsEncryptedVisibility(revision.Visibility) {
+			if tags.Valid && tags.String != "" {
+				revision.Tags = strings.Split(tags.String, ",")
+			}
+		} else if revision.Tags, err = DecryptTags(app, tags, userID); err != nil {
+			return nil, utils.Internal(err, "encryption error: failed to decrypt post history")
+		}
+
+		slices.Sort(revision.Tags)
+
+		revision.Number = len(revisions) + 1
+		revision.CreatedAt = FormatDate(createdAt)
+		revisions = append(revisions, revision)
+	}
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating revisions", "post_id", postID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load post history")
+	}
+
+	if len(revisions) > 0 {
+		revisions[len(revisions)-1].IsCurrent = true
+	}
+
+	return revisions, nil
+}
+
+func RestoreRevision(ctx context.Context, app *types.App, postID, revisionID, userID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.RestoreRevision")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	revisions, err := GetPostRevisions(ctx, app, postID, userID)
+
+	if err != nil {
+		return err
+	}
+
+	for _, revision := range revisions {
+		if revision.ID != revisionID {
+			continue
+		}
+
+		if revision.IsCurrent {
+			return utils.Conflict("this version is already the current one")
+		}
+
+		// Restoring brings back the words, the post keeps its current visibility
+		current := revisions[len(revisions)-1]
+
+		return UpdateBlogPostInDB(ctx, app, &types.UpdateBlogPost{
+			BlogPostBase: types.BlogPostBase{
+				Title:      revision.Title,
+				Content:    revision.Content,
+				Visibility: current.Visibility,
+				Tags:       revision.Tags,
+			},
+			UserID: userID,
+			ID:     postID,
+		}, nil)
+	}
+
+	return utils.NotFound("revision not found")
+}
+
+func DiffRevisions(from, to *types.PostRevision) []types.DiffLine {
+	// Diff whole lines, the way writers think about their changes
+	dmp := diffmatchpatch.New()
+	fromChars, toChars, lines := dmp.DiffLinesToChars(from.Content, to.Content)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(fromChars, toChars, false), lines)
+
+	var diffLines []types.DiffLine
+
+	for _, diff := range diffs {
+		op := utils.DIFF_EQUAL
+
+		switch diff.Type {
+		case diffmatchpatch.DiffInsert:
+			op = utils.DIFF_INSERT
+		case diffmatchpatch.DiffDelete:
+			op = utils.DIFF_DELETE
+		}
+
+		for _, line := range strings.SplitAfter(diff.Text, "\n") {
+			if line != "" {
+				diffLines = append(diffLines, types.DiffLine{Op: op, Text: strings.TrimSuffix(line, "\n")})
+			}
+		}
+	}
+
+	return collapseUnchanged(diffLines)
+}
+
+func collapseUnchanged(diffLines []types.DiffLine) []types.DiffLine {
+	// Keep a few unchanged lines around each change and fold the rest away
+	var collapsed []types.DiffLine
+
+	for start := 0; start < len(diffLines); {
+		if diffLines[start].Op != utils.DIFF_EQUAL {
+			collapsed = append(collapsed, diffLines[start])
+			start++
+			continue
+		}
+
+		end := start
+
+		for end < len(diffLines) && diffLines[end].Op == utils.DIFF_EQUAL {
+			end++
+		}
+
+		keepLeading, keepTrailing := utils.DIFF_CONTEXT_LINES, utils.DIFF_CONTEXT_LINES
+
+		if start == 0 {
+			keepLeading = 0
+		}
+
+		if end == len(diffLines) {
+			keepTrailing = 0
+		}
+
+		if hidden := end - start - keepLeading - keepTrailing; hidden > 0 {
+			collapsed = append(collapsed, diffLines[start:start+keepLeading]...)
+			collapsed = append(collapsed, types.DiffLine{Op: utils.DIFF_SKIP, Text: fmt.Sprintf("%d unchanged lines", hidden)})
+			collapsed = append(collapsed, diffLines[end-keepTrailing:end]...)
+		} else {
+			collapsed = append(collapsed, diffLines[start:end]...)
+		}
+
+		start = end
+	}
+
+	return collapsed
+}
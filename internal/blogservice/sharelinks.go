@@ -0,0 +1,365 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var shareTokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// Form values for how long a new link stays open, zero means it never expires
+var shareLinkExpiries = map[string]time.Duration{
+	"":    0,
+	"1d":  24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+	"30d": 30 * 24 * time.Hour,
+}
+
+func GetShareLinksPageData(ctx context.Context, app *types.App, postID, userID int, baseURL string) (*types.ShareLinksPageData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetShareLinksPageData")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Loading the post like the editor does also checks the viewer owns it
+	post := &types.BlogPostFormData{}
+
+	if err := GetPostDataOnEdit(ctx, app, post, postID, userID); err != nil {
+		return nil, err
+	}
+
+	links, err := GetShareLinks(ctx, app, postID, userID, baseURL)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &types.ShareLinksPageData{
+		PostID:    postID,
+		Title:     post.Title,
+		Links:     links,
+		CanCreate: IsEncryptedVisibility(post.Visibility) && len(links) < utils.SHARE_LINKS_MAX_PER_POST,
+	}, nil
+}
+
+func CreateShareLink(ctx context.Context, app *types.App, postID, userID int, expiresIn string) error {
+	ctx, span := tracing.Start(ctx, "blogservice.CreateShareLink")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	duration, ok := shareLinkExpiries[expiresIn]
+
+	if !ok {
+		return utils.Validation("invalid link expiry")
+	}
+
+	post := &types.BlogPostFormData{}
+
+	if err := GetPostDataOnEdit(ctx, app, post, postID, userID); err != nil {
+		return err
+	}
+
+	// Posts that aren't private can already be read at their normal address
+	if !IsEncryptedVisibility(post.Visibility) {
+		return utils.Validation("share links are only needed for private posts")
+	}
+
+	var count int
+
+	if err := app.Database.QueryRowContext(ctx, utils.CountShareLinksQuery, postID).Scan(&count); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while counting share links", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to create share link")
+	}
+
+	if count >= utils.SHARE_LINKS_MAX_PER_POST {
+		return utils.Validation("posts can have at most %d share links", utils.SHARE_LINKS_MAX_PER_POST)
+	}
+
+	// Every link gets its own key, so revoking one never affects the others
+	token, key, err := newShareLinkSecrets()
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to generate share link", "post_id", postID, "error", err)
+		return utils.Internal(err, "failed to create share link")
+	}
+
+	title, content, tags, err := sealSharedPost(app, &post.BlogPostBase, key)
+
+	if err != nil {
+		return utils.Internal(err, "encryption error: failed to create share link")
+	}
+
+	// The owner keeps a copy of the link key so the link can be shown again later
+	linkKey, err := encryptContent(app, base64.RawURLEncoding.EncodeToString(key), userID, false)
+
+	if err != nil {
+		return utils.Internal(err, "encryption error: failed to create share link")
+	}
+
+	var expiresAt any
+
+	if duration > 0 {
+		expiresAt = app.Clock.Now().UTC().Add(duration).Format(dbTimeLayout)
+	}
+
+	if _, err := app.Database.ExecContext(ctx, utils.InsertShareLinkQuery, token, postID, linkKey, title, content, tags, expiresAt); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while creating share link", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to create share link")
+	}
+
+	return nil
+}
+
+func GetShareLinks(ctx context.Context, app *types.App, postID, userID int, baseURL string) ([]*types.ShareLink, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetShareLinks")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectShareLinksOfPostQuery, postID, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading share links", "post_id", postID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load share links")
+	}
+
+	defer rows.Close()
+
+	var links []*types.ShareLink
+	now := app.Clock.Now().UTC()
+
+	for rows.Next() {
+		link := &types.ShareLink{}
+		var linkKey string
+		var expiresAt, createdAt []byte
+
+		if err := rows.Scan(&link.ID, &link.Token, &linkKey, &expiresAt, &createdAt); err != nil {
+			app.Logger.ErrorContext(ctx, "Error scanning share link", "post_id", postID, "error", err)
+			return nil, utils.DatabaseError(ctx, err, "failed to load share links")
+		}
+
+		// Expired links wait for the cleanup job, they no longer open anyway
+		if expiresAt != nil && !ParseDate(expiresAt).After(now) {
+			continue
+		}
+
+		key, err := decryptContent(app, linkKey, userID, false)
+
+		if err != nil {
+			return nil, utils.Internal(err, "encryption error: failed to decrypt share links")
+		}
+
+		// The key goes in the fragment, which browsers never send to the server
+		link.URL = fmt.Sprintf("%s/share/%s#%s", baseURL, link.Token, key)
+		link.CreatedAt = FormatDate(createdAt)
+		link.ExpiresAt = FormatDate(expiresAt)
+		links = append(links, link)
+	}
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating share links", "post_id", postID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load share links")
+	}
+
+	return links, nil
+}
+
+func RevokeShareLink(ctx context.Context, app *types.App, postID int, token string, userID int) error {
+	ctx, span := tracing.Start(ctx, "blogservice.RevokeShareLink")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	result, err := app.Database.ExecContext(ctx, utils.DeleteShareLinkQuery, token, postID, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while revoking share link", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to revoke share link")
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err != nil || rowsAffected == 0 {
+		return utils.NotFound("share link not found")
+	}
+
+	return nil
+}
+
+func GetSharedPost(ctx context.Context, app *types.App, token string) (*types.SharedPostPageData, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetSharedPost")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Reject anything that can't be a token before touching the database
+	if !shareTokenPattern.MatchString(token) {
+		return nil, utils.NotFound("share link not found or expired")
+	}
+
+	pageData := &types.SharedPostPageData{}
+	var expiresAt, createdAt, avatarUpdatedAt []byte
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectSharedPostQuery, token, app.Clock.Now().UTC().Format(dbTimeLayout)).Scan(
+		&pageData.PostID, &pageData.Title, &pageData.Content, &pageData.Tags, &expiresAt, &createdAt,
+		&pageData.Username, &pageData.DisplayName, &avatarUpdatedAt,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("share link not found or expired")
+		}
+
+		app.Logger.ErrorContext(ctx, "SQL query error while loading share link", "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load shared post")
+	}
+
+	pageData.AvatarURL = utils.AvatarURL(pageData.Username, avatarUpdatedAt)
+	pageData.DisplayName = utils.DisplayName(pageData.DisplayName, pageData.Username)
+	pageData.Username = utils.CapitalizeFirstLetter(pageData.Username)
+	pageData.CreatedAt = FormatDate(createdAt)
+	pageData.ExpiresAt = FormatDate(expiresAt)
+
+	return pageData, nil
+}
+
+func DeleteExpiredShareLinks(ctx context.Context, app *types.App) error {
+	ctx, span := tracing.Start(ctx, "blogservice.DeleteExpiredShareLinks")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	result, err := app.Database.ExecContext(ctx, utils.DeleteExpiredShareLinksQuery, app.Clock.Now().UTC().Format(dbTimeLayout))
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while deleting expired share links", "error", err)
+		return utils.DatabaseError(ctx, err, "failed to delete expired share links")
+	}
+
+	if rowsAffected, err := result.RowsAffected(); err == nil && rowsAffected > 0 {
+		app.Logger.InfoContext(ctx, "Deleted expired share links", "count", rowsAffected)
+	}
+
+	return nil
+}
+
+func syncShareLinks(ctx context.Context, app *types.App, tx *sql.Tx, postData *types.UpdateBlogPost) error {
+	if !IsEncryptedVisibility(postData.Visibility) {
+		if _, err := tx.ExecContext(ctx, utils.DeleteShareLinksOfPostQuery, postData.ID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while deleting share links", "post_id", postData.ID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update share links")
+		}
+
+		return nil
+	}
+
+	rows, err := tx.QueryContext(ctx, utils.SelectShareLinksOfPostQuery, postData.ID, postData.UserID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading share links", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update share links")
+	}
+
+	// Read every link first, the connection can't run updates while rows are open
+	linkKeys := make(map[int]string)
+
+	for rows.Next() {
+		var id int
+		var token, linkKey string
+		var expiresAt, createdAt []byte
+
+		if err := rows.Scan(&id, &token, &linkKey, &expiresAt, &createdAt); err != nil {
+			rows.Close()
+			app.Logger.ErrorContext(ctx, "Error scanning share link", "post_id", postData.ID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update share links")
+		}
+
+		linkKeys[id] = linkKey
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		app.Logger.ErrorContext(ctx, "Error iterating share links", "post_id", postData.ID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update share links")
+	}
+
+	for id, linkKey := range linkKeys {
+		encodedKey, err := decryptContent(app, linkKey, postData.UserID, false)
+
+		if err != nil {
+			return utils.Internal(err, "encryption error: failed to update share links")
+		}
+
+		key, err := base64.RawURLEncoding.DecodeString(encodedKey)
+
+		if err != nil {
+			app.Logger.WarnContext(ctx, "Invalid share link key", "share_link_id", id, "error", err)
+			return utils.Internal(err, "encryption error: failed to update share links")
+		}
+
+		title, content, tags, err := sealSharedPost(app, &postData.BlogPostBase, key)
+
+		if err != nil {
+			return utils.Internal(err, "encryption error: failed to update share links")
+		}
+
+		if _, err := tx.ExecContext(ctx, utils.UpdateShareLinkContentQuery, title, content, tags, id); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while updating share link", "share_link_id", id, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update share links")
+		}
+	}
+
+	return nil
+}
+
+func sealSharedPost(app *types.App, post *types.BlogPostBase, key []byte) (string, string, string, error) {
+	title, err := encryptWithKey(app, post.Title, key)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	content, err := encryptWithKey(app, post.Content, key)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	tags, err := encryptWithKey(app, strings.Join(post.Tags, ","), key)
+
+	if err != nil {
+		return "", "", "", err
+	}
+
+	return title, content, tags, nil
+}
+
+func newShareLinkSecrets() (string, []byte, error) {
+	raw := make([]byte, 16)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+
+	key := make([]byte, 32)
+
+	if _, err := rand.Read(key); err != nil {
+		return "", nil, err
+	}
+
+	return hex.EncodeToString(raw), key, nil
+}
@@ -0,0 +1,411 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var tagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+func ParseTags(input string) ([]string, error) {
+	// Tags may be separated by commas or whitespace
+	fields := strings.FieldsFunc(input, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '\t' || r == '\n' || r == '\r'
+	})
+
+	tags := []string{}
+	seen := make(map[string]bool)
+
+	for _, field := range fields {
+		tag := NormalizeTag(field)
+
+		if tag == "" || seen[tag] {
+			continue
+		}
+
+		if !IsValidTag(tag) {
+			return nil, utils.Validation("invalid tag %q: tags must be up to %d letters, numbers or dashes", tag, utils.TAG_MAX_LENGTH)
+		}
+
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+
+	if len(tags) > utils.TAG_MAX_PER_POST {
+		return nil, utils.Validation("too many tags: a post may have at most %d tags", utils.TAG_MAX_PER_POST)
+	}
+
+	return tags, nil
+}
+
+func NormalizeTag(tag string) string {
+	// Tags are case-insensitive and may be written with a leading '#'
+	return strings.ToLower(strings.TrimPrefix(strings.TrimSpace(tag), "#"))
+}
+
+func IsValidTag(tag string) bool {
+	// Check tag length & allowed characters
+	return len(tag) <= utils.TAG_MAX_LENGTH && tagPattern.MatchString(tag)
+}
+
+func GetTagQuery(ctx *gin.Context) string {
+	// Read the optional tag filter & ignore anything that isn't a valid tag
+	tag := NormalizeTag(ctx.Query(utils.TAG))
+
+	if !IsValidTag(tag) {
+		return ""
+	}
+
+	return tag
+}
+
+func ValidateTagParam(context *gin.Context) (string, error) {
+	// Validate tag name from url params
+	tag := NormalizeTag(context.Param(utils.NAME))
+
+	if !IsValidTag(tag) {
+		return "", utils.Validation(utils.INVALID_TAG_MESSAGE)
+	}
+
+	return tag, nil
+}
+
+func EncryptTags(app *types.App, tags []string, userID int, visibility string) (sql.NullString, error) {
+	// Posts that aren't private keep their tags in the PostTags table instead
+	if !IsEncryptedVisibility(visibility) || len(tags) == 0 {
+		return sql.NullString{}, nil
+	}
+
+	encryptedTags, err := encryptContent(app, strings.Join(tags, ","), userID, false)
+
+	if err != nil {
+		app.Logger.Error("Failed to encrypt tags", "error", err)
+		return sql.NullString{}, utils.Internal(err, "failed to encrypt tags")
+	}
+
+	return sql.NullString{String: encryptedTags, Valid: true}, nil
+}
+
+func DecryptTags(app *types.App, encryptedTags sql.NullString, userID int) ([]string, error) {
+	// Posts without encrypted tags have nothing to decrypt
+	if !encryptedTags.Valid || encryptedTags.String == "" {
+		return nil, nil
+	}
+
+	joinedTags, err := decryptContent(app, encryptedTags.String, userID, false)
+
+	if err != nil {
+		app.Logger.Error("Failed to decrypt tags", "error", err)
+		return nil, utils.Internal(err, "failed to decrypt tags")
+	}
+
+	return strings.Split(joinedTags, ","), nil
+}
+
+func replacePostTags(ctx context.Context, app *types.App, tx *sql.Tx, postID, userID int, tags []string, visibility string) error {
+	// Clear out any tags from a previous version of the post
+	if _, err := tx.ExecContext(ctx, utils.DeletePostTagsQuery, postID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while clearing tags", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update post tags")
+	}
+
+	if _, err := tx.ExecContext(ctx, utils.DeletePrivatePostTagsQuery, postID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while clearing private tags", "post_id", postID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update post tags")
+	}
+
+	// Private tags are encrypted & only queryable by their keyed hash, every other post gets queryable tags
+	if IsEncryptedVisibility(visibility) {
+		return insertPrivateTags(ctx, app, tx, postID, userID, tags)
+	}
+
+	for _, tag := range tags {
+		if _, err := tx.ExecContext(ctx, utils.InsertPostTagQuery, postID, tag); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while inserting tag", "tag", tag, "post_id", postID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to save post tags")
+		}
+	}
+
+	return nil
+}
+
+func insertPrivateTags(ctx context.Context, app *types.App, tx types.Querier, postID, userID int, tags []string) error {
+	for _, tag := range tags {
+		hash, err := privateTagHash(app, userID, tag)
+
+		if err != nil {
+			return utils.Internal(err, "encryption error: failed to save post tags")
+		}
+
+		if _, err := tx.ExecContext(ctx, utils.InsertPrivatePostTagQuery, postID, hash); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while inserting private tag", "post_id", postID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to save post tags")
+		}
+	}
+
+	return nil
+}
+
+func privateTagHash(app *types.App, userID int, tag string) (string, error) {
+	key, err := app.KeyCache.Get(userID)
+
+	if err != nil {
+		return "", err
+	}
+
+	// Keyed with the owner's key, so equal tags of different users never match
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(utils.PRIVATE_TAG_HASH_CONTEXT + tag))
+
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func indexPrivateTags(ctx context.Context, app *types.App, userID int) error {
+	// Private posts written before tags were hashed get their hashes once the owner's key is at hand
+	rows, err := app.Database.QueryContext(ctx, utils.SelectUnindexedPrivatePostsQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while loading unindexed private posts", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	// Read every post first, the connection can't run inserts while rows are open
+	postTags := make(map[int]sql.NullString)
+
+	for rows.Next() {
+		var postID int
+		var encryptedTags sql.NullString
+
+		if err := rows.Scan(&postID, &encryptedTags); err != nil {
+			rows.Close()
+			return utils.DatabaseError(ctx, err, "failed to retrieve posts")
+		}
+
+		postTags[postID] = encryptedTags
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	for postID, encryptedTags := range postTags {
+		tags, err := DecryptTags(app, encryptedTags, userID)
+
+		if err != nil {
+			return err
+		}
+
+		if err := insertPrivateTags(ctx, app, app.Database, postID, userID, tags); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func GetTagsForPosts(ctx context.Context, app *types.App, postIDs []int) (map[int][]string, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetTagsForPosts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	tags := make(map[int][]string)
+
+	if len(postIDs) == 0 {
+		return tags, nil
+	}
+
+	// Build one placeholder per post ID for the IN clause
+	placeholders := make([]string, len(postIDs))
+	args := make([]any, len(postIDs))
+
+	for i, id := range postIDs {
+		placeholders[i] = "?"
+		args[i] = id
+	}
+
+	rows, err := app.Database.QueryContext(ctx, fmt.Sprintf(utils.SelectTagsForPostsQuery, strings.Join(placeholders, ",")), args...)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying tags", "post_ids", postIDs, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to retrieve tags")
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID int
+		var tag string
+
+		if err := rows.Scan(&postID, &tag); err != nil {
+			return nil, utils.DatabaseError(ctx, err, "failed to scan tags for posts")
+		}
+
+		tags[postID] = append(tags[postID], tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, utils.DatabaseError(ctx, err, "failed to iterate tags for posts")
+	}
+
+	return tags, nil
+}
+
+func GetTagCloudForUser(ctx context.Context, app *types.App, username string) ([]*types.TagCount, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetTagCloudForUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Only tags on public posts are ever counted
+	rows, err := app.Database.QueryContext(ctx, utils.SelectTagCloudByUsernameQuery, username, utils.TAG_CLOUD_LIMIT)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Error querying tag cloud", "username", username, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to retrieve tags")
+	}
+
+	defer rows.Close()
+
+	var cloud []*types.TagCount
+
+	for rows.Next() {
+		tag := &types.TagCount{}
+
+		if err := rows.Scan(&tag.Name, &tag.Count); err != nil {
+			return nil, utils.DatabaseError(ctx, err, "error scanning tag cloud")
+		}
+
+		cloud = append(cloud, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, utils.DatabaseError(ctx, err, "error iterating tag cloud")
+	}
+
+	return cloud, nil
+}
+
+func getOwnPostsByTag(ctx context.Context, app *types.App, userID int, tag string, limit, offset int) ([]*types.BlogPostData, int, error) {
+	hash, err := ownTagHash(ctx, app, userID, tag)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectOwnPostsForTagQuery, userID, tag, hash, limit, offset)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while filtering own posts by tag", "user_id", userID, "error", err)
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	var totalCount int
+
+	posts, err := scanUserPosts(ctx, app, rows, userID, &totalCount)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	return posts, totalCount, nil
+}
+
+func getOwnPostsByTagAfter(ctx context.Context, app *types.App, userID int, tag string, cursor types.PostCursor, limit int) ([]*types.BlogPostData, error) {
+	hash, err := ownTagHash(ctx, app, userID, tag)
+
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectOwnPostsForTagAfterQuery, userID, tag, hash, cursor.CreatedAt, cursor.CreatedAt, cursor.ID, limit)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL query error while filtering own posts by tag", "user_id", userID, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to retrieve posts")
+	}
+
+	return scanUserPosts(ctx, app, rows, userID)
+}
+
+func ownTagHash(ctx context.Context, app *types.App, userID int, tag string) (string, error) {
+	if err := indexPrivateTags(ctx, app, userID); err != nil {
+		return "", err
+	}
+
+	hash, err := privateTagHash(app, userID, tag)
+
+	if err != nil {
+		return "", utils.Internal(err, "encryption error: failed to filter posts by tag")
+	}
+
+	return hash, nil
+}
+
+func GetPostsByTag(ctx context.Context, app *types.App, tag string, viewerID int, page int, limit int) ([]*types.HomeFeedData, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetPostsByTag")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Calculate pagination offset based on the post limit
+	offset := (page - 1) * limit
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectPostsByTagQuery, tag, viewerID, viewerID, viewerID, viewerID, limit, offset)
+
+	if err != nil {
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to query posts for tag %s", tag)
+	}
+
+	var totalCount int
+
+	posts, err := scanFeedPosts(rows, &totalCount)
+
+	if err != nil {
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to read posts for tag %s", tag)
+	}
+
+	// Attach the tags of every post on the page
+	if err := attachFeedTags(ctx, app, posts); err != nil {
+		return nil, 0, err
+	}
+
+	return posts, totalCount, nil
+}
+
+func attachFeedTags(ctx context.Context, app *types.App, posts []*types.HomeFeedData) error {
+	ids := make([]int, len(posts))
+
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	tags, err := GetTagsForPosts(ctx, app, ids)
+
+	if err != nil {
+		return err
+	}
+
+	for _, post := range posts {
+		post.Tags = tags[post.ID]
+	}
+
+	return nil
+}
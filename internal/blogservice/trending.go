@@ -0,0 +1,189 @@
+package blogservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var trendingWindows = map[string]time.Duration{
+	utils.TRENDING_WINDOW_DAY:   24 * time.Hour,
+	utils.TRENDING_WINDOW_WEEK:  7 * 24 * time.Hour,
+	utils.TRENDING_WINDOW_MONTH: 30 * 24 * time.Hour,
+}
+
+type trendingCandidate struct {
+	postID        int
+	createdAt     string
+	likesCount    int
+	commentsCount int
+	score         float64
+}
+
+func GetWindowQuery(ctx *gin.Context) string {
+	// Default to the weekly window for anything unrecognised
+	window := ctx.DefaultQuery(utils.WINDOW, utils.TRENDING_WINDOW_WEEK)
+
+	if _, ok := trendingWindows[window]; !ok {
+		return utils.TRENDING_WINDOW_WEEK
+	}
+
+	return window
+}
+
+func TrendingScore(likes, comments int, age time.Duration) float64 {
+	// Engagement decays with age so fresh posts can outrank older popular ones
+	hours := math.Max(age.Hours(), 0)
+	engagement := float64(1 + likes + utils.TRENDING_COMMENT_WEIGHT*comments)
+
+	return engagement / math.Pow(hours+2, utils.TRENDING_GRAVITY)
+}
+
+func RefreshTrendingPosts(ctx context.Context, app *types.App) error {
+	ctx, span := tracing.Start(ctx, "blogservice.RefreshTrendingPosts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	now := app.Clock.Now().UTC()
+
+	// Only posts inside the widest window can ever be shown
+	cutoff := now.Add(-trendingWindows[utils.TRENDING_WINDOW_MONTH]).Format(dbTimeLayout)
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectTrendingCandidatesQuery, cutoff)
+
+	if err != nil {
+		return utils.DatabaseError(ctx, err, "failed to query trending candidates")
+	}
+
+	defer rows.Close()
+
+	var candidates []*trendingCandidate
+
+	for rows.Next() {
+		candidate := &trendingCandidate{}
+		var createdAt []byte
+
+		if err := rows.Scan(&candidate.postID, &createdAt, &candidate.likesCount, &candidate.commentsCount); err != nil {
+			return utils.DatabaseError(ctx, err, "failed to scan trending candidate")
+		}
+
+		postTime, err := time.Parse(dbTimeLayout, string(createdAt))
+
+		if err != nil {
+			app.Logger.WarnContext(ctx, "Skipping trending candidate with unparseable date", "post_id", candidate.postID, "created_at", createdAt)
+			continue
+		}
+
+		candidate.createdAt = string(createdAt)
+		candidate.score = TrendingScore(candidate.likesCount, candidate.commentsCount, now.Sub(postTime))
+		candidates = append(candidates, candidate)
+	}
+
+	if err := rows.Err(); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to iterate trending candidates")
+	}
+
+	// Keep only the highest scoring posts
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].score > candidates[j].score
+	})
+
+	if len(candidates) > utils.TRENDING_MAX_POSTS {
+		candidates = candidates[:utils.TRENDING_MAX_POSTS]
+	}
+
+	// Swap the cached rankings in a single transaction so readers never see a partial table
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		return utils.DatabaseError(ctx, err, "failed to start trending refresh")
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, utils.DeleteTrendingPostsQuery); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to clear trending posts")
+	}
+
+	for _, candidate := range candidates {
+		if _, err := tx.ExecContext(ctx, utils.InsertTrendingPostQuery, candidate.postID, candidate.score,
+			candidate.likesCount, candidate.commentsCount, candidate.createdAt); err != nil {
+			return utils.DatabaseError(ctx, err, "failed to insert trending post %d", candidate.postID)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to commit trending refresh")
+	}
+
+	return nil
+}
+
+func GetTrendingPosts(ctx context.Context, app *types.App, window string, tag string, viewerID int, page int, limit int) ([]*types.TrendingPostData, int, error) {
+	ctx, span := tracing.Start(ctx, "blogservice.GetTrendingPosts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Calculate pagination offset based on the post limit
+	offset := (page - 1) * limit
+
+	// Only show posts created within the requested window
+	cutoff := app.Clock.Now().UTC().Add(-trendingWindows[window]).Format(dbTimeLayout)
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectTrendingPostsQuery, cutoff, viewerID, viewerID, viewerID, viewerID, tag, tag, limit, offset)
+
+	if err != nil {
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to query trending posts")
+	}
+
+	defer rows.Close()
+
+	var posts []*types.TrendingPostData
+	var totalCount int
+
+	for rows.Next() {
+		post := &types.TrendingPostData{}
+		var createdAt []byte
+
+		// Scan the row into the post struct
+		if err := rows.Scan(&post.ID, &post.Title, &post.Content, &createdAt, &post.Visibility,
+			&post.Username, &post.DisplayName, &post.LikesCount, &post.CommentsCount, &totalCount); err != nil {
+			return nil, 0, utils.DatabaseError(ctx, err, "failed to scan trending post")
+		}
+
+		// Limit content length for the preview
+		post.Content = TruncateString(post.Content, utils.BLOG_POST_PREVIEW_LENGTH)
+		post.DisplayName = utils.DisplayName(post.DisplayName, post.Username)
+		post.Username = utils.CapitalizeFirstLetter(post.Username)
+		post.CreatedAt = FormatDate(createdAt)
+
+		posts = append(posts, post)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, 0, utils.DatabaseError(ctx, err, "failed to iterate trending posts")
+	}
+
+	// Attach the tags of every post on the page
+	feedPosts := make([]*types.HomeFeedData, len(posts))
+
+	for i, post := range posts {
+		feedPosts[i] = &post.HomeFeedData
+	}
+
+	if err := attachFeedTags(ctx, app, feedPosts); err != nil {
+		return nil, 0, err
+	}
+
+	return posts, totalCount, nil
+}
@@ -1,17 +1,36 @@
 package cache
 
 import (
+	"App/internal/types"
 	"App/internal/utils"
 	"fmt"
-	"log"
 	"sync"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 )
 
-var userKeyCache = &sync.Map{}
+type KeyStore interface {
+	Load(userID int) ([]byte, bool)
+	Store(userID int, key []byte)
+	Delete(userID int)
+	Len() int
+}
+
+type KeyCache struct {
+	store   KeyStore
+	metrics types.Metrics
+}
+
+func New(store KeyStore, metrics types.Metrics) *KeyCache {
+	return &KeyCache{store: store, metrics: metrics}
+}
 
-func DeriveAndCacheUserKey(userID int, password string, salt []byte) error {
+func NewMemoryKeyStore() KeyStore {
+	return &memoryKeyStore{}
+}
+
+func (c *KeyCache) DeriveAndCache(userID int, password string, salt []byte) error {
 	if len(salt) != 16 {
 		return fmt.Errorf("salt must be exactly 16 bytes")
 	}
@@ -22,54 +41,80 @@ func DeriveAndCacheUserKey(userID int, password string, salt []byte) error {
 	}
 
 	// Derive a key using Argon2 using the provided password and salt
+	start := time.Now()
 	key := argon2.IDKey([]byte(password), salt, utils.ArgonTime, utils.ArgonMemory, utils.ArgonThreads, utils.ArgonKeyLen)
 
-	// Store the derived key system cache
-	CacheUserKey(userID, key)
+	c.metrics.ObserveCrypto(utils.CRYPTO_KEY_DERIVE, start)
+
+	// Store the derived key in the cache (ID : Key)
+	c.store.Store(userID, key)
 
 	// return no error if the key is successfully cached
 	return nil
 }
 
-func CacheUserKey(userID int, key []byte) {
-	// Store the derived key in the cache (ID : Key)
-	userKeyCache.Store(userID, key)
-}
-
-func GetUserKey(userID int) ([]byte, error) {
+func (c *KeyCache) Get(userID int) ([]byte, error) {
 	// Retrieve the key from the cache using the user ID
-	value, ok := userKeyCache.Load(userID)
+	key, ok := c.store.Load(userID)
+	c.metrics.KeyCacheLookup(ok)
 
 	if !ok {
 		return nil, fmt.Errorf("user key not found in cache")
 	}
 
-	// Type assertion with safety check
-	key, ok := value.([]byte)
-
-	if !ok {
-		log.Printf("Invalid key type found in cache for user ID: %d", userID)
-		return nil, fmt.Errorf("invalid key type found in cache")
-	}
-
 	// Return the key
 	return key, nil
 }
 
-func HasUserKey(userID int) bool {
+func (c *KeyCache) Has(userID int) bool {
 	// Check if the user key exists in the cache
-	value, ok := userKeyCache.Load(userID)
+	_, ok := c.store.Load(userID)
+	c.metrics.KeyCacheLookup(ok)
+
+	return ok
+}
+
+func (c *KeyCache) Remove(userID int) {
+	c.store.Delete(userID)
+}
+
+func (c *KeyCache) Len() int {
+	return c.store.Len()
+}
+
+type memoryKeyStore struct {
+	keys sync.Map
+}
+
+func (store *memoryKeyStore) Load(userID int) ([]byte, bool) {
+	value, ok := store.keys.Load(userID)
 
 	if !ok {
-		return false
+		return nil, false
 	}
 
-	// Check if the value is of type []byte
-	_, valid := value.([]byte)
+	// Store only ever puts keys in, but keep the type assertion safe
+	key, ok := value.([]byte)
+
+	return key, ok
+}
+
+func (store *memoryKeyStore) Store(userID int, key []byte) {
+	store.keys.Store(userID, key)
+}
 
-	return valid
+func (store *memoryKeyStore) Delete(userID int) {
+	store.keys.Delete(userID)
 }
 
-func RemoveUserKey(userID int) {
-	userKeyCache.Delete(userID)
+func (store *memoryKeyStore) Len() int {
+	// sync.Map has no length, so count the entries
+	size := 0
+
+	store.keys.Range(func(_, _ any) bool {
+		size++
+		return true
+	})
+
+	return size
 }
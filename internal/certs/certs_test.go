@@ -0,0 +1,277 @@
+package certs
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"App/internal/config"
+)
+
+func TestReloaderServesRenewedCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeKeyPair(t, certFile, keyFile, "first.example")
+
+	reloader, err := NewReloader(certFile, keyFile)
+
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	if name := servedCommonName(t, reloader); name != "first.example" {
+		t.Fatalf("before renewal: got %q, want %q", name, "first.example")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	reloader.Watch(ctx, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	// Push the mtime forward so the renewal is seen even on coarse filesystem clocks
+	writeKeyPair(t, certFile, keyFile, "second.example")
+	later := time.Now().Add(time.Minute)
+
+	for _, file := range []string{certFile, keyFile} {
+		if err := os.Chtimes(file, later, later); err != nil {
+			t.Fatalf("Chtimes: %v", err)
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+
+	for servedCommonName(t, reloader) != "second.example" {
+		if time.Now().After(deadline) {
+			t.Fatalf("after renewal: still serving %q", servedCommonName(t, reloader))
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestReloaderKeepsCertificateWhenRenewalIsBroken(t *testing.T) {
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "cert.pem")
+	keyFile := filepath.Join(dir, "key.pem")
+
+	writeKeyPair(t, certFile, keyFile, "first.example")
+
+	reloader, err := NewReloader(certFile, keyFile)
+
+	if err != nil {
+		t.Fatalf("NewReloader: %v", err)
+	}
+
+	later := time.Now().Add(time.Minute)
+
+	if err := os.WriteFile(certFile, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := os.Chtimes(certFile, later, later); err != nil {
+		t.Fatalf("Chtimes: %v", err)
+	}
+
+	if _, err := reloader.reload(); err == nil {
+		t.Fatal("reload: got nil error for a broken certificate")
+	}
+
+	if name := servedCommonName(t, reloader); name != "first.example" {
+		t.Fatalf("after broken renewal: got %q, want %q", name, "first.example")
+	}
+}
+
+func TestRedirectHandler(t *testing.T) {
+	tests := []struct {
+		name      string
+		httpsAddr string
+		host      string
+		target    string
+		location  string
+	}{
+		{"standard port", ":443", "example.com", "/posts/1?page=2", "https://example.com/posts/1?page=2"},
+		{"no port", "", "example.com", "/", "https://example.com/"},
+		{"custom port", ":8443", "example.com", "/login", "https://example.com:8443/login"},
+		{"drops http port", ":443", "example.com:8080", "/feed", "https://example.com/feed"},
+		{"swaps http port", "0.0.0.0:8443", "example.com:8080", "/feed", "https://example.com:8443/feed"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodGet, test.target, nil)
+			request.Host = test.host
+			response := httptest.NewRecorder()
+
+			RedirectHandler(test.httpsAddr).ServeHTTP(response, request)
+
+			if response.Code != http.StatusMovedPermanently {
+				t.Fatalf("status: got %d, want %d", response.Code, http.StatusMovedPermanently)
+			}
+
+			if location := response.Header().Get("Location"); location != test.location {
+				t.Fatalf("Location: got %q, want %q", location, test.location)
+			}
+		})
+	}
+}
+
+func TestNewACMEManagerTrustsCAFile(t *testing.T) {
+	// Stand in for a Pebble directory behind a certificate from its own CA
+	directory := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"newNonce":"https://acme.test/nonce","newAccount":"https://acme.test/account","newOrder":"https://acme.test/order"}`))
+	}))
+	defer directory.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: directory.Certificate().Raw})
+
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	manager, err := NewACMEManager(&config.Config{
+		ACMEDomains:      []string{"example.com"},
+		ACMECacheDir:     t.TempDir(),
+		ACMEDirectoryURL: directory.URL,
+		ACMECAFile:       caFile,
+	})
+
+	if err != nil {
+		t.Fatalf("NewACMEManager: %v", err)
+	}
+
+	if manager.Client == nil || manager.Client.DirectoryURL != directory.URL {
+		t.Fatalf("Client: got %+v, want directory %q", manager.Client, directory.URL)
+	}
+
+	// The handshake only succeeds when the pool was loaded from the CA file
+	discovered, err := manager.Client.Discover(context.Background())
+
+	if err != nil {
+		t.Fatalf("Discover: %v", err)
+	}
+
+	if discovered.OrderURL != "https://acme.test/order" {
+		t.Fatalf("Discover: got order URL %q", discovered.OrderURL)
+	}
+}
+
+func TestNewACMEManagerRejectsBadCAFile(t *testing.T) {
+	dir := t.TempDir()
+	emptyFile := filepath.Join(dir, "empty.pem")
+
+	if err := os.WriteFile(emptyFile, []byte("no certificates here"), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	tests := []struct {
+		name   string
+		caFile string
+	}{
+		{"missing file", filepath.Join(dir, "missing.pem")},
+		{"no certificates", emptyFile},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewACMEManager(&config.Config{
+				ACMEDomains:      []string{"example.com"},
+				ACMECacheDir:     dir,
+				ACMEDirectoryURL: "https://acme.test/dir",
+				ACMECAFile:       test.caFile,
+			})
+
+			if err == nil {
+				t.Fatal("NewACMEManager: got nil error")
+			}
+		})
+	}
+}
+
+func TestNewACMEManagerDefaultsToLetsEncrypt(t *testing.T) {
+	manager, err := NewACMEManager(&config.Config{
+		ACMEDomains:  []string{"example.com"},
+		ACMECacheDir: t.TempDir(),
+	})
+
+	if err != nil {
+		t.Fatalf("NewACMEManager: %v", err)
+	}
+
+	// A nil client makes autocert use Let's Encrypt
+	if manager.Client != nil {
+		t.Fatalf("Client: got %+v, want nil", manager.Client)
+	}
+}
+
+func servedCommonName(t *testing.T, reloader *Reloader) string {
+	t.Helper()
+
+	cert, err := reloader.GetCertificate(nil)
+
+	if err != nil {
+		t.Fatalf("GetCertificate: %v", err)
+	}
+
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+
+	if err != nil {
+		t.Fatalf("ParseCertificate: %v", err)
+	}
+
+	return leaf.Subject.CommonName
+}
+
+func writeKeyPair(t *testing.T, certFile, keyFile, commonName string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	if err != nil {
+		t.Fatalf("GenerateKey: %v", err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+
+	if err != nil {
+		t.Fatalf("CreateCertificate: %v", err)
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+
+	if err != nil {
+		t.Fatalf("MarshalECPrivateKey: %v", err)
+	}
+
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+package cli
+
+import (
+	"App/internal/cache"
+	"App/internal/config"
+	"App/internal/metrics"
+	"App/internal/types"
+	"App/internal/utils"
+	"bufio"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+const usage = `usage: posto <command> [flags]
+
+commands:
+  export    write a user's data archive to a ZIP file
+  import    import posts from Markdown, WordPress or Jekyll/Hugo files`
+
+func Run(args []string) error {
+	// Dispatch to the requested admin command
+	switch args[0] {
+	case "export":
+		return runExport(args[1:])
+	case "import":
+		return runImport(args[1:])
+	case "help", "-h", "--help":
+		fmt.Println(usage)
+		return nil
+	default:
+		return fmt.Errorf("unknown command %q\n%s", args[0], usage)
+	}
+}
+
+func openDatabase() (*sql.DB, error) {
+	// Admin commands use the same environment as the server
+	cfg, err := config.Load()
+
+	if err != nil {
+		return nil, fmt.Errorf("error loading configuration: %w", err)
+	}
+
+	database, err := sql.Open("mysql", cfg.DatabaseDSN())
+
+	if err != nil {
+		return nil, fmt.Errorf("error opening database connection: %w", err)
+	}
+
+	if err := database.Ping(); err != nil {
+		database.Close()
+		return nil, fmt.Errorf("error pinging the database: %w", err)
+	}
+
+	return database, nil
+}
+
+func newApp(database *sql.DB) *types.App {
+	// Admin commands run one at a time, so they get a key cache, clock, logger & metrics of their own
+	recorder := metrics.New()
+
+	return &types.App{
+		Database: database,
+		KeyCache: cache.New(cache.NewMemoryKeyStore(), recorder),
+		Clock:    utils.SystemClock{},
+		Logger:   slog.Default(),
+		Metrics:  recorder,
+	}
+}
+
+func readPassword(r io.Reader) (string, error) {
+	// Only the first line is used so the password can be piped in
+	line, err := bufio.NewReader(r).ReadString('\n')
+
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("error reading password: %w", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
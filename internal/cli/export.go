@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"App/internal/archiveservice"
+	"App/internal/userservice"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func runExport(args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	username := flags.String("user", "", "username of the account to export (required)")
+	output := flags.String("out", "", "path of the ZIP file to write, or - for stdout (default posto-<user>.zip)")
+	passwordStdin := flags.Bool("password-stdin", false, "read the user's password from stdin to decrypt private posts")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" {
+		return fmt.Errorf("export: -user is required")
+	}
+
+	*username = strings.ToLower(*username)
+
+	if *output == "" {
+		*output = fmt.Sprintf("posto-%s.zip", *username)
+	}
+
+	database, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer database.Close()
+
+	ctx := context.Background()
+	app := newApp(database)
+
+	// Private posts stay encrypted in the archive unless the owner's password unlocks them
+	if *passwordStdin {
+		password, err := readPassword(os.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		if _, err := userservice.UnlockUserKey(ctx, app, *username, password); err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+	} else {
+		fmt.Fprintln(os.Stderr, "No password given, private posts will be exported encrypted")
+	}
+
+	var w io.Writer = os.Stdout
+
+	if *output != "-" {
+		file, err := os.OpenFile(*output, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+
+		if err != nil {
+			return fmt.Errorf("export: %w", err)
+		}
+
+		defer file.Close()
+		w = file
+	}
+
+	if err := archiveservice.WriteUserArchive(ctx, app, w, *username); err != nil {
+		// Don't leave a truncated archive behind
+		if *output != "-" {
+			os.Remove(*output)
+		}
+
+		return fmt.Errorf("export: %w", err)
+	}
+
+	if *output != "-" {
+		fmt.Fprintf(os.Stderr, "Wrote %s\n", *output)
+	}
+
+	return nil
+}
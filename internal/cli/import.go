@@ -0,0 +1,120 @@
+package cli
+
+import (
+	"App/internal/archiveservice"
+	"App/internal/blogservice"
+	"App/internal/types"
+	"App/internal/userservice"
+	"App/internal/utils"
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+func runImport(args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	username := flags.String("user", "", "username of the account to import into (required)")
+	visibility := flags.String("visibility", utils.VISIBILITY_PUBLIC, "visibility of posts that don't set their own: public, unlisted, followers or private")
+	passwordStdin := flags.Bool("password-stdin", false, "read the user's password from stdin, required to import private posts")
+	dryRun := flags.Bool("dry-run", false, "parse the files and report what would be imported without saving anything")
+
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "usage: posto import -user NAME [flags] PATH...")
+		fmt.Fprintln(flags.Output(), "PATH may be a Markdown file, a WordPress export, a ZIP or a Jekyll/Hugo site folder.")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *username == "" || flags.NArg() == 0 {
+		flags.Usage()
+		return fmt.Errorf("import: -user and at least one path are required")
+	}
+
+	*username = strings.ToLower(*username)
+
+	if !blogservice.IsValidVisibility(*visibility) {
+		return fmt.Errorf("import: invalid visibility %q", *visibility)
+	}
+
+	// Parse everything up front so a bad path fails before anything is written
+	var items []*types.ImportItem
+
+	for _, path := range flags.Args() {
+		parsed, err := archiveservice.ParseImportPath(path, *visibility)
+
+		if err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+
+		items = append(items, parsed...)
+	}
+
+	if len(items) > utils.IMPORT_MAX_ITEMS {
+		return fmt.Errorf("import: found %d posts, at most %d can be imported at once", len(items), utils.IMPORT_MAX_ITEMS)
+	}
+
+	if *dryRun {
+		for _, item := range items {
+			if item.Err != nil {
+				fmt.Printf("FAIL %s: %v\n", item.Source, item.Err)
+			} else {
+				fmt.Printf("OK   %s: %q (%s, %s)\n", item.Source, item.Title, item.Visibility, item.CreatedAt.Format("2006-01-02"))
+			}
+		}
+
+		return nil
+	}
+
+	database, err := openDatabase()
+
+	if err != nil {
+		return err
+	}
+
+	defer database.Close()
+
+	ctx := context.Background()
+	app := newApp(database)
+
+	var userID int
+
+	// Private posts are encrypted with the owner's key, which only their password unlocks
+	if *passwordStdin {
+		password, err := readPassword(os.Stdin)
+
+		if err != nil {
+			return err
+		}
+
+		if userID, err = userservice.UnlockUserKey(ctx, app, *username, password); err != nil {
+			return fmt.Errorf("import: %w", err)
+		}
+	} else if err := database.QueryRowContext(ctx, utils.GetUserIDQuery, *username).Scan(&userID); err != nil {
+		return fmt.Errorf("import: user does not exist or an error occurred: %w", err)
+	}
+
+	results := archiveservice.ImportPosts(ctx, app, userID, items)
+	failed := 0
+
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+			fmt.Printf("FAIL %s: %s\n", result.Source, result.Error)
+		} else {
+			fmt.Printf("OK   %s: post %d\n", result.Source, result.PostID)
+		}
+	}
+
+	fmt.Printf("Imported %d of %d posts\n", len(results)-failed, len(results))
+
+	if failed > 0 {
+		return fmt.Errorf("import: %d posts failed", failed)
+	}
+
+	return nil
+}
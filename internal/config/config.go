@@ -0,0 +1,236 @@
+package config
+
+import (
+	"App/internal/utils"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Config struct {
+	MySQLUser      string
+	MySQLPassword  string
+	MySQLHost      string
+	MySQLDB        string
+	CookieStoreKey string
+	CookieDomain   string
+	PostsPerPage   int
+	SiteURL        string
+
+	TrendingRefreshInterval time.Duration
+
+	MediaStore        string
+	MediaDir          string
+	S3Endpoint        string
+	S3Region          string
+	S3Bucket          string
+	S3AccessKeyID     string
+	S3SecretAccessKey string
+	S3UsePathStyle    bool
+
+	LogOutput     string
+	LogFile       string
+	LogLevel      string
+	LogMaxSizeMB  int
+	LogMaxBackups int
+	LogMaxAgeDays int
+
+	MetricsAddr string
+
+	TraceExporter      string
+	TraceEndpoint      string
+	TraceSamplePercent int
+
+	HTTPAddr    string
+	HTTPSAddr   string
+	TLSMode     string
+	TLSCertFile string
+	TLSKeyFile  string
+	HSTSMaxAge  int
+
+	CSPReportOnly bool
+
+	ACMEDomains      []string
+	ACMEEmail        string
+	ACMECacheDir     string
+	ACMEDirectoryURL string
+	ACMECAFile       string
+}
+
+func Load() (*Config, error) {
+	// Get required system environment variables
+	cfg := &Config{
+		MySQLUser:      os.Getenv("MYSQL_USER"),
+		MySQLPassword:  os.Getenv("MYSQL_PASSWORD"),
+		MySQLHost:      os.Getenv("MYSQL_HOST"),
+		MySQLDB:        os.Getenv("MYSQL_DB"),
+		CookieStoreKey: os.Getenv("COOKIE_STORE_KEY"),
+	}
+
+	// Ensure all necessary environment variables are present
+	if cfg.MySQLUser == "" || cfg.MySQLPassword == "" || cfg.MySQLHost == "" || cfg.MySQLDB == "" || cfg.CookieStoreKey == "" {
+		return nil, fmt.Errorf("required environment variables are missing")
+	}
+
+	// Optional settings fall back to sensible defaults
+	postsPerPage, err := getIntEnv("POSTS_PER_PAGE", utils.DEFAULT_POSTS_PER_PAGE, 1, utils.MAX_POSTS_PER_PAGE)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.PostsPerPage = postsPerPage
+
+	// Absolute links in feeds point at the public site address
+	cfg.SiteURL = strings.TrimSuffix(os.Getenv("SITE_URL"), "/")
+
+	if cfg.SiteURL == "" {
+		cfg.SiteURL = utils.DEFAULT_SITE_URL
+	}
+
+	// The session cookie is scoped to the site's host unless another domain is given
+	cfg.CookieDomain = os.Getenv("COOKIE_DOMAIN")
+
+	if cfg.CookieDomain == "" {
+		siteURL, err := url.Parse(cfg.SiteURL)
+
+		if err != nil {
+			return nil, fmt.Errorf("SITE_URL must be a valid URL")
+		}
+
+		cfg.CookieDomain = siteURL.Hostname()
+	}
+
+	refreshMinutes, err := getIntEnv("TRENDING_REFRESH_MINUTES", utils.DEFAULT_TRENDING_REFRESH_MIN, 1, 24*60)
+
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.TrendingRefreshInterval = time.Duration(refreshMinutes) * time.Minute
+
+	// Uploaded media is kept on local disk unless an S3-compatible bucket is configured
+	cfg.MediaStore = getStringEnv("MEDIA_STORE", utils.MEDIA_STORE_LOCAL)
+	cfg.MediaDir = getStringEnv("MEDIA_DIR", utils.DEFAULT_MEDIA_DIR)
+	cfg.S3Endpoint = os.Getenv("S3_ENDPOINT")
+	cfg.S3Region = getStringEnv("S3_REGION", utils.DEFAULT_S3_REGION)
+	cfg.S3Bucket = os.Getenv("S3_BUCKET")
+	cfg.S3AccessKeyID = os.Getenv("S3_ACCESS_KEY_ID")
+	cfg.S3SecretAccessKey = os.Getenv("S3_SECRET_ACCESS_KEY")
+	cfg.S3UsePathStyle = os.Getenv("S3_USE_PATH_STYLE") == "true"
+
+	if cfg.MediaStore != utils.MEDIA_STORE_LOCAL && cfg.MediaStore != utils.MEDIA_STORE_S3 {
+		return nil, fmt.Errorf("MEDIA_STORE must be %q or %q", utils.MEDIA_STORE_LOCAL, utils.MEDIA_STORE_S3)
+	}
+
+	// Logs go to a rotated file by default, or to stdout when a supervisor collects them
+	cfg.LogOutput = getStringEnv("LOG_OUTPUT", utils.LOG_OUTPUT_FILE)
+	cfg.LogFile = getStringEnv("LOG_FILE", utils.DEFAULT_LOG_FILE)
+	cfg.LogLevel = getStringEnv("LOG_LEVEL", utils.DEFAULT_LOG_LEVEL)
+
+	if cfg.LogOutput != utils.LOG_OUTPUT_FILE && cfg.LogOutput != utils.LOG_OUTPUT_STDOUT {
+		return nil, fmt.Errorf("LOG_OUTPUT must be %q or %q", utils.LOG_OUTPUT_FILE, utils.LOG_OUTPUT_STDOUT)
+	}
+
+	if cfg.LogMaxSizeMB, err = getIntEnv("LOG_MAX_SIZE_MB", utils.DEFAULT_LOG_MAX_SIZE_MB, 1, 10240); err != nil {
+		return nil, err
+	}
+
+	if cfg.LogMaxBackups, err = getIntEnv("LOG_MAX_BACKUPS", utils.DEFAULT_LOG_MAX_BACKUPS, 0, 1000); err != nil {
+		return nil, err
+	}
+
+	if cfg.LogMaxAgeDays, err = getIntEnv("LOG_MAX_AGE_DAYS", utils.DEFAULT_LOG_MAX_AGE_DAYS, 0, 3650); err != nil {
+		return nil, err
+	}
+
+	// Metrics are served on their own admin address, kept off the public port
+	cfg.MetricsAddr = getStringEnv("METRICS_ADDR", utils.DEFAULT_METRICS_ADDR)
+
+	// Tracing is off unless spans are sent to stdout or an OTLP collector
+	cfg.TraceExporter = getStringEnv("TRACE_EXPORTER", utils.TRACE_EXPORTER_OFF)
+	cfg.TraceEndpoint = os.Getenv("TRACE_ENDPOINT")
+
+	switch cfg.TraceExporter {
+	case utils.TRACE_EXPORTER_OFF, utils.TRACE_EXPORTER_STDOUT, utils.TRACE_EXPORTER_OTLP:
+	default:
+		return nil, fmt.Errorf("TRACE_EXPORTER must be %q, %q or %q", utils.TRACE_EXPORTER_OFF, utils.TRACE_EXPORTER_STDOUT, utils.TRACE_EXPORTER_OTLP)
+	}
+
+	if cfg.TraceSamplePercent, err = getIntEnv("TRACE_SAMPLE_PERCENT", utils.DEFAULT_TRACE_SAMPLE_PERCENT, 0, 100); err != nil {
+		return nil, err
+	}
+
+	// Plain HTTP behind NGINX by default, or TLS served directly from certificate files or ACME
+	cfg.HTTPAddr = getStringEnv("HTTP_ADDR", utils.DEFAULT_HTTP_ADDR)
+	cfg.HTTPSAddr = getStringEnv("HTTPS_ADDR", utils.DEFAULT_HTTPS_ADDR)
+	cfg.TLSMode = getStringEnv("TLS_MODE", utils.TLS_MODE_OFF)
+	cfg.TLSCertFile = os.Getenv("TLS_CERT_FILE")
+	cfg.TLSKeyFile = os.Getenv("TLS_KEY_FILE")
+
+	if cfg.HSTSMaxAge, err = getIntEnv("HSTS_MAX_AGE", utils.DEFAULT_HSTS_MAX_AGE_SEC, 0, 2*utils.DEFAULT_HSTS_MAX_AGE_SEC); err != nil {
+		return nil, err
+	}
+
+	// Report-only sends the policy without enforcing it, for trying out changes in production
+	cfg.CSPReportOnly = os.Getenv("CSP_REPORT_ONLY") == "true"
+
+	for _, domain := range strings.Split(os.Getenv("ACME_DOMAINS"), ",") {
+		if domain = strings.TrimSpace(domain); domain != "" {
+			cfg.ACMEDomains = append(cfg.ACMEDomains, domain)
+		}
+	}
+
+	cfg.ACMEEmail = os.Getenv("ACME_EMAIL")
+	cfg.ACMECacheDir = getStringEnv("ACME_CACHE_DIR", utils.DEFAULT_ACME_CACHE_DIR)
+	cfg.ACMEDirectoryURL = os.Getenv("ACME_DIRECTORY_URL")
+	cfg.ACMECAFile = os.Getenv("ACME_CA_FILE")
+
+	switch cfg.TLSMode {
+	case utils.TLS_MODE_OFF:
+	case utils.TLS_MODE_FILES:
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return nil, fmt.Errorf("TLS_MODE=%s needs TLS_CERT_FILE and TLS_KEY_FILE", utils.TLS_MODE_FILES)
+		}
+	case utils.TLS_MODE_ACME:
+		if len(cfg.ACMEDomains) == 0 {
+			return nil, fmt.Errorf("TLS_MODE=%s needs ACME_DOMAINS", utils.TLS_MODE_ACME)
+		}
+	default:
+		return nil, fmt.Errorf("TLS_MODE must be %q, %q or %q", utils.TLS_MODE_OFF, utils.TLS_MODE_FILES, utils.TLS_MODE_ACME)
+	}
+
+	return cfg, nil
+}
+
+func (cfg *Config) DatabaseDSN() string {
+	// Format the MySQL connection string
+	return fmt.Sprintf("%s:%s@tcp(%s:3306)/%s", cfg.MySQLUser, cfg.MySQLPassword, cfg.MySQLHost, cfg.MySQLDB)
+}
+
+func getIntEnv(name string, fallback, min, max int) (int, error) {
+	raw := os.Getenv(name)
+
+	if raw == "" {
+		return fallback, nil
+	}
+
+	value, err := strconv.Atoi(raw)
+
+	if err != nil || value < min || value > max {
+		return 0, fmt.Errorf("%s must be a number between %d and %d", name, min, max)
+	}
+
+	return value, nil
+}
+
+func getStringEnv(name, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	}
+
+	return fallback
+}
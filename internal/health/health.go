@@ -0,0 +1,41 @@
+package health
+
+import (
+	"App/internal/migrations"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"fmt"
+	"time"
+)
+
+func Ready(ctx context.Context, app *types.App) (map[string]string, bool) {
+	ctx, cancel := context.WithTimeout(ctx, utils.READINESS_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	checks := map[string]string{"database": "ok", "migrations": "ok"}
+
+	// Without a database connection there is nothing else worth checking
+	if err := app.Database.PingContext(ctx); err != nil {
+		app.Logger.WarnContext(ctx, "Readiness check failed to reach the database", "error", err)
+		checks["database"] = "unreachable"
+		checks["migrations"] = "unknown"
+		return checks, false
+	}
+
+	// A new binary running against an old schema would fail on its first query
+	pending, err := migrations.Pending(ctx, app.Database)
+
+	if err != nil {
+		app.Logger.WarnContext(ctx, "Readiness check failed to read migrations", "error", err)
+		checks["migrations"] = "unknown"
+		return checks, false
+	}
+
+	if len(pending) > 0 {
+		checks["migrations"] = fmt.Sprintf("%d pending", len(pending))
+		return checks, false
+	}
+
+	return checks, true
+}
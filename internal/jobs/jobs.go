@@ -0,0 +1,72 @@
+package jobs
+
+import (
+	"App/internal/tracing"
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+type Runner struct {
+	logger  *slog.Logger
+	running sync.WaitGroup // Jobs that haven't stopped yet
+}
+
+func NewRunner(logger *slog.Logger) *Runner {
+	return &Runner{logger: logger}
+}
+
+func (r *Runner) RunPeriodically(ctx context.Context, name string, interval time.Duration, task func(ctx context.Context) error) {
+	r.running.Add(1)
+
+	go func() {
+		defer r.running.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Run once right away so results are available before the first tick
+		r.runTask(ctx, name, task)
+
+		for {
+			select {
+			case <-ctx.Done():
+				r.logger.InfoContext(ctx, "Background job stopped", "job", name)
+				return
+			case <-ticker.C:
+				r.runTask(ctx, name, task)
+			}
+		}
+	}()
+}
+
+func (r *Runner) Wait() {
+	// Block until every job has seen its context end & finished its current run
+	r.running.Wait()
+}
+
+func (r *Runner) runTask(ctx context.Context, name string, task func(ctx context.Context) error) {
+	// Keep a panicking job from taking down the server
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			r.logger.ErrorContext(ctx, "Background job panicked", "job", name, "panic", recovered)
+		}
+	}()
+
+	// Each run gets its own trace so its queries show up together
+	ctx, span := tracing.Start(ctx, "job "+name)
+	defer span.End()
+
+	start := time.Now()
+
+	if err := task(ctx); err != nil {
+		span.SetStatus(codes.Error, err.Error())
+		r.logger.ErrorContext(ctx, "Background job failed", "job", name, "error", err)
+		return
+	}
+
+	r.logger.InfoContext(ctx, "Background job finished", "job", name, "duration", time.Since(start))
+}
@@ -0,0 +1,183 @@
+package logging
+
+import (
+	"App/internal/config"
+	"App/internal/tracing"
+	"App/internal/utils"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+type requestIDKey struct{}
+
+var sensitiveKeys = map[string]bool{ // Attributes whose values never reach the log output
+	"username": true,
+	"password": true,
+	"token":    true,
+	"cookie":   true,
+	"email":    true,
+}
+
+func Setup(cfg *config.Config) (io.Closer, error) {
+	level, err := parseLevel(cfg.LogLevel)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var output io.WriteCloser = nopCloser{os.Stdout}
+
+	if cfg.LogOutput == utils.LOG_OUTPUT_FILE {
+		// The file is rotated once it grows too big & old copies are compressed
+		output = &lumberjack.Logger{
+			Filename:   cfg.LogFile,
+			MaxSize:    cfg.LogMaxSizeMB,
+			MaxBackups: cfg.LogMaxBackups,
+			MaxAge:     cfg.LogMaxAgeDays,
+			Compress:   true,
+		}
+	}
+
+	handler := slog.NewJSONHandler(output, &slog.HandlerOptions{
+		Level:       level,
+		ReplaceAttr: redactAttr,
+	})
+
+	// Anything still written through the log package ends up as JSON too
+	slog.SetDefault(slog.New(&contextHandler{handler}))
+	log.SetFlags(0)
+
+	return output, nil
+}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
+
+func NewHandler(handler slog.Handler) slog.Handler {
+	// The handler from Setup already tags & redacts its records
+	if _, ok := handler.(*contextHandler); ok {
+		return handler
+	}
+
+	// Any other handler gets the request & trace IDs and never sees a sensitive value
+	return &contextHandler{&redactingHandler{handler}}
+}
+
+func Redact(value string) string {
+	if value == "" {
+		return ""
+	}
+
+	return utils.REDACTED
+}
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(attr.Key)] {
+		return slog.String(attr.Key, Redact(attr.Value.String()))
+	}
+
+	return attr
+}
+
+func parseLevel(raw string) (slog.Level, error) {
+	var level slog.Level
+
+	if err := level.UnmarshalText([]byte(raw)); err != nil {
+		return 0, fmt.Errorf("LOG_LEVEL must be debug, info, warn or error")
+	}
+
+	return level, nil
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	// Tag every record logged during a request with that request's ID
+	if requestID := RequestID(ctx); requestID != "" {
+		record.AddAttrs(slog.String(utils.REQUEST_ID_ATTR, requestID))
+	}
+
+	// Link the line to its trace when tracing is on
+	if traceID := tracing.TraceID(ctx); traceID != "" {
+		record.AddAttrs(slog.String(utils.TRACE_ID_ATTR, traceID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{h.Handler.WithGroup(name)}
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+type redactingHandler struct {
+	slog.Handler
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	// Records can't drop attributes, so copy them over with the sensitive values replaced
+	redacted := slog.NewRecord(record.Time, record.Level, record.Message, record.PC)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(redactAll(attr))
+		return true
+	})
+
+	return h.Handler.Handle(ctx, redacted)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, len(attrs))
+
+	for i, attr := range attrs {
+		redacted[i] = redactAll(attr)
+	}
+
+	return &redactingHandler{h.Handler.WithAttrs(redacted)}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{h.Handler.WithGroup(name)}
+}
+
+func redactAll(attr slog.Attr) slog.Attr {
+	attr.Value = attr.Value.Resolve()
+
+	// Look inside groups too, the JSON handler's ReplaceAttr does the same
+	if attr.Value.Kind() == slog.KindGroup {
+		group := attr.Value.Group()
+		redacted := make([]any, len(group))
+
+		for i, member := range group {
+			redacted[i] = redactAll(member)
+		}
+
+		return slog.Group(attr.Key, redacted...)
+	}
+
+	return redactAttr(nil, attr)
+}
@@ -0,0 +1,65 @@
+package mediaservice
+
+import (
+	"App/internal/types"
+	"App/internal/utils"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"time"
+)
+
+func encryptBlob(app *types.App, data []byte, userID int) ([]byte, error) {
+	defer app.Metrics.ObserveCrypto(utils.CRYPTO_MEDIA_ENCRYPT, time.Now())
+
+	gcm, err := userCipher(app, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Prefix the ciphertext with its random nonce
+	nonce := make([]byte, gcm.NonceSize())
+
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	return gcm.Seal(nonce, nonce, data, nil), nil
+}
+
+func decryptBlob(app *types.App, data []byte, userID int) ([]byte, error) {
+	defer app.Metrics.ObserveCrypto(utils.CRYPTO_MEDIA_DECRYPT, time.Now())
+
+	gcm, err := userCipher(app, userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) < gcm.NonceSize() {
+		return nil, fmt.Errorf("encrypted media is too short")
+	}
+
+	nonce, ciphertext := data[:gcm.NonceSize()], data[gcm.NonceSize():]
+
+	return gcm.Open(nil, nonce, ciphertext, nil)
+}
+
+func userCipher(app *types.App, userID int) (cipher.AEAD, error) {
+	// Private media uses the same per-user key as private posts
+	key, err := app.KeyCache.Get(userID)
+
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
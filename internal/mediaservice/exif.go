@@ -0,0 +1,132 @@
+package mediaservice
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+)
+
+func jpegOrientation(data []byte) int {
+	// Walk the JPEG segments looking for the APP1 block that holds EXIF data
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return 1
+	}
+
+	offset := 2
+
+	for offset+4 <= len(data) {
+		if data[offset] != 0xFF {
+			return 1
+		}
+
+		marker := data[offset+1]
+		length := int(binary.BigEndian.Uint16(data[offset+2:]))
+
+		// Image data starts at the start of scan marker, metadata never follows it
+		if marker == 0xDA || length < 2 || offset+2+length > len(data) {
+			return 1
+		}
+
+		segment := data[offset+4 : offset+2+length]
+
+		if marker == 0xE1 && bytes.HasPrefix(segment, []byte("Exif\x00\x00")) {
+			return tiffOrientation(segment[6:])
+		}
+
+		offset += 2 + length
+	}
+
+	return 1
+}
+
+func tiffOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+
+	// TIFF headers say which byte order the rest of the block uses
+	var order binary.ByteOrder
+
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+
+	if ifd+2 > len(tiff) {
+		return 1
+	}
+
+	entries := int(order.Uint16(tiff[ifd:]))
+
+	for i := 0; i < entries; i++ {
+		entry := ifd + 2 + i*12
+
+		if entry+12 > len(tiff) {
+			return 1
+		}
+
+		// Tag 0x0112 is the orientation, stored as a short in the value field
+		if order.Uint16(tiff[entry:]) == 0x0112 {
+			orientation := int(order.Uint16(tiff[entry+8:]))
+
+			if orientation < 1 || orientation > 8 {
+				return 1
+			}
+
+			return orientation
+		}
+	}
+
+	return 1
+}
+
+func applyOrientation(source image.Image, orientation int) image.Image {
+	if orientation <= 1 || orientation > 8 {
+		return source
+	}
+
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Orientations 5 to 8 swap the width & height
+	outputWidth, outputHeight := width, height
+
+	if orientation >= 5 {
+		outputWidth, outputHeight = height, width
+	}
+
+	output := image.NewRGBA(image.Rect(0, 0, outputWidth, outputHeight))
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			var targetX, targetY int
+
+			switch orientation {
+			case 2:
+				targetX, targetY = width-1-x, y
+			case 3:
+				targetX, targetY = width-1-x, height-1-y
+			case 4:
+				targetX, targetY = x, height-1-y
+			case 5:
+				targetX, targetY = y, x
+			case 6:
+				targetX, targetY = height-1-y, x
+			case 7:
+				targetX, targetY = height-1-y, width-1-x
+			case 8:
+				targetX, targetY = y, width-1-x
+			}
+
+			output.Set(targetX, targetY, source.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return output
+}
@@ -0,0 +1,129 @@
+package mediaservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"bytes"
+	"context"
+	"fmt"
+	"image"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// Only formats that browsers display & Go can decode are accepted
+var allowedContentTypes = map[string]bool{
+	"image/jpeg": true,
+	"image/png":  true,
+	"image/gif":  true,
+	"image/webp": true,
+}
+
+func ProcessImage(ctx context.Context, data []byte) (*types.ProcessedImage, error) {
+	_, span := tracing.Start(ctx, "mediaservice.ProcessImage")
+	defer span.End()
+
+	if len(data) > utils.MEDIA_MAX_UPLOAD_BYTES {
+		return nil, utils.Validation("images must be smaller than %d MB", utils.MEDIA_MAX_UPLOAD_BYTES>>20)
+	}
+
+	// Trust the file's contents, never its name or the browser's content type
+	contentType := http.DetectContentType(data)
+
+	if !allowedContentTypes[contentType] {
+		return nil, utils.Validation("images must be JPEG, PNG, GIF or WebP files")
+	}
+
+	// Check the dimensions before decoding so huge images can't exhaust memory
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, utils.Validation("image could not be read")
+	}
+
+	if config.Width > utils.MEDIA_MAX_DIMENSION || config.Height > utils.MEDIA_MAX_DIMENSION || config.Width*config.Height > utils.MEDIA_MAX_PIXELS {
+		return nil, utils.Validation("images must be at most %d pixels wide and high", utils.MEDIA_MAX_DIMENSION)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return nil, utils.Validation("image could not be read")
+	}
+
+	// Re-encoding drops EXIF, so bake the camera's rotation into the pixels first
+	if contentType == "image/jpeg" {
+		source = applyOrientation(source, jpegOrientation(data))
+	}
+
+	full := fitWithin(source, utils.MEDIA_MAX_WIDTH)
+	thumbnail := fitWithin(source, utils.MEDIA_THUMBNAIL_SIZE)
+
+	// Photos stay JPEG, everything else becomes a PNG (animated GIFs keep their first frame)
+	encode, outputType := encodePNG, "image/png"
+
+	if contentType == "image/jpeg" {
+		encode, outputType = encodeJPEG, "image/jpeg"
+	}
+
+	processed := &types.ProcessedImage{
+		ContentType: outputType,
+		Width:       full.Bounds().Dx(),
+		Height:      full.Bounds().Dy(),
+	}
+
+	if processed.Data, err = encode(full); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+
+	if processed.Thumbnail, err = encode(thumbnail); err != nil {
+		return nil, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return processed, nil
+}
+
+func fitWithin(source image.Image, maxSide int) image.Image {
+	bounds := source.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	// Always copy, even when no scaling is needed, so nothing of the original survives
+	if width > maxSide || height > maxSide {
+		if width >= height {
+			width, height = maxSide, max(1, height*maxSide/width)
+		} else {
+			width, height = max(1, width*maxSide/height), maxSide
+		}
+	}
+
+	resized := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.CatmullRom.Scale(resized, resized.Bounds(), source, bounds, draw.Src, nil)
+
+	return resized
+}
+
+func encodeJPEG(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := jpeg.Encode(&buffer, img, &jpeg.Options{Quality: utils.MEDIA_JPEG_QUALITY}); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func encodePNG(img image.Image) ([]byte, error) {
+	var buffer bytes.Buffer
+
+	if err := png.Encode(&buffer, img); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
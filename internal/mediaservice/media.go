@@ -0,0 +1,482 @@
+package mediaservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"regexp"
+	"time"
+)
+
+var tokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+func UploadMedia(ctx context.Context, app *types.App, userID int, data []byte, isPublic bool) (*types.Media, error) {
+	ctx, span := tracing.Start(ctx, "mediaservice.UploadMedia")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	processed, err := ProcessImage(ctx, data)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Images for private posts can only be stored once the owner's key is available
+	if !isPublic && !app.KeyCache.Has(userID) {
+		return nil, utils.Unauthorized("please log in again to upload private images")
+	}
+
+	token, err := newToken()
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to generate media token", "error", err)
+		return nil, utils.Internal(err, "failed to store image")
+	}
+
+	media := &types.Media{
+		Token:       token,
+		UserID:      userID,
+		ContentType: processed.ContentType,
+		Width:       processed.Width,
+		Height:      processed.Height,
+		Size:        len(processed.Data),
+		IsEncrypted: !isPublic,
+	}
+
+	if err := putBlobs(ctx, app, media, processed.Data, processed.Thumbnail); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to store media", "user_id", userID, "error", err)
+		return nil, utils.Internal(err, "failed to store image")
+	}
+
+	result, err := app.Database.ExecContext(ctx, utils.InsertMediaQuery, media.Token, userID, media.ContentType, media.Width, media.Height,
+		media.Size, media.IsEncrypted, app.Clock.Now().UTC().Format("2006-01-02 15:04:05"))
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while saving media", "user_id", userID, "error", err)
+		deleteBlobs(ctx, app, media)
+		return nil, utils.DatabaseError(ctx, err, "failed to save image")
+	}
+
+	if id, err := result.LastInsertId(); err == nil {
+		media.ID = int(id)
+	}
+
+	setURLs(media)
+
+	return media, nil
+}
+
+func ReadMedia(ctx context.Context, app *types.App, token string, thumbnail bool, viewerID int) ([]byte, *types.Media, error) {
+	ctx, span := tracing.Start(ctx, "mediaservice.ReadMedia")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	media, err := GetMediaByToken(ctx, app.Database, token)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Encrypted media is only ever shown to its owner, everyone else is told it doesn't exist
+	if media.IsEncrypted && media.UserID != viewerID {
+		return nil, nil, utils.NotFound("media not found")
+	}
+
+	// Other media follows the visibility of the post it is attached to
+	if !media.IsEncrypted && media.PostID != 0 {
+		var visibility string
+
+		if err := app.Database.QueryRowContext(ctx, utils.SelectPostVisibilityForViewerQuery, media.PostID, viewerID, viewerID, viewerID, viewerID, viewerID, viewerID).Scan(&visibility); err != nil {
+			if err == sql.ErrNoRows {
+				return nil, nil, utils.NotFound("media not found")
+			}
+
+			app.Logger.ErrorContext(ctx, "SQL query error while checking access to media", "token", media.Token, "error", err)
+			return nil, nil, utils.DatabaseError(ctx, err, "failed to check access to media")
+		}
+
+		media.IsRestricted = visibility == utils.VISIBILITY_FOLLOWERS
+	}
+
+	key := blobKey(media.Token)
+
+	if thumbnail {
+		key = thumbnailKey(media.Token)
+	}
+
+	data, err := app.MediaStore.Get(ctx, key)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to read media", "token", media.Token, "error", err)
+		return nil, nil, utils.NotFound("media not found")
+	}
+
+	if media.IsEncrypted {
+		if data, err = decryptBlob(app, data, media.UserID); err != nil {
+			app.Logger.ErrorContext(ctx, "Failed to decrypt media", "token", media.Token, "error", err)
+			return nil, nil, utils.Internal(err, "encryption error: failed to decrypt image")
+		}
+	}
+
+	return data, media, nil
+}
+
+func GetMediaByToken(ctx context.Context, db types.Querier, token string) (*types.Media, error) {
+	ctx, span := tracing.Start(ctx, "mediaservice.GetMediaByToken")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Reject anything that can't be a token before touching the database
+	if !tokenPattern.MatchString(token) {
+		return nil, utils.NotFound("media not found")
+	}
+
+	media, err := scanMedia(db.QueryRowContext(ctx, utils.SelectMediaByTokenQuery, token))
+
+	if err == sql.ErrNoRows {
+		return nil, utils.NotFound("media not found")
+	}
+
+	if err != nil {
+		return nil, utils.DatabaseError(ctx, err, "failed to load media")
+	}
+
+	return media, nil
+}
+
+func GetMediaForPost(ctx context.Context, db types.Querier, postID int) ([]*types.Media, error) {
+	ctx, span := tracing.Start(ctx, "mediaservice.GetMediaForPost")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	return queryMedia(ctx, db, utils.SelectMediaByPostQuery, postID)
+}
+
+func GetMediaOfUser(ctx context.Context, app *types.App, userID int) ([]*types.Media, error) {
+	ctx, span := tracing.Start(ctx, "mediaservice.GetMediaOfUser")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	return queryMedia(ctx, app.Database, utils.SelectMediaByUserQuery, userID)
+}
+
+type PostMedia struct {
+	app       *types.App
+	userID    int
+	tokens    []string
+	isPublic  bool
+	converted []*types.Media // Blobs switched to the post's visibility, switched back if the post isn't saved
+	removed   []*types.Media // Rows deleted in the post's transaction, their blobs go once it commits
+}
+
+func NewPostMedia(app *types.App, userID int, tokens []string, isPublic bool) *PostMedia {
+	return &PostMedia{app: app, userID: userID, tokens: tokens, isPublic: isPublic}
+}
+
+func (m *PostMedia) Attach(ctx context.Context, tx *sql.Tx, postID int) error {
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	app, userID, isPublic := m.app, m.userID, m.isPublic
+
+	current, err := GetMediaForPost(ctx, tx, postID)
+
+	if err != nil {
+		return err
+	}
+
+	// Keep the editor's order, skipping duplicates & anything past the limit
+	keep := make(map[string]bool)
+
+	for _, token := range m.tokens {
+		if keep[token] {
+			continue
+		}
+
+		if len(keep) == utils.MEDIA_MAX_PER_POST {
+			return utils.Validation("posts can have at most %d images", utils.MEDIA_MAX_PER_POST)
+		}
+
+		media, err := GetMediaByToken(ctx, tx, token)
+
+		if err != nil {
+			return err
+		}
+
+		// Only the uploader's unattached images or ones already on this post can be used
+		if media.UserID != userID || (media.PostID != 0 && media.PostID != postID) {
+			return utils.NotFound("media not found")
+		}
+
+		// Re-encrypt or decrypt the blobs when the post's visibility doesn't match
+		if media.IsEncrypted == isPublic {
+			if err := setEncryption(ctx, app, media, !isPublic); err != nil {
+				app.Logger.ErrorContext(ctx, "Failed to change encryption of media", "token", media.Token, "error", err)
+				return utils.Internal(err, "failed to update image privacy")
+			}
+
+			m.converted = append(m.converted, media)
+		}
+
+		if _, err := tx.ExecContext(ctx, utils.AttachMediaQuery, postID, !isPublic, media.ID, userID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while attaching media", "token", media.Token, "post_id", postID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to attach image")
+		}
+
+		keep[token] = true
+	}
+
+	// Images removed in the editor are deleted for good
+	for _, media := range current {
+		if keep[media.Token] {
+			continue
+		}
+
+		if _, err := tx.ExecContext(ctx, utils.DeleteMediaQuery, media.ID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while deleting media", "token", media.Token, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to delete image")
+		}
+
+		m.removed = append(m.removed, media)
+	}
+
+	return nil
+}
+
+func (m *PostMedia) Finish(ctx context.Context, committed bool) {
+	if committed {
+		for _, media := range m.removed {
+			deleteBlobs(ctx, m.app, media)
+		}
+
+		return
+	}
+
+	// The rows kept their old flags, so put the blobs back the way those flags describe
+	for _, media := range m.converted {
+		if err := setEncryption(ctx, m.app, media, !media.IsEncrypted); err != nil {
+			m.app.Logger.ErrorContext(ctx, "Failed to restore encryption of media", "token", media.Token, "error", err)
+		}
+	}
+}
+
+func DeleteMedia(ctx context.Context, app *types.App, media []*types.Media) error {
+	ctx, span := tracing.Start(ctx, "mediaservice.DeleteMedia")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	for _, item := range media {
+		if _, err := app.Database.ExecContext(ctx, utils.DeleteMediaQuery, item.ID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while deleting media", "token", item.Token, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to delete image")
+		}
+
+		// A blob left behind is harmless once its row is gone, so only log failures
+		deleteBlobs(ctx, app, item)
+	}
+
+	return nil
+}
+
+func DeleteUnattachedMedia(ctx context.Context, app *types.App) error {
+	ctx, span := tracing.Start(ctx, "mediaservice.DeleteUnattachedMedia")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Uploads never saved with a post, or whose post was deleted, expire after a grace period
+	cutoff := app.Clock.Now().UTC().Add(-utils.MEDIA_ORPHAN_HOURS * time.Hour).Format("2006-01-02 15:04:05")
+
+	media, err := queryMedia(ctx, app.Database, utils.SelectUnattachedMediaQuery, cutoff)
+
+	if err != nil {
+		return err
+	}
+
+	if err := DeleteMedia(ctx, app, media); err != nil {
+		return err
+	}
+
+	if len(media) > 0 {
+		app.Logger.InfoContext(ctx, "Deleted unattached media files", "count", len(media))
+	}
+
+	return nil
+}
+
+func queryMedia(ctx context.Context, db types.Querier, query string, args ...any) ([]*types.Media, error) {
+	rows, err := db.QueryContext(ctx, query, args...)
+
+	if err != nil {
+		return nil, utils.DatabaseError(ctx, err, "failed to load media")
+	}
+
+	defer rows.Close()
+
+	var media []*types.Media
+
+	for rows.Next() {
+		item, err := scanMedia(rows)
+
+		if err != nil {
+			return nil, utils.DatabaseError(ctx, err, "failed to load media")
+		}
+
+		media = append(media, item)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, utils.DatabaseError(ctx, err, "failed to load media")
+	}
+
+	return media, nil
+}
+
+func scanMedia(row interface{ Scan(...any) error }) (*types.Media, error) {
+	media := &types.Media{}
+
+	if err := row.Scan(&media.ID, &media.Token, &media.UserID, &media.PostID, &media.ContentType,
+		&media.Width, &media.Height, &media.Size, &media.IsEncrypted); err != nil {
+		return nil, err
+	}
+
+	setURLs(media)
+
+	return media, nil
+}
+
+func setEncryption(ctx context.Context, app *types.App, media *types.Media, encrypt bool) error {
+	ctx, span := tracing.Start(ctx, "mediaservice.setEncryption")
+	defer span.End()
+
+	full, err := app.MediaStore.Get(ctx, blobKey(media.Token))
+
+	if err != nil {
+		return err
+	}
+
+	thumbnail, err := app.MediaStore.Get(ctx, thumbnailKey(media.Token))
+
+	if err != nil {
+		return err
+	}
+
+	// Blobs are stored as they are currently flagged, convert them to the other form
+	convert := encryptBlob
+
+	if !encrypt {
+		convert = decryptBlob
+	}
+
+	if full, err = convert(app, full, media.UserID); err != nil {
+		return err
+	}
+
+	if thumbnail, err = convert(app, thumbnail, media.UserID); err != nil {
+		return err
+	}
+
+	if err := app.MediaStore.Put(ctx, blobKey(media.Token), full, blobContentType(media.ContentType, encrypt)); err != nil {
+		return err
+	}
+
+	if err := app.MediaStore.Put(ctx, thumbnailKey(media.Token), thumbnail, blobContentType(media.ContentType, encrypt)); err != nil {
+		return err
+	}
+
+	media.IsEncrypted = encrypt
+
+	return nil
+}
+
+func putBlobs(ctx context.Context, app *types.App, media *types.Media, full, thumbnail []byte) error {
+	ctx, span := tracing.Start(ctx, "mediaservice.putBlobs")
+	defer span.End()
+
+	var err error
+
+	if media.IsEncrypted {
+		if full, err = encryptBlob(app, full, media.UserID); err != nil {
+			return err
+		}
+
+		if thumbnail, err = encryptBlob(app, thumbnail, media.UserID); err != nil {
+			return err
+		}
+	}
+
+	contentType := blobContentType(media.ContentType, media.IsEncrypted)
+
+	if err := app.MediaStore.Put(ctx, blobKey(media.Token), full, contentType); err != nil {
+		return err
+	}
+
+	if err := app.MediaStore.Put(ctx, thumbnailKey(media.Token), thumbnail, contentType); err != nil {
+		deleteBlobs(ctx, app, media)
+		return err
+	}
+
+	return nil
+}
+
+func deleteBlobs(ctx context.Context, app *types.App, media *types.Media) {
+	ctx, span := tracing.Start(ctx, "mediaservice.deleteBlobs")
+	defer span.End()
+
+	for _, key := range []string{blobKey(media.Token), thumbnailKey(media.Token)} {
+		if err := app.MediaStore.Delete(ctx, key); err != nil {
+			app.Logger.ErrorContext(ctx, "Failed to delete media blob", "key", key, "error", err)
+		}
+	}
+}
+
+func blobContentType(contentType string, encrypted bool) string {
+	// Encrypted blobs must never be served as images straight from a bucket
+	if encrypted {
+		return "application/octet-stream"
+	}
+
+	return contentType
+}
+
+func blobKey(token string) string {
+	// Spread blobs over folders so no single directory grows too large
+	return fmt.Sprintf("media/%s/%s", token[:2], token)
+}
+
+func thumbnailKey(token string) string {
+	return blobKey(token) + "_thumb"
+}
+
+func setURLs(media *types.Media) {
+	media.URL = "/media/" + media.Token
+	media.ThumbnailURL = "/media/" + media.Token + "/thumbnail"
+}
+
+func newToken() (string, error) {
+	raw := make([]byte, 16)
+
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(raw), nil
+}
@@ -0,0 +1,114 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "posto"
+
+type Metrics struct {
+	registry        *prometheus.Registry
+	requestDuration *prometheus.HistogramVec
+	requestsTotal   *prometheus.CounterVec
+	rateLimitBlocks prometheus.Counter
+	keyCacheLookups *prometheus.CounterVec
+	cryptoDuration  *prometheus.HistogramVec
+}
+
+func New() *Metrics {
+	// Each server gets its own registry & collectors, so two servers in one process never share counts
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time taken to handle HTTP requests, by route.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+
+		requestsTotal: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests handled, by route and status code.",
+		}, []string{"method", "route", "status"}),
+
+		rateLimitBlocks: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "rate_limit_blocks_total",
+			Help:      "IPs blocked for exceeding the rate limit.",
+		}),
+
+		keyCacheLookups: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "key_cache_lookups_total",
+			Help:      "Lookups of user encryption keys in the key cache, by result.",
+		}, []string{"result"}),
+
+		cryptoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "crypto_duration_seconds",
+			Help:      "Time taken by encryption, decryption and key derivation.",
+			// Most AES operations finish in microseconds, Argon2 takes tens of milliseconds
+			Buckets: prometheus.ExponentialBuckets(0.00001, 4, 10),
+		}, []string{"operation"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requestDuration,
+		m.requestsTotal,
+		m.rateLimitBlocks,
+		m.keyCacheLookups,
+		m.cryptoDuration,
+	)
+
+	return m
+}
+
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+func (m *Metrics) RegisterDB(db *sql.DB) {
+	// Open, idle & in-use connections plus time spent waiting for one
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, namespace))
+}
+
+func (m *Metrics) RegisterGauge(name, help string, value func() float64) {
+	m.registry.MustRegister(prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      name,
+		Help:      help,
+	}, value))
+}
+
+func (m *Metrics) ObserveRequest(method, route string, status int, elapsed time.Duration) {
+	m.requestDuration.WithLabelValues(method, route).Observe(elapsed.Seconds())
+	m.requestsTotal.WithLabelValues(method, route, strconv.Itoa(status)).Inc()
+}
+
+func (m *Metrics) RateLimitBlocked() {
+	m.rateLimitBlocks.Inc()
+}
+
+func (m *Metrics) KeyCacheLookup(found bool) {
+	if found {
+		m.keyCacheLookups.WithLabelValues("hit").Inc()
+		return
+	}
+
+	m.keyCacheLookups.WithLabelValues("miss").Inc()
+}
+
+func (m *Metrics) ObserveCrypto(operation string, start time.Time) {
+	m.cryptoDuration.WithLabelValues(operation).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,275 @@
+package migrations
+
+import (
+	"App/internal/types"
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+//go:embed sql/*.sql
+var migrationFiles embed.FS
+
+const (
+	createMigrationsTableQuery = `
+        CREATE TABLE IF NOT EXISTS Schema_Migrations (
+            Version VARCHAR(100) NOT NULL PRIMARY KEY,
+            AppliedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+        )`
+
+	// MySQL commits each DDL statement on its own, so progress inside a migration is recorded per statement
+	createMigrationStepsTableQuery = `
+        CREATE TABLE IF NOT EXISTS Schema_Migration_Steps (
+            Version VARCHAR(100) NOT NULL,
+            Step INT NOT NULL,
+            PRIMARY KEY (Version, Step)
+        )`
+
+	selectAppliedMigrationsQuery = `SELECT Version FROM Schema_Migrations`
+	insertMigrationQuery         = `INSERT INTO Schema_Migrations (Version) VALUES (?)`
+	selectAppliedStepsQuery      = `SELECT Step FROM Schema_Migration_Steps WHERE Version = ?`
+	insertMigrationStepQuery     = `INSERT INTO Schema_Migration_Steps (Version, Step) VALUES (?, ?)`
+	deleteMigrationStepsQuery    = `DELETE FROM Schema_Migration_Steps WHERE Version = ?`
+)
+
+func Apply(db *sql.DB) error {
+	// Make sure the bookkeeping tables exist before reading from them
+	if _, err := db.Exec(createMigrationsTableQuery); err != nil {
+		return fmt.Errorf("failed to create migrations table: %w", err)
+	}
+
+	if _, err := db.Exec(createMigrationStepsTableQuery); err != nil {
+		return fmt.Errorf("failed to create migration steps table: %w", err)
+	}
+
+	applied, err := appliedVersions(context.Background(), db)
+
+	if err != nil {
+		return err
+	}
+
+	versions, err := availableVersions()
+
+	if err != nil {
+		return err
+	}
+
+	// Run every migration that has not been recorded yet, in file name order
+	for _, version := range versions {
+		if applied[version] {
+			continue
+		}
+
+		if err := applyMigration(db, version); err != nil {
+			return err
+		}
+
+		slog.Info("Applied database migration", "version", version)
+	}
+
+	return nil
+}
+
+func Pending(ctx context.Context, db types.DB) ([]string, error) {
+	applied, err := appliedVersions(ctx, db)
+
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := availableVersions()
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Versions shipped with this binary that the database hasn't recorded yet
+	var pending []string
+
+	for _, version := range versions {
+		if !applied[version] {
+			pending = append(pending, version)
+		}
+	}
+
+	return pending, nil
+}
+
+func appliedVersions(ctx context.Context, db types.DB) (map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, selectAppliedMigrationsQuery)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read applied migrations: %w", err)
+	}
+
+	defer rows.Close()
+
+	applied := make(map[string]bool)
+
+	for rows.Next() {
+		var version string
+
+		if err := rows.Scan(&version); err != nil {
+			return nil, fmt.Errorf("failed to scan applied migration: %w", err)
+		}
+
+		applied[version] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate applied migrations: %w", err)
+	}
+
+	return applied, nil
+}
+
+func availableVersions() ([]string, error) {
+	files, err := fs.Glob(migrationFiles, "sql/*.sql")
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to list migration files: %w", err)
+	}
+
+	// The version is the file name without directory or extension
+	versions := make([]string, len(files))
+
+	for i, file := range files {
+		versions[i] = strings.TrimSuffix(strings.TrimPrefix(file, "sql/"), ".sql")
+	}
+
+	sort.Strings(versions)
+
+	return versions, nil
+}
+
+func applyMigration(db *sql.DB, version string) error {
+	contents, err := migrationFiles.ReadFile("sql/" + version + ".sql")
+
+	if err != nil {
+		return fmt.Errorf("failed to read migration %s: %w", version, err)
+	}
+
+	// Statements that ran before an earlier attempt failed are skipped, the rest pick up where it stopped
+	done, err := appliedSteps(db, version)
+
+	if err != nil {
+		return err
+	}
+
+	// The MySQL driver runs a single statement per Exec, so split the file up
+	for step, statement := range splitStatements(string(contents)) {
+		if done[step] {
+			continue
+		}
+
+		if err := applyStep(db, version, step, statement); err != nil {
+			return fmt.Errorf("migration %s failed at statement %d: %w", version, step+1, err)
+		}
+	}
+
+	// Mark the whole migration applied & drop its per statement progress together
+	tx, err := db.Begin()
+
+	if err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(insertMigrationQuery, version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	if _, err := tx.Exec(deleteMigrationStepsQuery, version); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to record migration %s: %w", version, err)
+	}
+
+	return nil
+}
+
+func appliedSteps(db *sql.DB, version string) (map[int]bool, error) {
+	rows, err := db.Query(selectAppliedStepsQuery, version)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read progress of migration %s: %w", version, err)
+	}
+
+	defer rows.Close()
+
+	done := make(map[int]bool)
+
+	for rows.Next() {
+		var step int
+
+		if err := rows.Scan(&step); err != nil {
+			return nil, fmt.Errorf("failed to scan progress of migration %s: %w", version, err)
+		}
+
+		done[step] = true
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate progress of migration %s: %w", version, err)
+	}
+
+	return done, nil
+}
+
+func applyStep(db *sql.DB, version string, step int, statement string) error {
+	// Data changes commit together with their step, DDL commits itself right before the step is recorded
+	tx, err := db.Begin()
+
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(statement); err != nil {
+		return err
+	}
+
+	if _, err := tx.Exec(insertMigrationStepQuery, version, step); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func splitStatements(contents string) []string {
+	var statements []string
+	var current strings.Builder
+
+	for _, line := range strings.Split(contents, "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		// Skip blank lines and SQL comments
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") {
+			continue
+		}
+
+		current.WriteString(line)
+		current.WriteString("\n")
+
+		// A trailing semicolon ends the statement
+		if strings.HasSuffix(trimmed, ";") {
+			statements = append(statements, strings.TrimSuffix(strings.TrimSpace(current.String()), ";"))
+			current.Reset()
+		}
+	}
+
+	if rest := strings.TrimSpace(current.String()); rest != "" {
+		statements = append(statements, rest)
+	}
+
+	return statements
+}
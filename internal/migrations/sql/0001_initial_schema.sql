@@ -0,0 +1,50 @@
+-- Baseline schema for the tables that existed before migrations were tracked.
+-- Every statement is guarded so it is a no-op against an existing database.
+
+CREATE TABLE IF NOT EXISTS Users (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    Username VARCHAR(40) NOT NULL UNIQUE,
+    Password VARBINARY(60) NOT NULL,
+    Encryption_Salt VARBINARY(16) NOT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE IF NOT EXISTS Posts (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    UserID INT NOT NULL,
+    Title TEXT NOT NULL,
+    Content MEDIUMTEXT NOT NULL,
+    IsPublic BOOLEAN NOT NULL DEFAULT TRUE,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_posts_user_created (UserID, CreatedAt),
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Comments (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    PostID INT NOT NULL,
+    UserID INT NOT NULL,
+    Comment TEXT NOT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_comments_post (PostID),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE,
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS Likes (
+    UserID INT NOT NULL,
+    PostID INT NOT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (UserID, PostID),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE,
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS User_Follows (
+    follower_id INT NOT NULL,
+    following_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (follower_id, following_id),
+    FOREIGN KEY (follower_id) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (following_id) REFERENCES Users(ID) ON DELETE CASCADE
+);
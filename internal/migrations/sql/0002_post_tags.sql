@@ -0,0 +1,12 @@
+-- Tags for non-private posts live in PostTags so they can be queried.
+-- Tags on private posts are encrypted alongside the title and content.
+
+ALTER TABLE Posts ADD COLUMN EncryptedTags TEXT NULL;
+
+CREATE TABLE PostTags (
+    PostID INT NOT NULL,
+    Tag VARCHAR(30) NOT NULL,
+    PRIMARY KEY (PostID, Tag),
+    INDEX idx_post_tags_tag (Tag),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE
+);
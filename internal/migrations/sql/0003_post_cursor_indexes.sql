@@ -0,0 +1,5 @@
+-- Keyset pagination walks posts by (CreatedAt, ID), newest first.
+
+CREATE INDEX idx_posts_user_created_id ON Posts (UserID, CreatedAt, ID);
+
+CREATE INDEX idx_posts_created_id ON Posts (CreatedAt, ID);
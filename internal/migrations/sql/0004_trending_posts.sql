@@ -0,0 +1,12 @@
+-- Cached trending scores for recent public posts, rebuilt by a background job.
+
+CREATE TABLE TrendingPosts (
+    PostID INT NOT NULL PRIMARY KEY,
+    Score DOUBLE NOT NULL,
+    LikesCount INT NOT NULL DEFAULT 0,
+    CommentsCount INT NOT NULL DEFAULT 0,
+    PostCreatedAt DATETIME NOT NULL,
+    ComputedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_trending_score (Score),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE
+);
@@ -0,0 +1,4 @@
+-- Accounts waiting out the deletion grace period
+ALTER TABLE Users ADD COLUMN DeletionRequestedAt DATETIME NULL;
+
+CREATE INDEX idx_users_deletion_requested ON Users (DeletionRequestedAt);
@@ -0,0 +1,13 @@
+-- Editable profile details shown on profiles, bylines and comments
+ALTER TABLE Users ADD COLUMN DisplayName VARCHAR(50) NULL;
+ALTER TABLE Users ADD COLUMN Bio TEXT NULL;
+ALTER TABLE Users ADD COLUMN Links TEXT NULL;
+ALTER TABLE Users ADD COLUMN AvatarUpdatedAt DATETIME NULL;
+
+-- Avatars are re-encoded on upload and kept apart from the hot Users table
+CREATE TABLE IF NOT EXISTS UserAvatars (
+    UserID INT PRIMARY KEY,
+    Image MEDIUMBLOB NOT NULL,
+    ContentType VARCHAR(50) NOT NULL,
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE
+);
@@ -0,0 +1,18 @@
+-- Images uploaded for posts. The bytes live in the blob store under the token,
+-- private media is encrypted there with the owner's key.
+CREATE TABLE IF NOT EXISTS Media (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    Token CHAR(32) NOT NULL UNIQUE,
+    UserID INT NOT NULL,
+    PostID INT NULL,
+    ContentType VARCHAR(50) NOT NULL,
+    Width INT NOT NULL,
+    Height INT NOT NULL,
+    Size INT NOT NULL,
+    IsEncrypted BOOLEAN NOT NULL DEFAULT FALSE,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_media_post (PostID),
+    INDEX idx_media_unattached (PostID, CreatedAt),
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE SET NULL
+);
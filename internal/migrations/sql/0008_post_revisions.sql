@@ -0,0 +1,13 @@
+-- Every saved version of a post. Private revisions are encrypted like the post,
+-- Tags holds the comma separated tags (encrypted for private revisions).
+CREATE TABLE IF NOT EXISTS PostRevisions (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    PostID INT NOT NULL,
+    Title TEXT NOT NULL,
+    Content MEDIUMTEXT NOT NULL,
+    IsPublic BOOLEAN NOT NULL,
+    Tags TEXT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_revisions_post (PostID, ID),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE
+);
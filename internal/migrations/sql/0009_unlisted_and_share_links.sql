@@ -0,0 +1,21 @@
+-- Unlisted posts are public to anyone with the link but left out of every listing
+ALTER TABLE Posts ADD COLUMN IsUnlisted BOOLEAN NOT NULL DEFAULT FALSE;
+
+-- Share links for private posts. Title, Content & Tags are a copy of the post encrypted
+-- with the link's own key, which only travels in the URL fragment. LinkKey holds that
+-- key encrypted with the owner's key so the owner can show the link again & re-encrypt
+-- the copy whenever the post is edited.
+CREATE TABLE IF NOT EXISTS PostShareLinks (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    Token CHAR(32) NOT NULL UNIQUE,
+    PostID INT NOT NULL,
+    LinkKey TEXT NOT NULL,
+    Title TEXT NOT NULL,
+    Content MEDIUMTEXT NOT NULL,
+    Tags TEXT NOT NULL,
+    ExpiresAt DATETIME NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_share_links_post (PostID),
+    INDEX idx_share_links_expiry (ExpiresAt),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE
+);
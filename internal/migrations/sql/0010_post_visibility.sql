@@ -0,0 +1,16 @@
+-- Visibility replaces the IsPublic & IsUnlisted flags: 'public', 'unlisted', 'followers' or 'private'.
+-- Only private posts are encrypted, followers-only posts are checked against User_Follows instead.
+-- The old flags stay until 0013 so this step can be rolled back & binaries that still read them keep working.
+ALTER TABLE Posts ADD COLUMN Visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+
+UPDATE Posts SET Visibility = CASE
+    WHEN IsPublic = 0 THEN 'private'
+    WHEN IsUnlisted = 1 THEN 'unlisted'
+    ELSE 'public'
+END;
+
+CREATE INDEX idx_posts_visibility_created ON Posts (Visibility, CreatedAt);
+
+-- Revisions remember the visibility they were saved with, which also says if they are encrypted
+ALTER TABLE PostRevisions ADD COLUMN Visibility VARCHAR(10) NOT NULL DEFAULT 'public';
+UPDATE PostRevisions SET Visibility = CASE WHEN IsPublic = 0 THEN 'private' ELSE 'public' END;
@@ -0,0 +1,33 @@
+-- Private accounts approve every new follower, who waits in Follow_Requests until then
+ALTER TABLE Users ADD COLUMN IsPrivate BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE TABLE IF NOT EXISTS Follow_Requests (
+    requester_id INT NOT NULL,
+    target_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (requester_id, target_id),
+    INDEX idx_follow_requests_target (target_id, created_at),
+    FOREIGN KEY (requester_id) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (target_id) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+-- A block hides both users' posts & comments from each other and stops them following
+CREATE TABLE IF NOT EXISTS User_Blocks (
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (blocker_id, blocked_id),
+    INDEX idx_user_blocks_blocked (blocked_id),
+    FOREIGN KEY (blocker_id) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (blocked_id) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+-- A mute only keeps someone out of the muter's home feed
+CREATE TABLE IF NOT EXISTS User_Mutes (
+    muter_id INT NOT NULL,
+    muted_id INT NOT NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (muter_id, muted_id),
+    FOREIGN KEY (muter_id) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (muted_id) REFERENCES Users(ID) ON DELETE CASCADE
+);
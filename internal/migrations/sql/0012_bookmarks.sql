@@ -0,0 +1,24 @@
+-- Named reading lists a user can sort their bookmarks into
+CREATE TABLE IF NOT EXISTS BookmarkCollections (
+    ID INT AUTO_INCREMENT PRIMARY KEY,
+    UserID INT NOT NULL,
+    Name VARCHAR(50) NOT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE KEY uq_bookmark_collections_name (UserID, Name),
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE
+);
+
+-- Posts saved for later, at most once per user. Bookmarks are never shown to anyone else
+-- and are only listed while their post can still be read by the user who saved it.
+CREATE TABLE IF NOT EXISTS Bookmarks (
+    UserID INT NOT NULL,
+    PostID INT NOT NULL,
+    CollectionID INT NULL,
+    CreatedAt DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (UserID, PostID),
+    INDEX idx_bookmarks_user_created (UserID, CreatedAt),
+    INDEX idx_bookmarks_collection (CollectionID),
+    FOREIGN KEY (UserID) REFERENCES Users(ID) ON DELETE CASCADE,
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE,
+    FOREIGN KEY (CollectionID) REFERENCES BookmarkCollections(ID) ON DELETE SET NULL
+);
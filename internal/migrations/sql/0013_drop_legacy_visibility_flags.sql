@@ -0,0 +1,4 @@
+-- Visibility was backfilled in 0010, the flags it replaced are no longer read or written.
+ALTER TABLE Posts DROP COLUMN IsUnlisted;
+ALTER TABLE Posts DROP COLUMN IsPublic;
+ALTER TABLE PostRevisions DROP COLUMN IsPublic;
@@ -0,0 +1,9 @@
+-- Private posts keep their tags encrypted, so each tag is also stored as an HMAC keyed
+-- with the owner's key. The owner can filter & page by tag in SQL, nobody else can read them.
+CREATE TABLE IF NOT EXISTS PrivatePostTags (
+    PostID INT NOT NULL,
+    TagHash CHAR(64) NOT NULL,
+    PRIMARY KEY (PostID, TagHash),
+    INDEX idx_private_post_tags_hash (TagHash),
+    FOREIGN KEY (PostID) REFERENCES Posts(ID) ON DELETE CASCADE
+);
@@ -0,0 +1,466 @@
+package server
+
+import (
+	"bytes"
+	"crypto/rand"
+	"database/sql"
+	"encoding/gob"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"App/internal/config"
+	"App/internal/migrations"
+	"App/internal/storage"
+	"App/internal/types"
+	"App/internal/utils"
+
+	sqle "github.com/dolthub/go-mysql-server"
+	"github.com/dolthub/go-mysql-server/memory"
+	gmsserver "github.com/dolthub/go-mysql-server/server"
+	gmssql "github.com/dolthub/go-mysql-server/sql"
+	"github.com/gin-gonic/gin"
+	_ "github.com/go-sql-driver/mysql"
+	"github.com/gorilla/sessions"
+	"github.com/sirupsen/logrus"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden HTML files in testdata/golden")
+
+func init() {
+	gin.SetMode(gin.TestMode)
+
+	// The in-memory MySQL server logs every connection at info level
+	logrus.SetLevel(logrus.ErrorLevel)
+}
+
+type testServer struct {
+	t        *testing.T
+	handler  http.Handler
+	db       *sql.DB
+	app      *types.App
+	metrics  http.Handler
+	keyCache *fakeKeyCache
+	clock    *fakeClock
+	logs     *logBuffer
+	clients  int // Gives every client of this server its own address
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+
+	db := openTestDB(t)
+
+	mediaStore, err := storage.NewLocalStore(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	// Every server gets its own key cache, clock & log, so tests can run side by side
+	keyCache := newFakeKeyCache()
+	clock := &fakeClock{now: time.Now()}
+	logs := &logBuffer{}
+
+	cfg := &config.Config{TLSMode: utils.TLS_MODE_OFF, PostsPerPage: 2, SiteURL: "https://posto.test"}
+
+	srv, err := New(cfg, Deps{
+		DB:           db,
+		MediaStore:   mediaStore,
+		SessionStore: newMemorySessionStore(),
+		KeyCache:     keyCache,
+		Clock:        clock,
+		Logger:       slog.NewJSONHandler(logs, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	})
+
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	return &testServer{t: t, handler: srv.Handler(), db: db, app: srv.app, metrics: srv.metrics.Handler(), keyCache: keyCache, clock: clock, logs: logs}
+}
+
+func openTestDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	// An in-process MySQL server runs the real queries & migrations without a database to install
+	database := memory.NewDatabase("posto")
+	database.BaseDatabase.EnablePrimaryKeyIndexes()
+
+	provider := memory.NewDBProvider(database)
+	engine := sqle.NewDefault(provider)
+
+	mysqlServer, err := gmsserver.NewServer(gmsserver.Config{Protocol: "tcp", Address: "127.0.0.1:0"}, engine, gmssql.NewContext, memory.NewSessionBuilder(provider), nil)
+
+	if err != nil {
+		t.Fatalf("starting in-memory MySQL: %v", err)
+	}
+
+	go mysqlServer.Start()
+
+	db, err := sql.Open("mysql", fmt.Sprintf("root@tcp(%s)/posto", mysqlServer.Listener.Addr()))
+
+	if err != nil {
+		t.Fatalf("sql.Open: %v", err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+		mysqlServer.Close()
+	})
+
+	if err := migrations.Apply(db); err != nil {
+		t.Fatalf("migrations.Apply: %v", err)
+	}
+
+	return db
+}
+
+func (s *testServer) exec(query string, args ...any) {
+	s.t.Helper()
+
+	if _, err := s.db.Exec(query, args...); err != nil {
+		s.t.Fatalf("exec %q: %v", query, err)
+	}
+}
+
+func (s *testServer) queryInt(query string, args ...any) int {
+	s.t.Helper()
+
+	var value int
+
+	if err := s.db.QueryRow(query, args...).Scan(&value); err != nil {
+		s.t.Fatalf("query %q: %v", query, err)
+	}
+
+	return value
+}
+
+type testClient struct {
+	server  *testServer
+	ip      string
+	cookies map[string]string
+}
+
+func (s *testServer) scrapeMetrics() string {
+	s.t.Helper()
+
+	recorder := httptest.NewRecorder()
+	s.metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+
+	return recorder.Body.String()
+}
+
+func (s *testServer) newClient() *testClient {
+	s.clients++
+	id := s.clients
+
+	return &testClient{
+		server:  s,
+		ip:      fmt.Sprintf("10.%d.%d.%d", id>>16&0xff, id>>8&0xff, id&0xff),
+		cookies: map[string]string{},
+	}
+}
+
+func (c *testClient) do(method, path string, form url.Values, headers ...string) *httptest.ResponseRecorder {
+	c.server.t.Helper()
+
+	var body *strings.Reader
+
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	} else {
+		body = strings.NewReader("")
+	}
+
+	request := httptest.NewRequest(method, path, body)
+	request.RemoteAddr = c.ip + ":40000"
+
+	if form != nil {
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	// Headers are given as name, value pairs; pages are asked for as a browser would by default
+	request.Header.Set("Accept", "text/html")
+
+	for i := 0; i+1 < len(headers); i += 2 {
+		request.Header.Set(headers[i], headers[i+1])
+	}
+
+	for name, value := range c.cookies {
+		request.AddCookie(&http.Cookie{Name: name, Value: value})
+	}
+
+	recorder := httptest.NewRecorder()
+	c.server.handler.ServeHTTP(recorder, request)
+
+	// Keep cookies like a browser would, the session cookie is Secure & scoped to the real domain
+	for _, cookie := range recorder.Result().Cookies() {
+		if cookie.MaxAge < 0 {
+			delete(c.cookies, cookie.Name)
+		} else {
+			c.cookies[cookie.Name] = cookie.Value
+		}
+	}
+
+	return recorder
+}
+
+func (c *testClient) get(path string, headers ...string) *httptest.ResponseRecorder {
+	c.server.t.Helper()
+	return c.do(http.MethodGet, path, nil, headers...)
+}
+
+func (c *testClient) post(path string, form url.Values, headers ...string) *httptest.ResponseRecorder {
+	c.server.t.Helper()
+
+	if form == nil {
+		form = url.Values{}
+	}
+
+	return c.do(http.MethodPost, path, form, headers...)
+}
+
+func (s *testServer) signup(username, password string) *testClient {
+	s.t.Helper()
+
+	client := s.newClient()
+	response := client.post("/signup", url.Values{"username": {username}, "password": {password}})
+
+	expectRedirect(s.t, response, "/profile/"+username)
+
+	return client
+}
+
+func (c *testClient) createPost(title, content, visibility string, tags ...string) int {
+	c.server.t.Helper()
+
+	response := c.post("/createpost", url.Values{
+		"title":      {title},
+		"message":    {content},
+		"visibility": {visibility},
+		"tags":       {strings.Join(tags, ",")},
+	})
+
+	if response.Code != http.StatusFound {
+		c.server.t.Fatalf("create post: got %d, want a redirect\n%s", response.Code, response.Body)
+	}
+
+	return c.server.queryInt("SELECT MAX(ID) FROM Posts")
+}
+
+func expectStatus(t *testing.T, response *httptest.ResponseRecorder, status int) {
+	t.Helper()
+
+	if response.Code != status {
+		t.Fatalf("got status %d, want %d\n%s", response.Code, status, response.Body)
+	}
+}
+
+func expectRedirect(t *testing.T, response *httptest.ResponseRecorder, location string) {
+	t.Helper()
+
+	expectStatus(t, response, http.StatusFound)
+
+	if got := response.Header().Get("Location"); got != location {
+		t.Fatalf("redirected to %q, want %q", got, location)
+	}
+}
+
+func expectBody(t *testing.T, response *httptest.ResponseRecorder, substrings ...string) {
+	t.Helper()
+
+	for _, substring := range substrings {
+		if !strings.Contains(response.Body.String(), substring) {
+			t.Fatalf("body is missing %q\n%s", substring, response.Body)
+		}
+	}
+}
+
+var noncePattern = regexp.MustCompile(`nonce="[A-Za-z0-9_-]*"`)
+
+func expectGolden(t *testing.T, name string, response *httptest.ResponseRecorder) {
+	t.Helper()
+
+	// Nonces change on every request, everything else on the page must match byte for byte
+	got := noncePattern.ReplaceAll(response.Body.Bytes(), []byte(`nonce="NONCE"`))
+	path := filepath.Join("testdata", "golden", name+".html")
+
+	if *updateGolden {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatalf("reading golden file, run the tests with -update to create it: %v", err)
+	}
+
+	if !bytes.Equal(got, want) {
+		t.Fatalf("%s does not match the rendered page, run the tests with -update if the change is intended\n%s", path, got)
+	}
+}
+
+type fakeClock struct {
+	mutex sync.Mutex
+	now   time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.now = c.now.Add(d)
+}
+
+type logBuffer struct {
+	mutex  sync.Mutex
+	buffer bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.Write(p)
+}
+
+func (b *logBuffer) String() string {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	return b.buffer.String()
+}
+
+type fakeKeyCache struct {
+	mutex sync.Mutex
+	keys  map[int][]byte
+}
+
+func newFakeKeyCache() *fakeKeyCache {
+	return &fakeKeyCache{keys: map[int][]byte{}}
+}
+
+func (c *fakeKeyCache) Load(userID int) ([]byte, bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	key, ok := c.keys[userID]
+	return key, ok
+}
+
+func (c *fakeKeyCache) Store(userID int, key []byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.keys[userID] = key
+}
+
+func (c *fakeKeyCache) Delete(userID int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	delete(c.keys, userID)
+}
+
+func (c *fakeKeyCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return len(c.keys)
+}
+
+type memorySessionStore struct {
+	mutex    sync.Mutex
+	sessions map[string][]byte
+}
+
+func newMemorySessionStore() *memorySessionStore {
+	return &memorySessionStore{sessions: map[string][]byte{}}
+}
+
+func (s *memorySessionStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+func (s *memorySessionStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	session.Options = &sessions.Options{Path: "/", MaxAge: 604800, HttpOnly: true}
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+
+	if err != nil {
+		return session, nil
+	}
+
+	s.mutex.Lock()
+	encoded, ok := s.sessions[cookie.Value]
+	s.mutex.Unlock()
+
+	if !ok {
+		return session, nil
+	}
+
+	if err := gob.NewDecoder(bytes.NewReader(encoded)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+
+	session.ID = cookie.Value
+	session.IsNew = false
+
+	return session, nil
+}
+
+func (s *memorySessionStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// A negative MaxAge is how the app logs a session out
+	if session.Options.MaxAge < 0 {
+		delete(s.sessions, session.ID)
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		id := make([]byte, 16)
+		rand.Read(id)
+		session.ID = hex.EncodeToString(id)
+	}
+
+	var encoded bytes.Buffer
+
+	if err := gob.NewEncoder(&encoded).Encode(session.Values); err != nil {
+		return err
+	}
+
+	s.sessions[session.ID] = encoded.Bytes()
+	http.SetCookie(w, sessions.NewCookie(session.Name(), session.ID, session.Options))
+
+	return nil
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"App/internal/api"
+	"App/internal/config"
+	"App/internal/templates"
+	"App/internal/types"
+	"App/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func newRouter(cfg *config.Config, app *types.App, rateLimiter *api.RateLimiter) (*gin.Engine, error) {
+	// Create a router to map incoming requests to handler functions
+	router := gin.New()
+
+	// Tag each request with an ID that follows it into the service logs
+	router.Use(api.RequestID())
+
+	// Start a trace for each request that the service & SQL spans attach to
+	router.Use(api.TraceRequests())
+
+	// Use CORS middleware
+	allowedOrigins := []string{
+		"https://codingwithkarim.github.io",
+		"https://postoblog.duckdns.org",
+	}
+
+	router.Use(api.CORSMiddleware(allowedOrigins))
+
+	// Tell browsers to stick to HTTPS once they have reached the site over it
+	if cfg.TLSMode != utils.TLS_MODE_OFF && cfg.HSTSMaxAge > 0 {
+		router.Use(api.StrictTransportSecurity(cfg.HSTSMaxAge))
+	}
+
+	// Send CSP & framing headers, and give each request the nonce its script tags are rendered with
+	router.Use(api.SecurityHeaders(cfg.CSPReportOnly))
+
+	// Use Gin's recovery middleware to recover from panics
+	router.Use(gin.Recovery())
+
+	// Log each request once it has been handled
+	router.Use(api.RequestLogger(app))
+
+	// Record latency & status of each request for /metrics
+	router.Use(api.RecordMetrics(app))
+
+	// Set up trusted proxies
+	if err := router.SetTrustedProxies([]string{"127.0.0.1"}); err != nil {
+		return nil, fmt.Errorf("failed to set trusted proxies: %w", err)
+	}
+
+	// This function map allows us to use custom functions in our HTML templates
+	templateFunctions := template.FuncMap{
+		"Iterate": func(start, end int) []int {
+			items := make([]int, end-start+1)
+			for i := range items {
+				items[i] = start + i
+			}
+			return items
+		},
+		"cspNonce":        api.CSPNonce,
+		"join":            strings.Join,
+		"visibilityLabel": utils.VisibilityLabel,
+		"add":             func(a, b int) int { return a + b },
+		"subtract":        func(a, b int) int { return a - b },
+		"max": func(a, b int) int {
+			if a > b {
+				return a
+			}
+			return b
+		},
+		"min": func(a, b int) int {
+			if a < b {
+				return a
+			}
+			return b
+		},
+	}
+
+	// Create a custom template with the template functions defined above
+	tmplate, err := template.New("").Funcs(templateFunctions).ParseFS(templates.Files, "*.html")
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing templates: %w", err)
+	}
+
+	// Set custom template for Gin router
+	// This allows us to use the custom functions in our HTML templates & fills in each request's CSP nonce
+	router.HTMLRender = api.HTMLRender{Template: tmplate}
+
+	// Health checks are registered before the rate limiter so frequent probes are never blocked
+	router.GET("/healthz", api.GetHealthzHandler)
+	router.GET("/readyz", api.GetReadyzHandler(app))
+
+	// Middleware for blocking suspicious IPs
+	router.Use(api.BlockSuspiciousIPsAndRateLimit(app, rateLimiter))
+
+	// Invalid Routes
+	router.NoRoute(api.GetNotFoundHandler)
+
+	// Public Routes (No authentication required)
+	router.GET("/", api.OptionalAuth(app), api.GetHomePageHandler(app))
+	router.GET("/explore", api.OptionalAuth(app), api.GetExplorePageHandler(app))
+	router.GET("/profile/:username", api.OptionalAuth(app), api.RenderUserProfilePageHandler(app))
+	router.GET("/profile/:username/rss", api.GetUserFeedHandler(app, utils.FEED_FORMAT_RSS))
+	router.GET("/profile/:username/atom", api.GetUserFeedHandler(app, utils.FEED_FORMAT_ATOM))
+	router.GET("/profile/:username/followers", api.OptionalAuth(app), api.GetFollowListHandler(app, utils.FOLLOWERS_LIST))
+	router.GET("/profile/:username/following", api.OptionalAuth(app), api.GetFollowListHandler(app, utils.FOLLOWING_LIST))
+	router.GET("/avatar/:username", api.GetAvatarHandler(app))
+	router.GET("/media/:token", api.OptionalAuth(app), api.GetMediaHandler(app, false))
+	router.GET("/media/:token/thumbnail", api.OptionalAuth(app), api.GetMediaHandler(app, true))
+	router.GET("/rss", api.GetPublicFeedHandler(app, utils.FEED_FORMAT_RSS))
+	router.GET("/atom", api.GetPublicFeedHandler(app, utils.FEED_FORMAT_ATOM))
+	router.GET("/blogpost/:ID", api.OptionalAuth(app), api.RenderSingleBlogPostHandler(app))
+	router.GET("/tag/:name", api.OptionalAuth(app), api.RenderTagPageHandler(app))
+	router.GET("/share/:token", api.GetSharedPostHandler(app))
+	router.GET("/login", api.GetLoginPageHandler)
+	router.GET("/signup", api.GetSignupPageHandler)
+	router.POST("/login", api.PostLoginHandler(app))
+	router.POST("/signup", api.PostSignupHandler(app))
+	router.POST(utils.CSP_REPORT_PATH, api.PostCSPReportHandler(app))
+
+	// Authenticated Routes (Require authentication)
+	authRoutes := router.Group("/")
+	authRoutes.Use(api.RequireAuth(app))
+	{
+		authRoutes.GET("/edit/:ID", api.GetCreateOrEditPostPageHandler(app))
+		authRoutes.POST("/createpost", api.CreatePostHandler(app))
+		authRoutes.POST("/edit/:ID", api.UpdatePostHandler(app))
+		authRoutes.GET("/createpost", api.GetCreateOrEditPostPageHandler(app))
+		authRoutes.POST("/delete/:ID", api.DeletePostHandler(app))
+		authRoutes.GET("/blogpost/:ID/history", api.GetPostHistoryHandler(app))
+		authRoutes.POST("/blogpost/:ID/history/:revision/restore", api.PostRestoreRevisionHandler(app))
+		authRoutes.GET("/blogpost/:ID/share", api.GetShareLinksHandler(app))
+		authRoutes.POST("/blogpost/:ID/share", api.PostShareLinkHandler(app))
+		authRoutes.POST("/blogpost/:ID/share/:token/revoke", api.PostRevokeShareLinkHandler(app))
+		authRoutes.POST("/logout", api.PostLogoutHandler(app))
+		authRoutes.POST("/media", api.PostMediaUploadHandler(app))
+		authRoutes.POST("/blogpost/:ID/comment", api.PostCommentHandler(app))
+		authRoutes.POST("/blogpost/:ID/like", api.PostLikeHandler(app))
+		authRoutes.POST("/blogpost/:ID/bookmark", api.PostBookmarkHandler(app))
+		authRoutes.POST("/blogpost/:ID/bookmark/remove", api.PostRemoveBookmarkHandler(app))
+		authRoutes.POST("/follow/:username", api.PostFollowHandler(app))
+		authRoutes.POST("/block/:username", api.PostBlockHandler(app))
+		authRoutes.POST("/mute/:username", api.PostMuteHandler(app))
+		authRoutes.GET("/feed", api.GetHomeFeedHandler(app))
+		authRoutes.GET("/saved", api.GetSavedPageHandler(app))
+		authRoutes.POST("/saved/collections", api.PostBookmarkCollectionHandler(app))
+		authRoutes.POST("/saved/collections/:collection/delete", api.PostDeleteBookmarkCollectionHandler(app))
+		authRoutes.GET("/settings", api.GetSettingsPageHandler)
+		authRoutes.GET("/settings/profile", api.GetProfileSettingsHandler(app))
+		authRoutes.POST("/settings/profile", api.PostProfileSettingsHandler(app))
+		authRoutes.GET("/settings/follow-requests", api.GetFollowRequestsHandler(app))
+		authRoutes.POST("/settings/follow-requests/:username/approve", api.PostFollowRequestHandler(app, true))
+		authRoutes.POST("/settings/follow-requests/:username/decline", api.PostFollowRequestHandler(app, false))
+		authRoutes.GET("/settings/export", api.GetExportHandler(app))
+		authRoutes.GET("/settings/import", api.GetImportPageHandler)
+		authRoutes.POST("/settings/import", api.PostImportHandler(app))
+		authRoutes.GET("/settings/delete", api.GetDeleteAccountPageHandler)
+		authRoutes.POST("/settings/delete", api.PostDeleteAccountHandler(app))
+	}
+
+	return router, nil
+}
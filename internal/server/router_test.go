@@ -0,0 +1,768 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"App/internal/blogservice"
+	"App/internal/utils"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestSignupAndLogin(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+
+	// The new session is logged in straight away
+	expectStatus(t, alice.get("/profile/alice"), http.StatusOK)
+
+	expectRedirect(t, alice.post("/logout", nil), "/")
+	expectRedirect(t, alice.get("/feed"), "/login")
+
+	// Logging back in unlocks the same account
+	expectRedirect(t, alice.post("/login", url.Values{"username": {"alice"}, "password": {"password1"}}), "/profile/alice")
+	expectStatus(t, alice.get("/feed"), http.StatusOK)
+}
+
+func TestSignupRejectsTakenUsername(t *testing.T) {
+	server := newTestServer(t)
+	server.signup("alice", "password1")
+
+	response := server.newClient().post("/signup", url.Values{"username": {"alice"}, "password": {"password2"}})
+
+	expectStatus(t, response, http.StatusConflict)
+	expectBody(t, response, "username already exists")
+}
+
+func TestLoginFailuresLookTheSame(t *testing.T) {
+	server := newTestServer(t)
+	server.signup("alice", "password1")
+
+	client := server.newClient()
+
+	// An unknown user & a wrong password can't be told apart
+	for _, form := range []url.Values{
+		{"username": {"nobody"}, "password": {"password1"}},
+		{"username": {"alice"}, "password": {"wrong-password"}},
+	} {
+		response := client.post("/login", form, "Accept", "application/json")
+
+		expectStatus(t, response, http.StatusUnauthorized)
+
+		if got := decodeJSON(t, response.Body.Bytes())["error"]; got != utils.INVALID_CREDENTIALS_MESSAGE {
+			t.Fatalf("error for %s: got %q, want %q", form.Get("username"), got, utils.INVALID_CREDENTIALS_MESSAGE)
+		}
+	}
+}
+
+func TestInjectedLoggerIsRedacted(t *testing.T) {
+	server := newTestServer(t)
+
+	// Unknown users are logged by name, which has to be redacted before it reaches the injected handler
+	response := server.newClient().post("/login", url.Values{"username": {"mallory"}, "password": {"password1"}})
+	expectStatus(t, response, http.StatusUnauthorized)
+
+	logs := server.logs.String()
+
+	if !strings.Contains(logs, "Login attempted for unknown user") {
+		t.Fatalf("the injected handler did not receive the service log:\n%s", logs)
+	}
+
+	if strings.Contains(logs, "mallory") {
+		t.Fatalf("username reached the injected handler unredacted:\n%s", logs)
+	}
+
+	if !strings.Contains(logs, utils.REQUEST_ID_ATTR) {
+		t.Fatalf("service log is missing the request ID:\n%s", logs)
+	}
+}
+
+func TestCreateEditAndDeletePost(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("First post", "Hello from Posto", utils.VISIBILITY_PUBLIC, "golang")
+	postPath := "/blogpost/" + strconv.Itoa(postID)
+
+	expectBody(t, server.newClient().get(postPath), "First post", "Hello from Posto", "golang")
+
+	// Editing keeps the post & redirects back to it
+	expectRedirect(t, alice.post("/edit/"+strconv.Itoa(postID), url.Values{
+		"title":      {"First post, edited"},
+		"message":    {"Hello again"},
+		"visibility": {utils.VISIBILITY_PUBLIC},
+	}), postPath)
+
+	expectBody(t, alice.get(postPath), "First post, edited", "Hello again")
+
+	// Nobody else can change or delete it
+	bob := server.signup("bob", "password2")
+
+	expectStatus(t, bob.post("/edit/"+strconv.Itoa(postID), url.Values{
+		"title":      {"Hijacked"},
+		"message":    {"Not yours"},
+		"visibility": {utils.VISIBILITY_PUBLIC},
+	}), http.StatusNotFound)
+
+	expectStatus(t, bob.post("/delete/"+strconv.Itoa(postID), nil), http.StatusNotFound)
+
+	expectRedirect(t, alice.post("/delete/"+strconv.Itoa(postID), nil), "/profile/alice")
+	expectStatus(t, alice.get(postPath), http.StatusNotFound)
+}
+
+func TestCreatePostValidation(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.signup("alice", "password1")
+
+	response := alice.post("/createpost", url.Values{"title": {""}, "message": {"No title"}, "visibility": {utils.VISIBILITY_PUBLIC}})
+
+	expectStatus(t, response, http.StatusBadRequest)
+	expectBody(t, response, "invalid title length")
+}
+
+func TestCreatePostWithUnknownMediaSavesNothing(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.signup("alice", "password1")
+
+	response := alice.post("/createpost", url.Values{
+		"title":      {"With a picture"},
+		"message":    {"The image was never uploaded"},
+		"visibility": {utils.VISIBILITY_PUBLIC},
+		"media":      {"missing"},
+	})
+
+	// The post & its images are saved together, so a retry can't create a duplicate
+	expectStatus(t, response, http.StatusNotFound)
+
+	if count := server.queryInt("SELECT COUNT(*) FROM Posts"); count != 0 {
+		t.Fatalf("post was saved without its images: %d posts", count)
+	}
+}
+
+func TestPrivatePostEncryption(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Diary", "Nobody else may read this", utils.VISIBILITY_PRIVATE)
+	postPath := "/blogpost/" + strconv.Itoa(postID)
+
+	// Only ciphertext reaches the database
+	var title, content string
+
+	if err := server.db.QueryRow("SELECT Title, Content FROM Posts WHERE ID = ?", postID).Scan(&title, &content); err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(title, "Diary") || strings.Contains(content, "Nobody else") {
+		t.Fatalf("private post was stored in plain text: %q / %q", title, content)
+	}
+
+	// The owner reads it decrypted, everyone else gets a 404
+	expectBody(t, alice.get(postPath), "Diary", "Nobody else may read this")
+	expectStatus(t, server.newClient().get(postPath), http.StatusNotFound)
+	expectStatus(t, server.signup("bob", "password2").get(postPath), http.StatusNotFound)
+
+	// A restart empties the key cache, the session can't decrypt anything so it is logged out
+	server.keyCache.Delete(server.queryInt("SELECT ID FROM Users WHERE Username = 'alice'"))
+
+	expectRedirect(t, alice.get("/feed"), "/login")
+
+	// Logging in derives the same key from the password again
+	expectRedirect(t, alice.post("/login", url.Values{"username": {"alice"}, "password": {"password1"}}), "/profile/alice")
+	expectBody(t, alice.get(postPath), "Diary", "Nobody else may read this")
+}
+
+func TestGoingPrivateEncryptsEarlierRevisions(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Draft plans", "Written for everyone", utils.VISIBILITY_PUBLIC, "plans")
+	postPath := "/blogpost/" + strconv.Itoa(postID)
+
+	expectRedirect(t, alice.post("/edit/"+strconv.Itoa(postID), url.Values{
+		"title":      {"Draft plans, second try"},
+		"message":    {"Still written for everyone"},
+		"visibility": {utils.VISIBILITY_PUBLIC},
+		"tags":       {"plans"},
+	}), postPath)
+
+	expectRedirect(t, alice.post("/edit/"+strconv.Itoa(postID), url.Values{
+		"title":      {"Secret plans"},
+		"message":    {"Only for me now"},
+		"visibility": {utils.VISIBILITY_PRIVATE},
+	}), postPath)
+
+	// None of the earlier versions are left readable in the database
+	rows, err := server.db.Query("SELECT Title, Content, Visibility, COALESCE(Tags, '') FROM PostRevisions WHERE PostID = ?", postID)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer rows.Close()
+
+	revisions := 0
+
+	for rows.Next() {
+		var title, content, visibility, tags string
+
+		if err := rows.Scan(&title, &content, &visibility, &tags); err != nil {
+			t.Fatal(err)
+		}
+
+		if visibility != utils.VISIBILITY_PRIVATE || strings.Contains(title, "plans") || strings.Contains(content, "everyone") || strings.Contains(tags, "plans") {
+			t.Errorf("revision left readable: %q / %q / %q / %q", visibility, title, content, tags)
+		}
+
+		revisions++
+	}
+
+	if revisions != 3 {
+		t.Fatalf("got %d revisions, want 3", revisions)
+	}
+
+	// The owner still sees the whole history decrypted
+	expectBody(t, alice.get(postPath+"/history"), "Draft plans, second try", "Still written for everyone", "Only for me now")
+	expectBody(t, alice.get(postPath+"/history?to=2"), "Draft plans", "Written for everyone", "plans")
+}
+
+func TestServersKeepTheirOwnKeyCache(t *testing.T) {
+	first, second := newTestServer(t), newTestServer(t)
+
+	// Both databases are fresh, so each alice gets the same user ID
+	firstAlice := first.signup("alice", "password1")
+	secondAlice := second.signup("alice", "password1")
+	postPath := "/blogpost/" + strconv.Itoa(secondAlice.createPost("Diary", "Nobody else may read this", utils.VISIBILITY_PRIVATE))
+
+	// Emptying one server's key cache only logs out its own sessions
+	first.keyCache.Delete(first.queryInt("SELECT ID FROM Users WHERE Username = 'alice'"))
+
+	expectRedirect(t, firstAlice.get("/feed"), "/login")
+	expectBody(t, secondAlice.get(postPath), "Diary", "Nobody else may read this")
+}
+
+func TestOwnerTagFilterIncludesPrivatePosts(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	alice.createPost("Public notes", "Everyone can read this", utils.VISIBILITY_PUBLIC, "golang")
+	alice.createPost("Private notes", "Only alice can read this", utils.VISIBILITY_PRIVATE, "golang")
+	alice.createPost("Other notes", "Tagged with something else", utils.VISIBILITY_PRIVATE, "rust")
+
+	// Private tags are encrypted, the owner's filter still finds those posts
+	response := alice.get("/profile/alice?tag=golang")
+	expectBody(t, response, "Public notes", "Private notes")
+
+	if strings.Contains(response.Body.String(), "Other notes") {
+		t.Fatal("tag filter listed a post without the tag")
+	}
+
+	// Pages of one post each, walked with the JSON cursor
+	first := decodeJSON(t, alice.get("/profile/alice?tag=golang&limit=1", "Accept", "application/json").Body.Bytes())
+	cursor, _ := first["nextCursor"].(string)
+
+	if cursor == "" {
+		t.Fatal("expected a second page of tagged posts")
+	}
+
+	second := alice.get("/profile/alice?tag=golang&limit=1&cursor="+cursor, "Accept", "application/json")
+	expectBody(t, second, "Public notes")
+
+	// Only keyed hashes of the private tags reach the database
+	if got := server.queryInt("SELECT COUNT(*) FROM PrivatePostTags WHERE TagHash IN ('golang', 'rust')"); got != 0 {
+		t.Fatalf("%d private tags stored in plain text", got)
+	}
+
+	// Posts saved before their tags were hashed are indexed the next time the owner filters
+	server.exec("DELETE FROM PrivatePostTags")
+
+	alice.createPost("Newest notes", "Also tagged", utils.VISIBILITY_PRIVATE, "golang")
+
+	// Pages of two posts each, counted & limited in SQL
+	expectBody(t, alice.get("/profile/alice?tag=golang"), "Newest notes", "Private notes")
+	response = alice.get("/profile/alice?tag=golang&page=2")
+	expectBody(t, response, "Public notes")
+
+	if strings.Contains(response.Body.String(), "Private notes") {
+		t.Fatal("second page repeated a post from the first")
+	}
+
+	if got := server.queryInt("SELECT COUNT(*) FROM PrivatePostTags"); got != 3 {
+		t.Fatalf("got %d private tag hashes after indexing, want 3", got)
+	}
+
+	// Everyone else only sees the public post
+	response = server.newClient().get("/profile/alice?tag=golang")
+	expectBody(t, response, "Public notes")
+
+	if strings.Contains(response.Body.String(), "Private notes") {
+		t.Fatal("private post listed to another user")
+	}
+}
+
+func TestShareLinkExpiry(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Diary", "Shared with one friend", utils.VISIBILITY_PRIVATE)
+
+	expectRedirect(t, alice.post(fmt.Sprintf("/blogpost/%d/share", postID), url.Values{"expires": {"7d"}}), fmt.Sprintf("/blogpost/%d/share", postID))
+
+	var token string
+
+	if err := server.db.QueryRow("SELECT Token FROM PostShareLinks WHERE PostID = ?", postID).Scan(&token); err != nil {
+		t.Fatal(err)
+	}
+
+	// Anyone with the link can open the post until it expires
+	expectStatus(t, server.newClient().get("/share/"+token), http.StatusOK)
+
+	server.clock.Advance(8 * 24 * time.Hour)
+
+	expectStatus(t, server.newClient().get("/share/"+token), http.StatusNotFound)
+}
+
+func TestLikes(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Likeable", "Content", utils.VISIBILITY_PUBLIC)
+	likePath := fmt.Sprintf("/blogpost/%d/like", postID)
+
+	bob := server.signup("bob", "password2")
+
+	// Liking toggles
+	for _, want := range []bool{true, false, true} {
+		response := bob.post(likePath, nil, "Accept", "application/json")
+
+		expectStatus(t, response, http.StatusOK)
+
+		if got := decodeJSON(t, response.Body.Bytes())["liked"]; got != want {
+			t.Fatalf("liked: got %v, want %v", got, want)
+		}
+	}
+
+	if likes := server.queryInt("SELECT COUNT(*) FROM Likes WHERE PostID = ?", postID); likes != 1 {
+		t.Fatalf("got %d likes, want 1", likes)
+	}
+
+	// Posts that don't exist can't be liked
+	expectStatus(t, bob.post("/blogpost/999/like", nil, "Accept", "application/json"), http.StatusNotFound)
+}
+
+func TestFollows(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	bob := server.signup("bob", "password2")
+
+	follow := func(client *testClient, username string) string {
+		t.Helper()
+
+		response := client.post("/follow/"+username, nil, "Accept", "application/json")
+		expectStatus(t, response, http.StatusOK)
+
+		return decodeJSON(t, response.Body.Bytes())["status"].(string)
+	}
+
+	if status := follow(bob, "alice"); status != utils.FOLLOW_STATUS_ACTIVE {
+		t.Fatalf("follow: got %q, want %q", status, utils.FOLLOW_STATUS_ACTIVE)
+	}
+
+	// Following again unfollows
+	if status := follow(bob, "alice"); status != utils.FOLLOW_STATUS_NONE {
+		t.Fatalf("unfollow: got %q, want %q", status, utils.FOLLOW_STATUS_NONE)
+	}
+
+	// Private accounts get a request to approve instead
+	expectStatus(t, alice.post("/settings/profile", url.Values{"isPrivate": {"true"}}), http.StatusOK)
+
+	if status := follow(bob, "alice"); status != utils.FOLLOW_STATUS_PENDING {
+		t.Fatalf("follow private account: got %q, want %q", status, utils.FOLLOW_STATUS_PENDING)
+	}
+
+	expectRedirect(t, alice.post("/settings/follow-requests/bob/approve", nil), "/settings/follow-requests")
+
+	if follows := server.queryInt("SELECT COUNT(*) FROM User_Follows"); follows != 1 {
+		t.Fatalf("got %d follows after approving, want 1", follows)
+	}
+
+	expectStatus(t, bob.post("/follow/bob", nil, "Accept", "application/json"), http.StatusBadRequest)
+	expectStatus(t, bob.post("/follow/nobody", nil, "Accept", "application/json"), http.StatusNotFound)
+}
+
+func TestPrivateAccountHidesPostsFromNonFollowers(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	alice.createPost("Only for friends", "Public post on a private account", utils.VISIBILITY_PUBLIC, "golang")
+	expectStatus(t, alice.post("/settings/profile", url.Values{"isPrivate": {"true"}}), http.StatusOK)
+
+	bob := server.signup("bob", "password2")
+	anonymous := server.newClient()
+
+	// Neither a stranger nor someone with a pending request sees the posts or follow lists
+	bob.post("/follow/alice", nil, "Accept", "application/json")
+
+	for name, client := range map[string]*testClient{"anonymous": anonymous, "pending": bob} {
+		response := client.get("/profile/alice")
+		expectBody(t, response, "This Account Is Private")
+
+		if strings.Contains(response.Body.String(), "Only for friends") {
+			t.Fatalf("%s viewer saw a private account's post", name)
+		}
+
+		profile := decodeJSON(t, client.get("/profile/alice", "Accept", "application/json").Body.Bytes())
+
+		if posts, _ := profile["posts"].([]any); len(posts) != 0 || profile["isLocked"] != true {
+			t.Fatalf("%s viewer got posts from a private account over JSON: %v", name, profile)
+		}
+
+		expectStatus(t, client.get("/profile/alice/followers"), http.StatusForbidden)
+		expectStatus(t, client.get("/profile/alice/following"), http.StatusForbidden)
+	}
+
+	// Once approved, the follower sees everything
+	expectRedirect(t, alice.post("/settings/follow-requests/bob/approve", nil), "/settings/follow-requests")
+
+	expectBody(t, bob.get("/profile/alice"), "Only for friends")
+	expectStatus(t, bob.get("/profile/alice/followers"), http.StatusOK)
+	expectBody(t, alice.get("/profile/alice"), "Only for friends")
+}
+
+func TestPrivateAccountPostsStayHiddenEverywhere(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Only for friends", "Public post on a private account", utils.VISIBILITY_PUBLIC, "golang")
+	postPath := "/blogpost/" + strconv.Itoa(postID)
+	server.exec("INSERT INTO Media (Token, UserID, PostID, ContentType, Width, Height, Size) VALUES (?, ?, ?, 'image/png', 1, 1, 1)",
+		strings.Repeat("a", 32), server.queryInt("SELECT ID FROM Users WHERE Username = 'alice'"), postID)
+
+	bob := server.signup("bob", "password2")
+	anonymous := server.newClient()
+
+	// Rank the post while the account is still public, the explore page has to re-check it
+	if err := blogservice.RefreshTrendingPosts(context.Background(), server.app); err != nil {
+		t.Fatalf("RefreshTrendingPosts: %v", err)
+	}
+
+	expectBody(t, bob.get("/explore"), "Only for friends")
+	expectStatus(t, alice.post("/settings/profile", url.Values{"isPrivate": {"true"}}), http.StatusOK)
+
+	for name, client := range map[string]*testClient{"anonymous": anonymous, "non-follower": bob} {
+		expectStatus(t, client.get(postPath), http.StatusNotFound)
+		expectStatus(t, client.get("/media/"+strings.Repeat("a", 32)), http.StatusNotFound)
+
+		for _, path := range []string{"/tag/golang", "/explore", "/rss", "/atom"} {
+			if response := client.get(path); strings.Contains(response.Body.String(), "Only for friends") {
+				t.Fatalf("%s viewer saw a private account's post on %s", name, path)
+			}
+		}
+	}
+
+	if strings.Contains(anonymous.get("/").Body.String(), "Only for friends") {
+		t.Fatal("the logged out home page showed a private account's post")
+	}
+
+	// Approved followers read it like any other post
+	bob.post("/follow/alice", nil, "Accept", "application/json")
+	expectRedirect(t, alice.post("/settings/follow-requests/bob/approve", nil), "/settings/follow-requests")
+
+	expectBody(t, bob.get(postPath), "Only for friends")
+	expectBody(t, bob.get("/tag/golang"), "Only for friends")
+	expectBody(t, bob.get("/explore"), "Only for friends")
+}
+
+func TestComments(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Discuss", "Content", utils.VISIBILITY_PUBLIC)
+	postPath := "/blogpost/" + strconv.Itoa(postID)
+
+	bob := server.signup("bob", "password2")
+
+	expectRedirect(t, bob.post(postPath+"/comment", url.Values{"content": {"Great post!"}}), postPath)
+	expectBody(t, alice.get(postPath), "Great post!")
+
+	expectStatus(t, bob.post(postPath+"/comment", url.Values{"content": {""}}), http.StatusBadRequest)
+	expectStatus(t, bob.post("/blogpost/999/comment", url.Values{"content": {"Hello?"}}), http.StatusNotFound)
+}
+
+func TestCommentsAndLikesNeedReadAccess(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	followersOnly := alice.createPost("Friends", "Content", utils.VISIBILITY_FOLLOWERS)
+	private := alice.createPost("Diary", "Content", utils.VISIBILITY_PRIVATE)
+
+	bob := server.signup("bob", "password2")
+
+	// Posting the ID of a post bob can't read is the same as posting an unknown one
+	for _, postID := range []int{followersOnly, private} {
+		postPath := "/blogpost/" + strconv.Itoa(postID)
+
+		expectStatus(t, bob.post(postPath+"/comment", url.Values{"content": {"Hello?"}}), http.StatusNotFound)
+		expectStatus(t, bob.post(postPath+"/like", nil, "Accept", "application/json"), http.StatusNotFound)
+	}
+
+	if count := server.queryInt("SELECT COUNT(*) FROM Comments") + server.queryInt("SELECT COUNT(*) FROM Likes"); count != 0 {
+		t.Fatalf("got %d comments & likes on posts bob can't read", count)
+	}
+
+	// Following opens up the followers-only post
+	bob.post("/follow/alice", nil, "Accept", "application/json")
+
+	expectRedirect(t, bob.post(fmt.Sprintf("/blogpost/%d/comment", followersOnly), url.Values{"content": {"Hi!"}}), fmt.Sprintf("/blogpost/%d", followersOnly))
+	expectStatus(t, bob.post(fmt.Sprintf("/blogpost/%d/like", followersOnly), nil, "Accept", "application/json"), http.StatusOK)
+}
+
+func TestPagination(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+
+	for i := 1; i <= 5; i++ {
+		alice.createPost(fmt.Sprintf("Post number %d", i), "Content", utils.VISIBILITY_PUBLIC)
+	}
+
+	// Two posts per page, newest first
+	expectBody(t, alice.get("/profile/alice"), "Post number 5", "Post number 4")
+	expectBody(t, alice.get("/profile/alice?page=3"), "Post number 1")
+
+	// JSON clients walk the same posts with cursors, without repeats or gaps
+	var titles []string
+	path := "/profile/alice?limit=2"
+
+	for path != "" {
+		response := alice.get(path, "Accept", "application/json")
+		expectStatus(t, response, http.StatusOK)
+
+		var page struct {
+			Posts []struct {
+				Title string `json:"title"`
+			} `json:"posts"`
+			NextCursor string `json:"nextCursor"`
+		}
+
+		if err := json.Unmarshal(response.Body.Bytes(), &page); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, post := range page.Posts {
+			titles = append(titles, post.Title)
+		}
+
+		path = ""
+
+		if page.NextCursor != "" {
+			path = "/profile/alice?limit=2&cursor=" + url.QueryEscape(page.NextCursor)
+		}
+	}
+
+	want := "Post number 5,Post number 4,Post number 3,Post number 2,Post number 1"
+
+	if got := strings.Join(titles, ","); got != want {
+		t.Fatalf("cursor pages: got %s, want %s", got, want)
+	}
+
+	expectStatus(t, alice.get("/profile/alice?cursor=not-a-cursor", "Accept", "application/json"), http.StatusBadRequest)
+}
+
+func TestRateLimiter(t *testing.T) {
+	server := newTestServer(t)
+
+	client := server.newClient()
+	limited := false
+
+	// A burst over the per-second limit gets the address blocked
+	for i := 0; i <= utils.REQUEST_LIMIT+10 && !limited; i++ {
+		limited = client.get("/login").Code == http.StatusTooManyRequests
+	}
+
+	if !limited {
+		t.Fatalf("no request was rate limited")
+	}
+
+	expectStatus(t, client.get("/login"), http.StatusForbidden)
+
+	// Other addresses & health checks are unaffected
+	expectStatus(t, server.newClient().get("/login"), http.StatusOK)
+	expectStatus(t, client.get("/healthz"), http.StatusOK)
+}
+
+func TestServersKeepTheirOwnMetricsAndRateLimiter(t *testing.T) {
+	first, second := newTestServer(t), newTestServer(t)
+
+	// Each server's first client has the same address
+	client := first.newClient()
+
+	for i := 0; i <= utils.REQUEST_LIMIT+10; i++ {
+		client.get("/login")
+	}
+
+	expectStatus(t, client.get("/login"), http.StatusForbidden)
+	expectStatus(t, second.newClient().get("/login"), http.StatusOK)
+
+	// Only the server that saw the burst counts it
+	firstMetrics, secondMetrics := first.scrapeMetrics(), second.scrapeMetrics()
+
+	for _, line := range []string{"posto_rate_limit_blocked_ips 1", "posto_rate_limit_blocks_total 1"} {
+		if !strings.Contains(firstMetrics, line) {
+			t.Errorf("first server metrics are missing %q", line)
+		}
+	}
+
+	for _, line := range []string{"posto_rate_limit_blocked_ips 0", `posto_http_requests_total{method="GET",route="/login",status="200"} 1`} {
+		if !strings.Contains(secondMetrics, line) {
+			t.Errorf("second server metrics are missing %q:\n%s", line, secondMetrics)
+		}
+	}
+}
+
+func TestErrorsFollowAcceptHeader(t *testing.T) {
+	server := newTestServer(t)
+	client := server.newClient()
+
+	expectBody(t, client.get("/blogpost/999"), "<h1>404</h1>")
+
+	response := client.get("/blogpost/999", "Accept", "application/json")
+
+	expectStatus(t, response, http.StatusNotFound)
+
+	if got := decodeJSON(t, response.Body.Bytes())["error"]; got != "post not found" {
+		t.Fatalf("error: got %q, want %q", got, "post not found")
+	}
+
+	// Without an Accept header errors & pages both come back as HTML
+	alice := server.signup("alice", "password1")
+	postPath := "/blogpost/" + strconv.Itoa(alice.createPost("Hello", "World", utils.VISIBILITY_PUBLIC))
+
+	for _, path := range []string{postPath, "/blogpost/999", "/explore"} {
+		if contentType := client.get(path, "Accept", "").Header().Get("Content-Type"); !strings.HasPrefix(contentType, gin.MIMEHTML) {
+			t.Errorf("%s without an Accept header: got %s, want HTML", path, contentType)
+		}
+	}
+}
+
+func TestMediaUploadErrorsFollowAcceptHeader(t *testing.T) {
+	server := newTestServer(t)
+	alice := server.signup("alice", "password1")
+
+	want := fmt.Sprintf("Please choose an image of at most %d MB.", utils.MEDIA_MAX_UPLOAD_BYTES>>20)
+
+	// The upload script asks for JSON, a plain form post gets the error page
+	response := alice.post("/media", nil, "Accept", "application/json")
+
+	expectStatus(t, response, http.StatusBadRequest)
+
+	if got := decodeJSON(t, response.Body.Bytes())["error"]; got != want {
+		t.Fatalf("error: got %q, want %q", got, want)
+	}
+
+	response = alice.post("/media", nil)
+
+	expectStatus(t, response, http.StatusBadRequest)
+	expectBody(t, response, "<h1>400</h1>", want)
+}
+
+func TestCSPNonceIsPerRequest(t *testing.T) {
+	server := newTestServer(t)
+	client := server.newClient()
+
+	var previous string
+
+	for range 2 {
+		response := client.get("/explore")
+
+		// The header's nonce is the one every script tag on the page carries
+		match := regexp.MustCompile(`'nonce-([A-Za-z0-9_-]+)'`).FindStringSubmatch(response.Header().Get("Content-Security-Policy"))
+
+		if match == nil {
+			t.Fatalf("no nonce in the CSP header %q", response.Header().Get("Content-Security-Policy"))
+		}
+
+		expectBody(t, response, `nonce="`+match[1]+`"`)
+
+		if strings.Contains(response.Body.String(), `nonce=""`) {
+			t.Fatal("a script tag was rendered without the request's nonce")
+		}
+
+		if match[1] == previous {
+			t.Fatal("two requests were given the same nonce")
+		}
+
+		previous = match[1]
+	}
+}
+
+func TestDatabaseErrorsAreNotReportedAsNotFound(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Hello", "First post", utils.VISIBILITY_PUBLIC)
+
+	// With the database gone, pages that exist fail as errors instead of claiming to be missing
+	server.db.Close()
+
+	anonymous := server.newClient()
+
+	for _, path := range []string{
+		fmt.Sprintf("/blogpost/%d", postID),
+		"/profile/alice/followers",
+		"/avatar/alice",
+	} {
+		expectStatus(t, anonymous.get(path), http.StatusInternalServerError)
+	}
+}
+
+func TestGoldenPages(t *testing.T) {
+	server := newTestServer(t)
+
+	alice := server.signup("alice", "password1")
+	postID := alice.createPost("Golden post", "Rendered the same every time", utils.VISIBILITY_PUBLIC, "golang", "testing")
+
+	bob := server.signup("bob", "password2")
+	bob.post(fmt.Sprintf("/blogpost/%d/comment", postID), url.Values{"content": {"Looks right"}})
+	bob.post(fmt.Sprintf("/blogpost/%d/like", postID), nil, "Accept", "application/json")
+
+	// Fix the timestamps the page shows
+	server.exec("UPDATE Posts SET CreatedAt = '2024-05-01 09:30:00'")
+	server.exec("UPDATE Comments SET CreatedAt = '2024-05-02 10:00:00'")
+
+	anonymous := server.newClient()
+
+	for name, path := range map[string]string{
+		"login":    "/login",
+		"signup":   "/signup",
+		"notfound": "/no-such-page",
+		"blogpost": fmt.Sprintf("/blogpost/%d", postID),
+	} {
+		t.Run(name, func(t *testing.T) {
+			expectGolden(t, name, anonymous.get(path))
+		})
+	}
+}
+
+func decodeJSON(t *testing.T, body []byte) map[string]any {
+	t.Helper()
+
+	var value map[string]any
+
+	if err := json.Unmarshal(body, &value); err != nil {
+		t.Fatalf("decoding %s: %v", body, err)
+	}
+
+	return value
+}
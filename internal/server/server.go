@@ -0,0 +1,294 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"database/sql"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"App/internal/api"
+	"App/internal/blogservice"
+	"App/internal/cache"
+	"App/internal/certs"
+	"App/internal/config"
+	"App/internal/jobs"
+	"App/internal/logging"
+	"App/internal/mediaservice"
+	"App/internal/metrics"
+	"App/internal/types"
+	"App/internal/userservice"
+	"App/internal/utils"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/sessions"
+)
+
+type Deps struct {
+	DB           types.DB        // Required
+	MediaStore   types.BlobStore // Required
+	SessionStore sessions.Store  // Defaults to the signed cookie store keyed by COOKIE_STORE_KEY
+	KeyCache     cache.KeyStore  // Defaults to an in-memory key cache of its own
+	Clock        types.Clock     // Defaults to the system clock
+	Logger       slog.Handler    // Defaults to the handler set up by the logging package, always wrapped with its redaction
+}
+
+type Server struct {
+	cfg           *config.Config
+	app           *types.App
+	router        *gin.Engine
+	logger        *slog.Logger
+	metrics       *metrics.Metrics
+	rateLimiter   *api.RateLimiter
+	jobs          *jobs.Runner
+	servers       []*http.Server
+	metricsServer *http.Server
+	stopJobs      context.CancelFunc
+}
+
+func New(cfg *config.Config, deps Deps) (*Server, error) {
+	if deps.DB == nil || deps.MediaStore == nil {
+		return nil, errors.New("a database & media store are required")
+	}
+
+	// Register the User type for encoding/decoding
+	gob.Register(types.User{})
+
+	// Each server keeps its own keys, so two servers in one process never see each other's users
+	if deps.KeyCache == nil {
+		deps.KeyCache = cache.NewMemoryKeyStore()
+	}
+
+	if deps.Clock == nil {
+		deps.Clock = utils.SystemClock{}
+	}
+
+	if deps.Logger == nil {
+		deps.Logger = slog.Default().Handler()
+	}
+
+	// An injected handler still gets request IDs & has usernames, tokens etc. redacted
+	s := &Server{cfg: cfg, logger: slog.New(logging.NewHandler(deps.Logger)), metrics: metrics.New(), rateLimiter: api.NewRateLimiter()}
+	s.jobs = jobs.NewRunner(s.logger)
+
+	if deps.SessionStore == nil {
+		deps.SessionStore = newCookieStore(cfg.CookieStoreKey, cfg.CookieDomain)
+	}
+
+	// Create app struct for accessing session, database, keys, clock & logger
+	s.app = &types.App{
+		SessionStore: deps.SessionStore,
+		Database:     deps.DB,
+		MediaStore:   deps.MediaStore,
+		KeyCache:     cache.New(deps.KeyCache, s.metrics),
+		Clock:        deps.Clock,
+		Logger:       s.logger,
+		Metrics:      s.metrics,
+		PostsPerPage: cfg.PostsPerPage,
+		SiteURL:      cfg.SiteURL,
+	}
+
+	// Create a router with the middleware, templates & routes that map requests to handlers
+	router, err := newRouter(cfg, s.app, s.rateLimiter)
+
+	if err != nil {
+		return nil, err
+	}
+
+	s.router = router
+
+	// Expose connection pool, rate limiter & key cache state alongside the request metrics
+	if db, ok := deps.DB.(*sql.DB); ok {
+		s.metrics.RegisterDB(db)
+	}
+
+	s.metrics.RegisterGauge("rate_limit_blocked_ips", "IPs currently blocked by the rate limiter.", func() float64 {
+		return float64(s.rateLimiter.BlockedIPCount())
+	})
+	s.metrics.RegisterGauge("key_cache_size", "User encryption keys held in the key cache.", func() float64 {
+		return float64(s.app.KeyCache.Len())
+	})
+
+	return s, nil
+}
+
+func (s *Server) Handler() http.Handler {
+	return s.router
+}
+
+func (s *Server) Start(ctx context.Context) error {
+	// Serve /metrics on the admin address so it never reaches the public port
+	if s.cfg.MetricsAddr != utils.METRICS_DISABLED {
+		s.metricsServer = s.newMetricsServer(s.cfg.MetricsAddr)
+	}
+
+	// Jobs run until Shutdown, which waits for their current run
+	jobsCtx, stopJobs := context.WithCancel(ctx)
+	s.stopJobs = stopJobs
+	s.startJobs(jobsCtx)
+
+	// Without TLS, serve plain HTTP on port 8080 and let NGINX in front of it terminate SSL
+	s.servers = []*http.Server{newHTTPServer(s.cfg.HTTPAddr, s.router)}
+
+	if s.cfg.TLSMode != utils.TLS_MODE_OFF {
+		tlsConfig, redirect, err := setupTLS(ctx, s.cfg, s.logger)
+
+		if err != nil {
+			return fmt.Errorf("error setting up TLS: %w", err)
+		}
+
+		// The site moves to the HTTPS address, the HTTP one only redirects there
+		httpsServer := newHTTPServer(s.cfg.HTTPSAddr, s.router)
+		httpsServer.TLSConfig = tlsConfig
+
+		s.servers = []*http.Server{newHTTPServer(s.cfg.HTTPAddr, redirect), httpsServer}
+	}
+
+	serverErrors := make(chan error, len(s.servers))
+
+	for _, server := range s.servers {
+		go func() {
+			if server.TLSConfig != nil {
+				s.logger.Info("Serving HTTPS", "addr", server.Addr)
+				serverErrors <- server.ListenAndServeTLS("", "")
+				return
+			}
+
+			s.logger.Info("Serving HTTP", "addr", server.Addr)
+			serverErrors <- server.ListenAndServe()
+		}()
+	}
+
+	// Run until a server fails or the context is canceled
+	select {
+	case err := <-serverErrors:
+		return err
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	var errs []error
+
+	// Stop accepting connections & wait for in-flight requests to finish
+	for _, server := range s.servers {
+		if err := server.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s did not drain in time: %w", server.Addr, err))
+		}
+	}
+
+	if s.metricsServer != nil {
+		s.metricsServer.Shutdown(ctx)
+	}
+
+	// Wait for the jobs' current run before the caller closes the database
+	if s.stopJobs != nil {
+		s.stopJobs()
+		s.jobs.Wait()
+	}
+
+	return errors.Join(errs...)
+}
+
+func (s *Server) startJobs(ctx context.Context) {
+	app := s.app
+
+	// Periodically rebuild the cached trending rankings for the explore page
+	s.jobs.RunPeriodically(ctx, "trending", s.cfg.TrendingRefreshInterval, func(ctx context.Context) error {
+		return blogservice.RefreshTrendingPosts(ctx, app)
+	})
+
+	// Remove accounts whose deletion grace period has passed
+	s.jobs.RunPeriodically(ctx, "account-purge", utils.ACCOUNT_PURGE_INTERVAL_MIN*time.Minute, func(ctx context.Context) error {
+		return userservice.PurgeDeletedAccounts(ctx, app)
+	})
+
+	// Remove images that were uploaded but never saved with a post
+	s.jobs.RunPeriodically(ctx, "media-cleanup", utils.MEDIA_CLEANUP_INTERVAL_MIN*time.Minute, func(ctx context.Context) error {
+		return mediaservice.DeleteUnattachedMedia(ctx, app)
+	})
+
+	// Remove share links once they have expired
+	s.jobs.RunPeriodically(ctx, "share-link-cleanup", utils.SHARE_LINK_CLEANUP_INTERVAL_MIN*time.Minute, func(ctx context.Context) error {
+		return blogservice.DeleteExpiredShareLinks(ctx, app)
+	})
+}
+
+func newCookieStore(key, domain string) *sessions.CookieStore {
+	// Create a cookie store for session management
+	cookieStore := sessions.NewCookieStore([]byte(key))
+	cookieStore.Options.HttpOnly = true
+	cookieStore.Options.SameSite = http.SameSiteStrictMode
+	cookieStore.Options.Domain = domain
+	cookieStore.Options.MaxAge = 604800
+	cookieStore.Options.Secure = true
+
+	return cookieStore
+}
+
+func newHTTPServer(addr string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: utils.SERVER_READ_HEADER_TIMEOUT_SEC * time.Second,
+		ReadTimeout:       utils.SERVER_READ_TIMEOUT_SEC * time.Second,
+		WriteTimeout:      utils.SERVER_WRITE_TIMEOUT_SEC * time.Second,
+		IdleTimeout:       utils.SERVER_IDLE_TIMEOUT_SEC * time.Second,
+	}
+}
+
+func setupTLS(ctx context.Context, cfg *config.Config, logger *slog.Logger) (*tls.Config, http.Handler, error) {
+	redirect := certs.RedirectHandler(cfg.HTTPSAddr)
+
+	if cfg.TLSMode == utils.TLS_MODE_ACME {
+		manager, err := certs.NewACMEManager(cfg)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// The HTTP address also answers http-01 challenges before redirecting
+		tlsConfig := manager.TLSConfig()
+		tlsConfig.MinVersion = tls.VersionTLS12
+
+		return tlsConfig, manager.HTTPHandler(redirect), nil
+	}
+
+	reloader, err := certs.NewReloader(cfg.TLSCertFile, cfg.TLSKeyFile)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Pick up renewed certificates without a restart
+	reloader.Watch(ctx, utils.TLS_RELOAD_INTERVAL_SEC*time.Second, logger)
+
+	return certs.TLSConfig(reloader.GetCertificate), redirect, nil
+}
+
+func (s *Server) newMetricsServer(addr string) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", s.metrics.Handler())
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: utils.SERVER_READ_HEADER_TIMEOUT_SEC * time.Second,
+	}
+
+	go func() {
+		s.logger.Info("Serving metrics", "addr", addr)
+
+		// The site keeps running without metrics if the admin port can't be opened
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			s.logger.Error("Metrics server stopped", "addr", addr, "error", err)
+		}
+	}()
+
+	return server
+}
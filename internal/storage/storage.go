@@ -0,0 +1,41 @@
+package storage
+
+import (
+	"App/internal/config"
+	"App/internal/types"
+	"App/internal/utils"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+func Open(cfg *config.Config) (types.BlobStore, error) {
+	// Pick the blob store backend named in the configuration
+	switch cfg.MediaStore {
+	case utils.MEDIA_STORE_LOCAL:
+		return NewLocalStore(cfg.MediaDir)
+	case utils.MEDIA_STORE_S3:
+		return NewS3Store(S3Config{
+			Endpoint:        cfg.S3Endpoint,
+			Region:          cfg.S3Region,
+			Bucket:          cfg.S3Bucket,
+			AccessKeyID:     cfg.S3AccessKeyID,
+			SecretAccessKey: cfg.S3SecretAccessKey,
+			UsePathStyle:    cfg.S3UsePathStyle,
+		})
+	default:
+		return nil, fmt.Errorf("unknown media store %q", cfg.MediaStore)
+	}
+}
+
+func validateKey(key string) error {
+	// Keys are relative slash separated paths that never climb out of the store
+	if key == "" || strings.HasPrefix(key, "/") || path.Clean(key) != key || strings.HasPrefix(key, "..") {
+		return fmt.Errorf("invalid blob key %q", key)
+	}
+
+	return nil
+}
@@ -0,0 +1,98 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"App/internal/types"
+
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+func TestLocalStore(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+
+	if err != nil {
+		t.Fatalf("NewLocalStore: %v", err)
+	}
+
+	testBlobStore(t, store)
+}
+
+func TestS3Store(t *testing.T) {
+	// Serve a fake S3 API from memory
+	backend := s3mem.New()
+
+	if err := backend.CreateBucket("media"); err != nil {
+		t.Fatalf("CreateBucket: %v", err)
+	}
+
+	server := httptest.NewServer(gofakes3.New(backend).Server())
+	defer server.Close()
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:        server.URL,
+		Region:          "us-east-1",
+		Bucket:          "media",
+		AccessKeyID:     "test",
+		SecretAccessKey: "test",
+		UsePathStyle:    true,
+	})
+
+	if err != nil {
+		t.Fatalf("NewS3Store: %v", err)
+	}
+
+	testBlobStore(t, store)
+}
+
+func testBlobStore(t *testing.T, store types.BlobStore) {
+	ctx := context.Background()
+	key := "media/ab/abcdef"
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put: got %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(ctx, key, []byte("first"), "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+
+	// Writing the same key again replaces the blob
+	if err := store.Put(ctx, key, []byte("second"), "image/png"); err != nil {
+		t.Fatalf("Put overwrite: %v", err)
+	}
+
+	data, err := store.Get(ctx, key)
+
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+
+	if !bytes.Equal(data, []byte("second")) {
+		t.Fatalf("Get: got %q, want %q", data, "second")
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get after Delete: got %v, want ErrNotFound", err)
+	}
+
+	// Deleting twice is harmless
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete missing blob: %v", err)
+	}
+
+	for _, badKey := range []string{"", "/etc/passwd", "../outside", "media/../../outside", "media//double"} {
+		if err := store.Put(ctx, badKey, []byte("x"), "text/plain"); err == nil {
+			t.Errorf("Put(%q) succeeded, want an invalid key error", badKey)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package templates
+
+import "embed"
+
+// Compiled into the binary so the site renders no matter which directory it runs from
+//
+//go:embed *.html
+var Files embed.FS
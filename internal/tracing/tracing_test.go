@@ -0,0 +1,174 @@
+package tracing
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"net/http/httptest"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func init() {
+	sql.Register("tracingtest", fakeDriver{})
+}
+
+func TestOpenDBTracesQueriesUnderParent(t *testing.T) {
+	exporter := useInMemoryExporter(t, 1)
+
+	db, err := OpenDB("tracingtest", "")
+
+	if err != nil {
+		t.Fatalf("OpenDB: %v", err)
+	}
+
+	defer db.Close()
+
+	ctx, parent := Start(context.Background(), "blogservice.GetLikesCount")
+
+	if _, err := db.ExecContext(ctx, "UPDATE posts SET title = ? WHERE id = ?", "title", 1); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+
+	parent.End()
+
+	spans := exporter.GetSpans()
+
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want the query & its parent", len(spans))
+	}
+
+	query := spans[0]
+
+	if query.Name != "sql.conn.exec" {
+		t.Errorf("query span name: got %q, want %q", query.Name, "sql.conn.exec")
+	}
+
+	if query.Parent.SpanID() != parent.SpanContext().SpanID() {
+		t.Errorf("query span is not a child of the service span")
+	}
+
+	// The attribute name depends on which semantic conventions otelsql is opted into
+	statement := "UPDATE posts SET title = ? WHERE id = ?"
+
+	if !hasAttribute(query, "db.statement", statement) && !hasAttribute(query, "db.query.text", statement) {
+		t.Errorf("query span is missing the query text: %v", query.Attributes)
+	}
+}
+
+func TestOpenDBSkipsUntracedQueries(t *testing.T) {
+	exporter := useInMemoryExporter(t, 1)
+
+	db, err := OpenDB("tracingtest", "")
+
+	if err != nil {
+		t.Fatalf("OpenDB: %v", err)
+	}
+
+	defer db.Close()
+
+	// Queries outside a request or job, like migrations, don't start traces of their own
+	if _, err := db.ExecContext(context.Background(), "DELETE FROM share_links"); err != nil {
+		t.Fatalf("ExecContext: %v", err)
+	}
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("got %d spans, want none", len(spans))
+	}
+}
+
+func TestStartRequestContinuesIncomingTrace(t *testing.T) {
+	exporter := useInMemoryExporter(t, 1)
+
+	request := httptest.NewRequest("GET", "/blogpost/7", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+	ctx, span := StartRequest(request, "/blogpost/:ID")
+	span.End()
+
+	spans := exporter.GetSpans()
+
+	if len(spans) != 1 {
+		t.Fatalf("got %d spans, want 1", len(spans))
+	}
+
+	if spans[0].Name != "GET /blogpost/:ID" {
+		t.Errorf("span name: got %q, want %q", spans[0].Name, "GET /blogpost/:ID")
+	}
+
+	if spans[0].SpanKind != trace.SpanKindServer {
+		t.Errorf("span kind: got %v, want server", spans[0].SpanKind)
+	}
+
+	if got := TraceID(ctx); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("TraceID: got %q, want the caller's trace ID", got)
+	}
+}
+
+func TestSampleRatio(t *testing.T) {
+	exporter := useInMemoryExporter(t, 0)
+
+	_, span := Start(context.Background(), "blogservice.GetBlogPostData")
+	span.End()
+
+	if spans := exporter.GetSpans(); len(spans) != 0 {
+		t.Fatalf("got %d spans with sampling off, want none", len(spans))
+	}
+}
+
+func TestTraceIDWithoutSpan(t *testing.T) {
+	if got := TraceID(context.Background()); got != "" {
+		t.Fatalf("TraceID: got %q, want empty", got)
+	}
+}
+
+func useInMemoryExporter(t *testing.T, sampleRatio float64) *tracetest.InMemoryExporter {
+	t.Helper()
+
+	// Export synchronously so spans are visible as soon as they end
+	exporter := tracetest.NewInMemoryExporter()
+	provider := newProvider(sdktrace.WithSyncer(exporter), sampleRatio)
+
+	previousProvider := otel.GetTracerProvider()
+	previousPropagator := otel.GetTextMapPropagator()
+
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	t.Cleanup(func() {
+		provider.Shutdown(context.Background())
+		otel.SetTracerProvider(previousProvider)
+		otel.SetTextMapPropagator(previousPropagator)
+	})
+
+	return exporter
+}
+
+func hasAttribute(span tracetest.SpanStub, key, value string) bool {
+	for _, attr := range span.Attributes {
+		if string(attr.Key) == key && attr.Value.AsString() == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, driver.ErrSkip }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return nil, driver.ErrSkip }
+
+func (fakeConn) ExecContext(context.Context, string, []driver.NamedValue) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
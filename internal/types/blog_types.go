@@ -1,34 +1,55 @@
 package types
 
+import "time"
+
 type BlogPageData struct {
-	Username    string
-	Posts       []*BlogPostData
-	IsOwner     bool
-	IsLoggedIn  bool
-	IsFollowing bool
-	Tabs        int
-	CurrentPage int
+	Username     string
+	Profile      *UserProfile
+	Posts        []*BlogPostData
+	IsOwner      bool
+	IsLoggedIn   bool
+	Relationship *UserRelationship
+	IsLocked     bool // Private account the viewer doesn't follow
+	Tabs         int
+	CurrentPage  int
+	Tag          string
+	TagCloud     []*TagCount
+	NextCursor   string
 }
 
 type BlogPostData struct {
 	ID int
 	BlogPostBase
+	CreatedAt   string
+	PublishedAt time.Time
+	Cursor      string
+}
+
+type PostCursor struct {
 	CreatedAt string
+	ID        int
 }
 
 type HomeFeedData struct {
 	BlogPostData
-	Username string
+	Username    string
+	DisplayName string
 }
 
 type BlogPostPageData struct {
 	Post         *BlogPostData
 	Username     string
+	DisplayName  string
+	AvatarURL    string
 	IsLoggedIn   bool
 	IsOwner      bool
 	Comments     []*Comment
+	Media        []*Media
 	LikesCount   int
 	HasUserLiked bool
+	IsBookmarked bool
+	BookmarkedIn int
+	Collections  []*BookmarkCollection
 }
 
 type CreateComment struct {
@@ -38,29 +59,35 @@ type CreateComment struct {
 }
 
 type Comment struct {
-	ID        int    `json:"id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"createdAt"`
-	Username  string `json:"username"`
+	ID          int    `json:"id"`
+	Content     string `json:"content"`
+	CreatedAt   string `json:"createdAt"`
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	AvatarURL   string `json:"avatarUrl,omitempty"`
 }
 
 type BlogPostFormData struct {
 	Username  string
 	IsEditing bool
 	PostID    int
+	Media     []*Media
 	BlogPostBase
 }
 
 type BlogPostBase struct {
-	Title    string
-	IsPublic bool
-	Content  string
+	Title      string
+	Visibility string
+	Content    string
+	Tags       []string
 }
 
 type BlogPreview struct {
-	ID      int    `json:"id"`
-	Title   string `json:"title"`
-	Content string `json:"content"`
+	ID        int      `json:"id"`
+	Title     string   `json:"title"`
+	Content   string   `json:"content"`
+	CreatedAt string   `json:"createdAt"`
+	Tags      []string `json:"tags"`
 }
 
 type CreateBlogPost struct {
@@ -78,4 +105,55 @@ type HomeFeedPage struct {
 	Posts       []*HomeFeedData
 	CurrentPage int
 	Tabs        int
+	Tag         string
+	NextCursor  string
+}
+
+type TagCount struct {
+	Name  string `json:"name"`
+	Count int    `json:"count"`
+}
+
+type TagPageData struct {
+	Tag         string
+	Posts       []*HomeFeedData
+	IsLoggedIn  bool
+	CurrentPage int
+	Tabs        int
+	NextCursor  string
+}
+
+type FeedPreview struct {
+	ID          int      `json:"id"`
+	Title       string   `json:"title"`
+	Content     string   `json:"content"`
+	Username    string   `json:"username"`
+	DisplayName string   `json:"displayName"`
+	CreatedAt   string   `json:"createdAt"`
+	Tags        []string `json:"tags"`
+}
+
+type TrendingPostData struct {
+	HomeFeedData
+	LikesCount    int
+	CommentsCount int
+}
+
+type ExplorePageData struct {
+	Posts       []*TrendingPostData
+	IsLoggedIn  bool
+	Tag         string
+	Window      string
+	CurrentPage int
+	Tabs        int
+}
+
+type TrendingPreview struct {
+	FeedPreview
+	LikesCount    int `json:"likesCount"`
+	CommentsCount int `json:"commentsCount"`
+}
+
+type HomePageData struct {
+	Posts []*TrendingPostData
 }
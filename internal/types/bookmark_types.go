@@ -0,0 +1,28 @@
+package types
+
+type BookmarkCollection struct {
+	ID   int    `json:"id"`
+	Name string `json:"name"`
+}
+
+type SavedPost struct {
+	HomeFeedData
+	SavedAt    string
+	Collection string
+}
+
+type SavedPreview struct {
+	FeedPreview
+	SavedAt    string `json:"savedAt"`
+	Collection string `json:"collection,omitempty"`
+}
+
+type SavedPageData struct {
+	Posts        []*SavedPost
+	Collections  []*BookmarkCollection
+	CollectionID int
+	Collection   string
+	CanCreate    bool
+	CurrentPage  int
+	Tabs         int
+}
@@ -0,0 +1,24 @@
+package types
+
+type CSPReport struct {
+	EffectiveDirective string `json:"effective-directive"`
+	BlockedURI         string `json:"blocked-uri"`
+	SourceFile         string `json:"source-file"`
+	LineNumber         int    `json:"line-number"`
+	Disposition        string `json:"disposition"`
+}
+
+type CSPLegacyReport struct {
+	Report CSPReport `json:"csp-report"`
+}
+
+type CSPReportingAPIReport struct {
+	Type string `json:"type"`
+	Body struct {
+		EffectiveDirective string `json:"effectiveDirective"`
+		BlockedURL         string `json:"blockedURL"`
+		SourceFile         string `json:"sourceFile"`
+		LineNumber         int    `json:"lineNumber"`
+		Disposition        string `json:"disposition"`
+	} `json:"body"`
+}
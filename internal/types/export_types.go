@@ -0,0 +1,37 @@
+package types
+
+type ExportProfile struct {
+	Username   string `json:"username"`
+	CreatedAt  string `json:"createdAt"`
+	ExportedAt string `json:"exportedAt"`
+	PostCount  int    `json:"postCount"`
+}
+
+type ExportPost struct {
+	ID            int
+	Title         string
+	Content       string
+	CreatedAt     string
+	Visibility    string
+	Tags          []string
+	Encrypted     bool
+	EncryptedTags string
+}
+
+type ExportComment struct {
+	ID        int    `json:"id"`
+	PostID    int    `json:"postId"`
+	Comment   string `json:"comment"`
+	CreatedAt string `json:"createdAt"`
+}
+
+type ExportLike struct {
+	PostID     int    `json:"postId"`
+	PostAuthor string `json:"postAuthor"`
+	CreatedAt  string `json:"createdAt"`
+}
+
+type ExportFollow struct {
+	Username  string `json:"username"`
+	CreatedAt string `json:"createdAt"`
+}
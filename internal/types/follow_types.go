@@ -0,0 +1,38 @@
+package types
+
+type UserRelationship struct {
+	UserID      int  `json:"-"`
+	IsPrivate   bool `json:"isPrivate"`
+	IsFollowing bool `json:"isFollowing"`
+	IsRequested bool `json:"isRequested"`
+	IsBlocked   bool `json:"isBlocked"`
+	IsBlockedBy bool `json:"-"`
+	IsMuted     bool `json:"isMuted"`
+	FollowsYou  bool `json:"followsYou"`
+}
+
+type FollowUser struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"displayName"`
+	AvatarURL   string `json:"avatarUrl,omitempty"`
+	Since       string `json:"since"`
+	FollowsYou  bool   `json:"followsYou"`
+	IsFollowing bool   `json:"isFollowing"`
+}
+
+type FollowListPageData struct {
+	Username    string
+	DisplayName string
+	List        string
+	Users       []*FollowUser
+	Total       int
+	IsLoggedIn  bool
+	CurrentPage int
+	Tabs        int
+}
+
+type FollowRequestsPageData struct {
+	Requests    []*FollowUser
+	CurrentPage int
+	Tabs        int
+}
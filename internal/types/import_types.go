@@ -0,0 +1,27 @@
+package types
+
+import "time"
+
+type ImportItem struct {
+	Source     string
+	Title      string
+	Content    string
+	CreatedAt  time.Time
+	Visibility string
+	Tags       []string
+	Err        error
+}
+
+type ImportResult struct {
+	Source string `json:"source"`
+	Title  string `json:"title"`
+	PostID int    `json:"postId,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type ImportPageData struct {
+	Username string
+	Results  []*ImportResult
+	Imported int
+	Failed   int
+}
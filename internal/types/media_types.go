@@ -0,0 +1,32 @@
+package types
+
+import "context"
+
+type BlobStore interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) ([]byte, error)
+	Delete(ctx context.Context, key string) error
+}
+
+type Media struct {
+	ID           int    `json:"-"`
+	Token        string `json:"id"`
+	UserID       int    `json:"-"`
+	PostID       int    `json:"postId,omitempty"`
+	ContentType  string `json:"contentType"`
+	Width        int    `json:"width"`
+	Height       int    `json:"height"`
+	Size         int    `json:"size"`
+	IsEncrypted  bool   `json:"encrypted"`
+	IsRestricted bool   `json:"-"`
+	URL          string `json:"url"`
+	ThumbnailURL string `json:"thumbnailUrl"`
+}
+
+type ProcessedImage struct {
+	Data        []byte
+	Thumbnail   []byte
+	ContentType string
+	Width       int
+	Height      int
+}
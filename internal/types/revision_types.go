@@ -0,0 +1,29 @@
+package types
+
+type PostRevision struct {
+	ID         int      `json:"id"`
+	Number     int      `json:"number"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Visibility string   `json:"visibility"`
+	Tags       []string `json:"tags"`
+	CreatedAt  string   `json:"createdAt"`
+	IsCurrent  bool     `json:"isCurrent"`
+}
+
+type DiffLine struct {
+	Op   string `json:"op"`
+	Text string `json:"text"`
+}
+
+type RevisionsPageData struct {
+	PostID       int
+	Title        string
+	Username     string
+	Revisions    []*PostRevision
+	From         *PostRevision
+	To           *PostRevision
+	Diff         []DiffLine
+	TitleChanged bool
+	TagsChanged  bool
+}
@@ -0,0 +1,28 @@
+package types
+
+type ShareLink struct {
+	ID        int
+	Token     string
+	URL       string
+	CreatedAt string
+	ExpiresAt string
+}
+
+type ShareLinksPageData struct {
+	PostID    int
+	Title     string
+	Links     []*ShareLink
+	CanCreate bool
+}
+
+type SharedPostPageData struct {
+	PostID      int
+	Title       string
+	Content     string
+	Tags        string
+	Username    string
+	DisplayName string
+	AvatarURL   string
+	CreatedAt   string
+	ExpiresAt   string
+}
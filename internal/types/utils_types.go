@@ -1,14 +1,60 @@
 package types
 
 import (
+	"context"
 	"database/sql"
+	"log/slog"
+	"time"
 
 	"github.com/gorilla/sessions"
 )
 
+// The queries the services run, satisfied by *sql.DB & *sql.Tx alike
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// A connection the services can also start transactions on, satisfied by *sql.DB or anything that wraps one
+type DB interface {
+	Querier
+	BeginTx(ctx context.Context, opts *sql.TxOptions) (*sql.Tx, error)
+	PingContext(ctx context.Context) error
+}
+
+// Derives users' encryption keys from their passwords & holds them while they are logged in
+type KeyCache interface {
+	DeriveAndCache(userID int, password string, salt []byte) error
+	Get(userID int) ([]byte, error)
+	Has(userID int) bool
+	Remove(userID int)
+	Len() int
+}
+
+// The time the services see, swapped out in tests to move past an expiry
+type Clock interface {
+	Now() time.Time
+}
+
+// Records the request, rate limit, key cache & crypto metrics of one server
+type Metrics interface {
+	ObserveRequest(method, route string, status int, elapsed time.Duration)
+	RateLimitBlocked()
+	KeyCacheLookup(found bool)
+	ObserveCrypto(operation string, start time.Time)
+}
+
 type App struct {
-	SessionStore *sessions.CookieStore
-	Database     *sql.DB
+	SessionStore sessions.Store
+	Database     DB
+	MediaStore   BlobStore
+	KeyCache     KeyCache
+	Clock        Clock
+	Logger       *slog.Logger
+	Metrics      Metrics
+	PostsPerPage int
+	SiteURL      string
 }
 
 type User struct {
@@ -20,3 +66,30 @@ type ErrorPageData struct {
 	StatusCode   int
 	ErrorMessage string
 }
+
+type DeleteAccountPageData struct {
+	Username     string
+	Scheduled    bool
+	DeletionDate string
+}
+
+type UserProfile struct {
+	Username       string   `json:"username"`
+	DisplayName    string   `json:"displayName"`
+	Bio            string   `json:"bio"`
+	Links          []string `json:"links"`
+	AvatarURL      string   `json:"avatarUrl,omitempty"`
+	IsPrivate      bool     `json:"isPrivate"`
+	FollowersCount int      `json:"followersCount"`
+	FollowingCount int      `json:"followingCount"`
+}
+
+type ProfileFormData struct {
+	Username    string
+	DisplayName string
+	Bio         string
+	Links       string
+	AvatarURL   string
+	IsPrivate   bool
+	Saved       bool
+}
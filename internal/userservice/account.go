@@ -0,0 +1,186 @@
+package userservice
+
+import (
+	"App/internal/mediaservice"
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"context"
+	"errors"
+	"time"
+)
+
+func RequestAccountDeletion(ctx context.Context, app *types.App, userID int, username, password string) (time.Time, error) {
+	ctx, span := tracing.Start(ctx, "userservice.RequestAccountDeletion")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Deleting an account always needs the current password
+	id, _, err := verifyPassword(ctx, app, username, password)
+
+	if errors.Is(err, utils.ErrUnauthorized) || err == nil && id != userID {
+		return time.Time{}, utils.Unauthorized("incorrect password, your account was not deleted")
+	} else if err != nil {
+		return time.Time{}, err
+	}
+
+	requestedAt := app.Clock.Now().UTC()
+
+	if _, err := app.Database.ExecContext(ctx, utils.RequestAccountDeletionQuery, requestedAt.Format("2006-01-02 15:04:05"), userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while scheduling deletion", "user_id", userID, "error", err)
+		return time.Time{}, utils.DatabaseError(ctx, err, "failed to schedule account deletion")
+	}
+
+	// Forget the key so every session has to log in again, which is also how deletion is cancelled
+	app.KeyCache.Remove(userID)
+
+	app.Logger.InfoContext(ctx, "Account deletion requested", "user_id", userID)
+
+	return requestedAt.AddDate(0, 0, utils.ACCOUNT_DELETION_GRACE_DAYS), nil
+}
+
+func CancelAccountDeletion(ctx context.Context, app *types.App, userID int) error {
+	ctx, span := tracing.Start(ctx, "userservice.CancelAccountDeletion")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	result, err := app.Database.ExecContext(ctx, utils.CancelAccountDeletionQuery, userID)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while cancelling deletion", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to restore account")
+	}
+
+	if rows, _ := result.RowsAffected(); rows > 0 {
+		app.Logger.InfoContext(ctx, "Account deletion cancelled", "user_id", userID)
+	}
+
+	return nil
+}
+
+func PurgeDeletedAccounts(ctx context.Context, app *types.App) error {
+	ctx, span := tracing.Start(ctx, "userservice.PurgeDeletedAccounts")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_BATCH_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Only accounts whose grace period has fully passed are removed
+	cutoff := app.Clock.Now().UTC().AddDate(0, 0, -utils.ACCOUNT_DELETION_GRACE_DAYS).Format("2006-01-02 15:04:05")
+
+	rows, err := app.Database.QueryContext(ctx, utils.SelectAccountsDueForDeletionQuery, cutoff)
+
+	if err != nil {
+		return utils.DatabaseError(ctx, err, "failed to query accounts due for deletion")
+	}
+
+	type dueAccount struct {
+		id       int
+		username string
+	}
+
+	var accounts []dueAccount
+
+	for rows.Next() {
+		var account dueAccount
+
+		if err := rows.Scan(&account.id, &account.username); err != nil {
+			rows.Close()
+			return utils.DatabaseError(ctx, err, "failed to scan account due for deletion")
+		}
+
+		accounts = append(accounts, account)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to iterate accounts due for deletion")
+	}
+
+	// Keep going past a failed account so one bad row doesn't block the rest
+	var failed int
+
+	for _, account := range accounts {
+		if err := deleteAccount(ctx, app, account.id); err != nil {
+			app.Logger.ErrorContext(ctx, "Failed to delete account", "user_id", account.id, "error", err)
+			failed++
+			continue
+		}
+
+		app.Logger.InfoContext(ctx, "Deleted account after the grace period", "user_id", account.id, "username", account.username)
+	}
+
+	if failed > 0 {
+		return utils.Internal(nil, "failed to delete %d of %d accounts", failed, len(accounts))
+	}
+
+	return nil
+}
+
+func deleteAccount(ctx context.Context, app *types.App, userID int) error {
+	// Look up the uploaded images first, their blobs are removed once the rows are gone
+	media, err := mediaservice.GetMediaOfUser(ctx, app, userID)
+
+	if err != nil {
+		return err
+	}
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		return utils.DatabaseError(ctx, err, "failed to start account deletion")
+	}
+
+	defer tx.Rollback()
+
+	// Children go first so this works whether or not the foreign keys cascade
+	steps := []struct {
+		query string
+		args  []any
+	}{
+		{utils.DeleteLikesByUserQuery, []any{userID}},
+		{utils.DeleteLikesOnUserPostsQuery, []any{userID}},
+		{utils.DeleteCommentsByUserQuery, []any{userID}},
+		{utils.DeleteCommentsOnUserPostsQuery, []any{userID}},
+		{utils.DeleteTagsOnUserPostsQuery, []any{userID}},
+		{utils.DeletePrivateTagsOfUserQuery, []any{userID}},
+		{utils.DeleteTrendingUserPostsQuery, []any{userID}},
+		{utils.DeleteRevisionsOfUserPostsQuery, []any{userID}},
+		{utils.DeleteShareLinksOfUserQuery, []any{userID}},
+		{utils.DeleteBookmarksOnUserPostsQuery, []any{userID}},
+		{utils.DeleteBookmarksByUserQuery, []any{userID}},
+		{utils.DeleteBookmarkCollectionsQuery, []any{userID}},
+		{utils.DeletePostsByUserQuery, []any{userID}},
+		{utils.DeleteFollowsOfUserQuery, []any{userID, userID}},
+		{utils.DeleteFollowRequestsOfUserQuery, []any{userID, userID}},
+		{utils.DeleteBlocksOfUserQuery, []any{userID, userID}},
+		{utils.DeleteMutesOfUserQuery, []any{userID, userID}},
+		{utils.DeleteAvatarOfUserQuery, []any{userID}},
+		{utils.DeleteMediaOfUserQuery, []any{userID}},
+		{utils.DeleteUserQuery, []any{userID}},
+	}
+
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			return utils.DatabaseError(ctx, err, "failed to delete account data")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return utils.DatabaseError(ctx, err, "failed to commit account deletion")
+	}
+
+	// Sessions are cookies, without the key (and now the user) none of them work
+	app.KeyCache.Remove(userID)
+
+	if err := mediaservice.DeleteMedia(ctx, app, media); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to delete media", "user_id", userID, "error", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,326 @@
+package userservice
+
+import (
+	"App/internal/tracing"
+	"App/internal/types"
+	"App/internal/utils"
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"image"
+	_ "image/gif"
+	_ "image/jpeg"
+	"image/png"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+	"golang.org/x/text/unicode/norm"
+)
+
+func GetUserProfile(ctx context.Context, app *types.App, username string) (*types.UserProfile, error) {
+	ctx, span := tracing.Start(ctx, "userservice.GetUserProfile")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	profile := &types.UserProfile{}
+	var linksJSON string
+	var avatarUpdatedAt []byte
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectUserProfileQuery, username).Scan(
+		&profile.Username, &profile.DisplayName, &profile.Bio, &linksJSON, &avatarUpdatedAt, &profile.IsPrivate,
+		&profile.FollowersCount, &profile.FollowingCount,
+	); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, utils.NotFound("user not found")
+		}
+
+		app.Logger.ErrorContext(ctx, "SQL query error while loading profile", "username", username, "error", err)
+		return nil, utils.DatabaseError(ctx, err, "failed to load profile")
+	}
+
+	// Links are stored as a JSON array, an unreadable value just hides them
+	profile.Links = []string{}
+
+	if linksJSON != "" {
+		if err := json.Unmarshal([]byte(linksJSON), &profile.Links); err != nil {
+			app.Logger.WarnContext(ctx, "Invalid profile links", "username", username, "error", err)
+			profile.Links = []string{}
+		}
+	}
+
+	profile.AvatarURL = utils.AvatarURL(profile.Username, avatarUpdatedAt)
+	profile.DisplayName = utils.DisplayName(profile.DisplayName, profile.Username)
+
+	return profile, nil
+}
+
+func UpdateUserProfile(ctx context.Context, app *types.App, userID int, displayName, bio, linksText string, isPrivate bool) error {
+	ctx, span := tracing.Start(ctx, "userservice.UpdateUserProfile")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	displayName, err := NormalizeDisplayName(displayName)
+
+	if err != nil {
+		return err
+	}
+
+	// Bios keep their line breaks but lose stray whitespace around them
+	bio = strings.TrimSpace(norm.NFC.String(strings.ReplaceAll(bio, "\r\n", "\n")))
+
+	if utf8.RuneCountInString(bio) > utils.BIO_MAX_LENGTH {
+		return utils.Validation("bio must be at most %d characters", utils.BIO_MAX_LENGTH)
+	}
+
+	links, err := parseProfileLinks(linksText)
+
+	if err != nil {
+		return err
+	}
+
+	linksJSON, _ := json.Marshal(links)
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for profile", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update profile")
+	}
+
+	defer tx.Rollback()
+
+	// Empty fields are stored as NULL so names fall back to the username
+	if _, err := tx.ExecContext(ctx, utils.UpdateUserProfileQuery, nullIfEmpty(displayName), nullIfEmpty(bio), nullIfEmpty(string(linksJSON), "[]"), isPrivate, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while updating profile", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update profile")
+	}
+
+	// A public account has nobody left to approve, so pending requests become follows
+	if !isPrivate {
+		if _, err := tx.ExecContext(ctx, utils.ApproveAllFollowRequestsQuery, userID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while approving follow requests", "user_id", userID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update profile")
+		}
+
+		if _, err := tx.ExecContext(ctx, utils.DeleteFollowRequestsToUserQuery, userID); err != nil {
+			app.Logger.ErrorContext(ctx, "SQL execution error while clearing follow requests", "user_id", userID, "error", err)
+			return utils.DatabaseError(ctx, err, "failed to update profile")
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit profile", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to update profile")
+	}
+
+	return nil
+}
+
+func NormalizeDisplayName(displayName string) (string, error) {
+	// Compose accents the same way whichever keyboard typed them & collapse runs of spaces
+	displayName = strings.Join(strings.Fields(norm.NFC.String(displayName)), " ")
+
+	for _, r := range displayName {
+		// Control & invisible formatting characters can be used to impersonate other users
+		if unicode.IsControl(r) || unicode.Is(unicode.Cf, r) || r == utf8.RuneError {
+			return "", utils.Validation("display name contains characters that aren't allowed")
+		}
+	}
+
+	if utf8.RuneCountInString(displayName) > utils.DISPLAY_NAME_MAX_LENGTH {
+		return "", utils.Validation("display name must be at most %d characters", utils.DISPLAY_NAME_MAX_LENGTH)
+	}
+
+	return displayName, nil
+}
+
+func parseProfileLinks(linksText string) ([]string, error) {
+	links := []string{}
+
+	// One link per line, blank lines are ignored
+	for _, line := range strings.Split(linksText, "\n") {
+		link := strings.TrimSpace(line)
+
+		if link == "" {
+			continue
+		}
+
+		if len(links) == utils.PROFILE_MAX_LINKS {
+			return nil, utils.Validation("add at most %d links", utils.PROFILE_MAX_LINKS)
+		}
+
+		if len(link) > utils.PROFILE_LINK_MAX_LENGTH {
+			return nil, utils.Validation("links must be at most %d characters", utils.PROFILE_LINK_MAX_LENGTH)
+		}
+
+		// Only plain web links are allowed, anything else could run script when clicked
+		parsed, err := url.Parse(link)
+
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return nil, utils.Validation("%q is not a valid http or https link", link)
+		}
+
+		links = append(links, parsed.String())
+	}
+
+	return links, nil
+}
+
+func nullIfEmpty(value string, empties ...string) any {
+	if value == "" {
+		return nil
+	}
+
+	for _, empty := range empties {
+		if value == empty {
+			return nil
+		}
+	}
+
+	return value
+}
+
+func SaveAvatar(ctx context.Context, app *types.App, userID int, data []byte) error {
+	ctx, span := tracing.Start(ctx, "userservice.SaveAvatar")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	if len(data) > utils.AVATAR_MAX_UPLOAD_BYTES {
+		return utils.Validation("avatar must be smaller than %d MB", utils.AVATAR_MAX_UPLOAD_BYTES>>20)
+	}
+
+	// Check the real format & size before decoding so huge images can't exhaust memory
+	config, _, err := image.DecodeConfig(bytes.NewReader(data))
+
+	if err != nil {
+		return utils.Validation("avatar must be a JPEG, PNG, GIF or WebP image")
+	}
+
+	if config.Width > utils.AVATAR_MAX_DIMENSION || config.Height > utils.AVATAR_MAX_DIMENSION {
+		return utils.Validation("avatar must be at most %d pixels wide and high", utils.AVATAR_MAX_DIMENSION)
+	}
+
+	source, _, err := image.Decode(bytes.NewReader(data))
+
+	if err != nil {
+		return utils.Validation("avatar image could not be read")
+	}
+
+	// Re-encoding drops any metadata such as the location the photo was taken at
+	encoded, err := resizeAvatar(source)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to encode avatar", "user_id", userID, "error", err)
+		return utils.Internal(err, "failed to process avatar")
+	}
+
+	tx, err := app.Database.BeginTx(ctx, nil)
+
+	if err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to begin transaction for avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save avatar")
+	}
+
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, utils.DeleteAvatarQuery, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while replacing avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save avatar")
+	}
+
+	if _, err := tx.ExecContext(ctx, utils.InsertAvatarQuery, userID, encoded, http.DetectContentType(encoded)); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while saving avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save avatar")
+	}
+
+	if _, err := tx.ExecContext(ctx, utils.UpdateAvatarTimestampQuery, app.Clock.Now().UTC().Format("2006-01-02 15:04:05"), userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while saving avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save avatar")
+	}
+
+	if err := tx.Commit(); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to commit avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to save avatar")
+	}
+
+	return nil
+}
+
+func resizeAvatar(source image.Image) ([]byte, error) {
+	// Crop the largest centred square, then scale it down to the avatar size
+	bounds := source.Bounds()
+	side := min(bounds.Dx(), bounds.Dy())
+	offset := image.Pt((bounds.Dx()-side)/2, (bounds.Dy()-side)/2)
+	crop := image.Rectangle{Min: bounds.Min.Add(offset), Max: bounds.Min.Add(offset).Add(image.Pt(side, side))}
+
+	size := min(side, utils.AVATAR_SIZE)
+	avatar := image.NewRGBA(image.Rect(0, 0, size, size))
+	draw.CatmullRom.Scale(avatar, avatar.Bounds(), source, crop, draw.Src, nil)
+
+	var buffer bytes.Buffer
+
+	if err := png.Encode(&buffer, avatar); err != nil {
+		return nil, err
+	}
+
+	return buffer.Bytes(), nil
+}
+
+func RemoveAvatar(ctx context.Context, app *types.App, userID int) error {
+	ctx, span := tracing.Start(ctx, "userservice.RemoveAvatar")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_WRITE_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	if _, err := app.Database.ExecContext(ctx, utils.DeleteAvatarQuery, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while removing avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to remove avatar")
+	}
+
+	if _, err := app.Database.ExecContext(ctx, utils.UpdateAvatarTimestampQuery, nil, userID); err != nil {
+		app.Logger.ErrorContext(ctx, "SQL execution error while removing avatar", "user_id", userID, "error", err)
+		return utils.DatabaseError(ctx, err, "failed to remove avatar")
+	}
+
+	return nil
+}
+
+func GetAvatar(ctx context.Context, app *types.App, username string) ([]byte, string, time.Time, error) {
+	ctx, span := tracing.Start(ctx, "userservice.GetAvatar")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	var data []byte
+	var contentType string
+	var updatedAt []byte
+
+	if err := app.Database.QueryRowContext(ctx, utils.SelectAvatarByUsernameQuery, username).Scan(&data, &contentType, &updatedAt); err != nil {
+		if err == sql.ErrNoRows {
+			return nil, "", time.Time{}, utils.NotFound("avatar not found")
+		}
+
+		app.Logger.ErrorContext(ctx, "SQL query error while loading avatar", "username", username, "error", err)
+		return nil, "", time.Time{}, utils.DatabaseError(ctx, err, "failed to load avatar")
+	}
+
+	// Missing timestamps only lose the Last-Modified header
+	modifiedAt, _ := time.Parse("2006-01-02 15:04:05", string(updatedAt))
+
+	return data, contentType, modifiedAt, nil
+}
@@ -1,81 +1,82 @@
 package userservice
 
 import (
+	"context"
 	"crypto/rand"
 	"database/sql"
 	"errors"
-	"fmt"
-	"log"
 	"net/http"
+	"time"
 
-	"App/internal/cache"
+	"App/internal/tracing"
 	"App/internal/types"
 	"App/internal/utils"
 
 	"github.com/gin-gonic/gin"
-	"github.com/gorilla/sessions"
 	"golang.org/x/crypto/bcrypt"
 )
 
 func RegisterUserAndSaveSession(username string, password string, context *gin.Context, app *types.App) error {
+	ctx := context.Request.Context()
+
 	// Declare variable to check if username exists
 	var exists bool
 
 	// Execute SQL query & store result in exists variable
-	if err := app.Database.QueryRow(utils.UserExistsQuery, username).Scan(&exists); err != nil {
-		log.Println("Error checking if username exists:", err)
-		return fmt.Errorf("error checking username availability")
+	if err := app.Database.QueryRowContext(ctx, utils.UserExistsQuery, username).Scan(&exists); err != nil {
+		app.Logger.ErrorContext(ctx, "Error checking if username exists", "error", err)
+		return utils.DatabaseError(ctx, err, "failed to check username availability")
 	}
 
 	// If username exists return an error
 	if exists {
-		return fmt.Errorf("username already exists")
+		return utils.Conflict("username already exists")
 	}
 
 	// Hash the provided password using bcrypt package
 	passwordHash, err := bcrypt.GenerateFromPassword([]byte(password), 10)
 
 	if err != nil {
-		log.Println("Failed to generate password hash:", err)
-		return fmt.Errorf("failed to hash password")
+		app.Logger.ErrorContext(ctx, "Failed to generate password hash", "error", err)
+		return utils.Internal(err, "failed to hash password")
 	}
 
 	// Generate a random encryption salt
 	encryptionSalt := make([]byte, 16)
 
 	if _, err := rand.Read(encryptionSalt); err != nil {
-		log.Println("Failed to generate encryption salt:", err)
-		return fmt.Errorf("failed to generate encryption salt")
+		app.Logger.ErrorContext(ctx, "Failed to generate encryption salt", "error", err)
+		return utils.Internal(err, "failed to generate encryption salt")
 	}
 
 	// Execute sql query, passing the username & hashed password
-	result, err := app.Database.Exec(utils.InsertUserQuery, username, passwordHash, encryptionSalt)
+	result, err := app.Database.ExecContext(ctx, utils.InsertUserQuery, username, passwordHash, encryptionSalt)
 
 	if err != nil {
-		log.Println("Error inserting new user into database:", err)
-		return fmt.Errorf("failed to insert new user")
+		app.Logger.ErrorContext(ctx, "Error inserting new user into database", "error", err)
+		return utils.DatabaseError(ctx, err, "failed to insert new user")
 	}
 
 	// Get the last inserted ID
 	userID, err := result.LastInsertId()
 
 	if err != nil {
-		log.Println("Error getting last inserted ID:", err)
-		return fmt.Errorf("failed to get last inserted ID")
+		app.Logger.ErrorContext(ctx, "Error getting last inserted ID", "error", err)
+		return utils.DatabaseError(ctx, err, "failed to get last inserted ID")
 	}
 
 	id := int(userID)
 
 	// Cache the user key using the derived salt
-	cache.DeriveAndCacheUserKey(id, password, encryptionSalt)
+	app.KeyCache.DeriveAndCache(id, password, encryptionSalt)
 
 	// Save the user session using the session store
-	if err := SaveUserSession(context, app.SessionStore, &types.User{
+	if err := SaveUserSession(context, app, &types.User{
 		ID:       id,
 		Username: username,
 	}); err != nil {
-		log.Println("Failed to save user session:", err)
-		return fmt.Errorf("failed to save session after registration")
+		app.Logger.ErrorContext(ctx, "Failed to save user session", "error", err)
+		return utils.Internal(err, "failed to save session after registration")
 	}
 
 	// Return nil if user registration & session saving is successful
@@ -83,49 +84,96 @@ func RegisterUserAndSaveSession(username string, password string, context *gin.C
 }
 
 func VerifyUserCredentialsAndSaveSession(username, password string, context *gin.Context, app *types.App) error {
+	// Check the credentials & unlock the user's encryption key
+	id, err := UnlockUserKey(context.Request.Context(), app, username, password)
+
+	if err != nil {
+		return err
+	}
+
+	// Logging in during the grace period keeps the account
+	if err := CancelAccountDeletion(context.Request.Context(), app, id); err != nil {
+		return err
+	}
+
+	if err := SaveUserSession(context, app, &types.User{
+		ID:       id,
+		Username: username,
+	}); err != nil {
+		app.Logger.ErrorContext(context.Request.Context(), "Failed to save user session", "error", err)
+		return utils.Internal(err, "failed to save session after login")
+	}
+
+	return nil
+}
+
+func UnlockUserKey(ctx context.Context, app *types.App, username, password string) (int, error) {
+	ctx, span := tracing.Start(ctx, "userservice.UnlockUserKey")
+	defer span.End()
+
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
+
+	// Check the password before deriving anything from it
+	id, encryptionSalt, err := verifyPassword(ctx, app, username, password)
+
+	if err != nil {
+		return 0, err
+	}
+
+	// Derive the user's key so their private posts can be decrypted
+	if err := app.KeyCache.DeriveAndCache(id, password, encryptionSalt); err != nil {
+		app.Logger.ErrorContext(ctx, "Failed to derive key", "username", username, "error", err)
+		return 0, utils.Internal(err, "failed to unlock encryption key")
+	}
+
+	return id, nil
+}
+
+func verifyPassword(ctx context.Context, app *types.App, username, password string) (int, []byte, error) {
 	// Declare variables to store id & password hash from SQL query
 	var id int
 	var passwordHash []byte
 	var encryptionSalt []byte
 
 	// Run SQL query against the database & return the SQL row
-	row := app.Database.QueryRow(utils.GetUserCredentialsQuery, username)
+	row := app.Database.QueryRowContext(ctx, utils.GetUserCredentialsQuery, username)
 
 	// Scan the row data into id and passwordHash
-	if err := row.Scan(&id, &passwordHash, &encryptionSalt); err != nil {
-		log.Println("Error fetching user from database:", err)
-		return fmt.Errorf("user not found")
+	// An unknown username & a wrong password get the same answer so accounts can't be discovered
+	if err := row.Scan(&id, &passwordHash, &encryptionSalt); err == sql.ErrNoRows {
+		app.Logger.InfoContext(ctx, "Login attempted for unknown user", "username", username)
+		return 0, nil, utils.Unauthorized(utils.INVALID_CREDENTIALS_MESSAGE)
+	} else if err != nil {
+		app.Logger.ErrorContext(ctx, "Error fetching user from database", "error", err)
+		return 0, nil, utils.DatabaseError(ctx, err, "failed to fetch user credentials")
 	}
 
 	// Compare password from user with the hashed password in the database
 	if err := bcrypt.CompareHashAndPassword(passwordHash, []byte(password)); err != nil {
-		log.Println("Error comparing password:")
-		return fmt.Errorf("invalid password credentials")
+		app.Logger.InfoContext(ctx, "Password did not match", "username", username)
+		return 0, nil, utils.Unauthorized(utils.INVALID_CREDENTIALS_MESSAGE)
 	}
 
-	cache.DeriveAndCacheUserKey(id, password, encryptionSalt)
+	return id, encryptionSalt, nil
+}
 
-	if err := SaveUserSession(context, app.SessionStore, &types.User{
-		ID:       id,
-		Username: username,
-	}); err != nil {
-		log.Println("Failed to save user session:", err)
-		return fmt.Errorf("failed to save session after registration")
-	}
+func CheckUserExists(ctx context.Context, user types.User, app *types.App) bool {
+	ctx, span := tracing.Start(ctx, "userservice.CheckUserExists")
+	defer span.End()
 
-	return nil
-}
+	ctx, cancel := context.WithTimeout(ctx, utils.DB_READ_TIMEOUT_SEC*time.Second)
+	defer cancel()
 
-func CheckUserExists(user types.User, database *sql.DB) bool {
 	// Execute the query using the constant and check if the user exists
 	var exists bool
-	if err := database.QueryRow(utils.UserExistsQuery, user.Username).Scan(&exists); err != nil {
+	if err := app.Database.QueryRowContext(ctx, utils.UserExistsQuery, user.Username).Scan(&exists); err != nil {
 		// If no rows are found, we return false (normal case)
 		if errors.Is(err, sql.ErrNoRows) {
 			return false
 		}
 		// Log any other database-related errors
-		log.Printf("Database query error: %v", err)
+		app.Logger.ErrorContext(ctx, "Error checking if session user exists", "user_id", user.ID, "error", err)
 		return false
 	}
 
@@ -133,12 +181,12 @@ func CheckUserExists(user types.User, database *sql.DB) bool {
 	return exists
 }
 
-func SaveUserSession(context *gin.Context, cookieStore *sessions.CookieStore, user *types.User) error {
+func SaveUserSession(context *gin.Context, app *types.App, user *types.User) error {
 	// Retrieve session data from the request
-	session, err := cookieStore.Get(context.Request, utils.COOKIE_SESSION)
+	session, err := app.SessionStore.Get(context.Request, utils.COOKIE_SESSION)
 	if err != nil {
-		log.Printf("Failed to retrieve session data for user: %s, Error: %v", user.Username, err)
-		return fmt.Errorf("failed to retrieve session data: %w", err)
+		app.Logger.ErrorContext(context.Request.Context(), "Failed to retrieve session data", "username", user.Username, "error", err)
+		return utils.Internal(err, "failed to retrieve session data")
 	}
 
 	// Store user in the session
@@ -146,20 +194,20 @@ func SaveUserSession(context *gin.Context, cookieStore *sessions.CookieStore, us
 
 	// Save session data to ensure persistence
 	if err := session.Save(context.Request, context.Writer); err != nil {
-		log.Printf("Failed to save session data for user: %s, Error: %v", user.Username, err)
-		return fmt.Errorf("failed to save session data: %w", err)
+		app.Logger.ErrorContext(context.Request.Context(), "Failed to save session data", "username", user.Username, "error", err)
+		return utils.Internal(err, "failed to save session data")
 	}
 
 	return nil
 }
 
-func LogoutUserSession(context *gin.Context, store *sessions.CookieStore) error {
+func LogoutUserSession(context *gin.Context, app *types.App) error {
 	// Retrieve session data from the store
-	session, err := store.Get(context.Request, utils.COOKIE_SESSION)
+	session, err := app.SessionStore.Get(context.Request, utils.COOKIE_SESSION)
 
 	if err != nil {
-		log.Printf("Failed to retrieve session data: %v", err)
-		return fmt.Errorf("failed to retrieve session data")
+		app.Logger.ErrorContext(context.Request.Context(), "Failed to retrieve session data", "error", err)
+		return utils.Internal(err, "failed to retrieve session data")
 	}
 
 	// Expire the session by setting MaxAge to -1
@@ -167,8 +215,8 @@ func LogoutUserSession(context *gin.Context, store *sessions.CookieStore) error
 
 	// Save session data to ensure persistence
 	if err := session.Save(context.Request, context.Writer); err != nil {
-		log.Printf("Failed to save session data during logout: %v", err)
-		return fmt.Errorf("failed to save session data")
+		app.Logger.ErrorContext(context.Request.Context(), "Failed to save session data during logout", "error", err)
+		return utils.Internal(err, "failed to save session data")
 	}
 
 	return nil
@@ -177,21 +225,21 @@ func LogoutUserSession(context *gin.Context, store *sessions.CookieStore) error
 func ValidateAuthInputLength(username, password string) error {
 	// Validate username length
 	if !utils.IsValidInputLength(username, utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH) {
-		return fmt.Errorf("username must be between %d and %d characters", utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH)
+		return utils.Validation("username must be between %d and %d characters", utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH)
 	}
 
 	// Validate password length
 	if !utils.IsValidInputLength(password, utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH) {
-		return fmt.Errorf("password must be between %d and %d characters", utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH)
+		return utils.Validation("password must be between %d and %d characters", utils.AUTH_MIN_LENGTH, utils.AUTH_MAX_LENGTH)
 	}
 
 	// Return nil if username & password length validation passed
 	return nil
 }
 
-func HandleAuthenticationError(context *gin.Context, err error) {
+func HandleAuthenticationError(context *gin.Context, app *types.App, err error) {
 	// Log error
-	log.Println(err.Error())
+	app.Logger.InfoContext(context.Request.Context(), "Authentication failed", "error", err)
 
 	// Redirect the user to the login page
 	context.Redirect(http.StatusFound, "/login")
@@ -0,0 +1,9 @@
+package utils
+
+import "time"
+
+type SystemClock struct{}
+
+func (SystemClock) Now() time.Time {
+	return time.Now()
+}
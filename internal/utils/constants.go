@@ -12,9 +12,16 @@ const (
 )
 
 const (
-	REQUEST_LIMIT       = 60
-	POST_LIMIT_PER_PAGE = 3
-	BLOG_POST_PAGE_MAX  = 1000
+	TAG_MAX_LENGTH   = 30
+	TAG_MAX_PER_POST = 5
+	TAG_CLOUD_LIMIT  = 20
+)
+
+const (
+	REQUEST_LIMIT          = 60
+	DEFAULT_POSTS_PER_PAGE = 3
+	MAX_POSTS_PER_PAGE     = 50
+	BLOG_POST_PAGE_MAX     = 1000
 )
 
 const (
@@ -25,8 +32,33 @@ const (
 	EXPIRATION_TIME = 24
 )
 
+const (
+	TRENDING_WINDOW_DAY          = "day"
+	TRENDING_WINDOW_WEEK         = "week"
+	TRENDING_WINDOW_MONTH        = "month"
+	TRENDING_MAX_POSTS           = 500
+	TRENDING_GRAVITY             = 1.5
+	TRENDING_COMMENT_WEIGHT      = 2
+	TRENDING_HOME_PAGE_POSTS     = 5
+	DEFAULT_TRENDING_REFRESH_MIN = 10
+)
+
+const (
+	SITE_NAME         = "Posto"
+	DEFAULT_SITE_URL  = "https://postoblog.duckdns.org"
+	FEED_FORMAT_RSS   = "rss"
+	FEED_FORMAT_ATOM  = "atom"
+	FEED_MAX_ITEMS    = 20
+	RSS_CONTENT_TYPE  = "application/rss+xml; charset=utf-8"
+	ATOM_CONTENT_TYPE = "application/atom+xml; charset=utf-8"
+)
+
 const (
 	PAGE         = "page"
+	TAG          = "tag"
+	CURSOR       = "cursor"
+	WINDOW       = "window"
+	LIMIT        = "limit"
 	DEFAULT_PAGE = "1"
 	DOTS_STRING  = "..."
 )
@@ -36,16 +68,30 @@ const (
 	USERNAME = "username"
 	PASSWORD = "password"
 	USER     = "user"
+	NAME     = "name"
 )
 
 const (
-	BLOG_POST_PAGE    = "blogpost.html"
-	CREATE_POST_PAGE  = "createpost.html"
-	ERROR_PAGE        = "error.html"
-	ROOT_PAGE         = "index.html"
-	LOGIN_PAGE        = "login.html"
-	SIGNUP_PAGE       = "signup.html"
-	USER_PROFILE_PAGE = "userprofile.html"
+	BLOG_POST_PAGE       = "blogpost.html"
+	CREATE_POST_PAGE     = "createpost.html"
+	DELETE_ACCOUNT_PAGE  = "deleteaccount.html"
+	ERROR_PAGE           = "error.html"
+	EXPLORE_PAGE         = "explore.html"
+	FEED_PAGE            = "feed.html"
+	FOLLOW_LIST_PAGE     = "followlist.html"
+	FOLLOW_REQUESTS_PAGE = "followrequests.html"
+	IMPORT_PAGE          = "import.html"
+	ROOT_PAGE            = "index.html"
+	SETTINGS_PAGE        = "settings.html"
+	LOGIN_PAGE           = "login.html"
+	PROFILE_PAGE         = "profile.html"
+	REVISIONS_PAGE       = "revisions.html"
+	SAVED_PAGE           = "saved.html"
+	SHARED_POST_PAGE     = "sharedpost.html"
+	SHARE_LINKS_PAGE     = "sharelinks.html"
+	SIGNUP_PAGE          = "signup.html"
+	TAG_PAGE             = "tag.html"
+	USER_PROFILE_PAGE    = "userprofile.html"
 )
 
 const (
@@ -53,8 +99,11 @@ const (
 )
 
 const (
-	INVALID_REQUEST_MESSAGE  = "Oops! The page you're looking for doesn't exist."
-	INVALID_USERNAME_MESSAGE = "Invalid username. Please try again."
+	INVALID_REQUEST_MESSAGE     = "Oops! The page you're looking for doesn't exist."
+	INVALID_USERNAME_MESSAGE    = "Invalid username. Please try again."
+	INVALID_TAG_MESSAGE         = "Invalid tag. Tags may only contain letters, numbers and dashes."
+	INVALID_CREDENTIALS_MESSAGE = "Invalid username or password."
+	INTERNAL_ERROR_MESSAGE      = "Something went wrong on our end. Please try again later."
 )
 
 const (
@@ -63,3 +112,153 @@ const (
 	ArgonThreads = 4
 	ArgonKeyLen  = 32
 )
+
+// Prefixed to a tag before hashing it with the owner's key, so the hashes can't be mistaken for anything else the key signs
+const PRIVATE_TAG_HASH_CONTEXT = "posto-private-tag:"
+
+const (
+	IMPORT_CONTENT_MAX_LENGTH = 100000
+	IMPORT_MAX_ITEMS          = 1000
+	IMPORT_MAX_UPLOAD_BYTES   = 20 << 20
+)
+
+const (
+	ACCOUNT_DELETION_GRACE_DAYS = 14
+	ACCOUNT_PURGE_INTERVAL_MIN  = 60
+)
+
+const (
+	DISPLAY_NAME_MAX_LENGTH = 50
+	BIO_MAX_LENGTH          = 300
+	PROFILE_MAX_LINKS       = 3
+	PROFILE_LINK_MAX_LENGTH = 200
+	AVATAR_MAX_UPLOAD_BYTES = 2 << 20
+	AVATAR_MAX_DIMENSION    = 8000
+	AVATAR_SIZE             = 256
+)
+
+const (
+	MEDIA_MAX_UPLOAD_BYTES     = 10 << 20
+	MEDIA_MAX_DIMENSION        = 10000
+	MEDIA_MAX_PIXELS           = 50000000
+	MEDIA_MAX_WIDTH            = 2048
+	MEDIA_THUMBNAIL_SIZE       = 400
+	MEDIA_JPEG_QUALITY         = 85
+	MEDIA_MAX_PER_POST         = 10
+	MEDIA_ORPHAN_HOURS         = 24
+	MEDIA_CLEANUP_INTERVAL_MIN = 60
+)
+
+const (
+	MEDIA_STORE_LOCAL = "local"
+	MEDIA_STORE_S3    = "s3"
+	DEFAULT_MEDIA_DIR = "data/media"
+	DEFAULT_S3_REGION = "us-east-1"
+)
+
+const (
+	POST_MAX_REVISIONS = 50
+	DIFF_CONTEXT_LINES = 3
+)
+
+const (
+	DIFF_EQUAL  = "equal"
+	DIFF_INSERT = "insert"
+	DIFF_DELETE = "delete"
+	DIFF_SKIP   = "skip"
+)
+
+const (
+	VISIBILITY_PUBLIC    = "public"
+	VISIBILITY_UNLISTED  = "unlisted"
+	VISIBILITY_FOLLOWERS = "followers"
+	VISIBILITY_PRIVATE   = "private"
+)
+
+const (
+	SHARE_LINKS_MAX_PER_POST        = 20
+	SHARE_LINK_CLEANUP_INTERVAL_MIN = 60
+)
+
+const (
+	FOLLOW_LIST_PAGE_SIZE = 20
+	FOLLOWERS_LIST        = "followers"
+	FOLLOWING_LIST        = "following"
+	FOLLOW_STATUS_NONE    = "none"
+	FOLLOW_STATUS_PENDING = "requested"
+	FOLLOW_STATUS_ACTIVE  = "following"
+)
+
+const (
+	SAVED_PAGE_SIZE                = 10
+	BOOKMARK_COLLECTIONS_MAX       = 20
+	BOOKMARK_COLLECTION_MAX_LENGTH = 50
+	COLLECTION                     = "collection"
+)
+
+const (
+	LOG_OUTPUT_STDOUT        = "stdout"
+	LOG_OUTPUT_FILE          = "file"
+	DEFAULT_LOG_LEVEL        = "info"
+	DEFAULT_LOG_FILE         = "/home/ec2-user/logs/posto.log"
+	DEFAULT_LOG_MAX_SIZE_MB  = 100
+	DEFAULT_LOG_MAX_BACKUPS  = 5
+	DEFAULT_LOG_MAX_AGE_DAYS = 30
+	REDACTED                 = "[redacted]"
+)
+
+const (
+	REQUEST_ID_HEADER     = "X-Request-ID"
+	REQUEST_ID_ATTR       = "request_id"
+	REQUEST_ID_MAX_LENGTH = 64
+)
+
+const (
+	DEFAULT_METRICS_ADDR = "127.0.0.1:9090"
+	METRICS_DISABLED     = "off"
+	CRYPTO_POST_ENCRYPT  = "post_encrypt"
+	CRYPTO_POST_DECRYPT  = "post_decrypt"
+	CRYPTO_MEDIA_ENCRYPT = "media_encrypt"
+	CRYPTO_MEDIA_DECRYPT = "media_decrypt"
+	CRYPTO_KEY_DERIVE    = "key_derive"
+)
+
+const (
+	TRACE_EXPORTER_OFF           = "off"
+	TRACE_EXPORTER_STDOUT        = "stdout"
+	TRACE_EXPORTER_OTLP          = "otlp"
+	DEFAULT_TRACE_SAMPLE_PERCENT = 100
+	TRACE_SERVICE_NAME           = "posto"
+	TRACE_ID_ATTR                = "trace_id"
+)
+
+const (
+	DB_READ_TIMEOUT_SEC  = 5
+	DB_WRITE_TIMEOUT_SEC = 10
+	DB_BATCH_TIMEOUT_SEC = 300
+)
+
+const (
+	SERVER_READ_HEADER_TIMEOUT_SEC = 10
+	SERVER_READ_TIMEOUT_SEC        = 60
+	SERVER_WRITE_TIMEOUT_SEC       = 120
+	SERVER_IDLE_TIMEOUT_SEC        = 120
+	SHUTDOWN_TIMEOUT_SEC           = 30
+	READINESS_TIMEOUT_SEC          = 2
+)
+
+const (
+	DEFAULT_HTTP_ADDR        = ":8080"
+	DEFAULT_HTTPS_ADDR       = ":443"
+	TLS_MODE_OFF             = "off"
+	TLS_MODE_FILES           = "files"
+	TLS_MODE_ACME            = "acme"
+	TLS_RELOAD_INTERVAL_SEC  = 60
+	DEFAULT_HSTS_MAX_AGE_SEC = 31536000
+	DEFAULT_ACME_CACHE_DIR   = "/home/ec2-user/.posto/acme"
+)
+
+const (
+	CSP_REPORT_PATH      = "/csp-report"
+	CSP_REPORT_MAX_BYTES = 16 << 10
+)
@@ -0,0 +1,113 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var ErrTimeout = errors.New("the server took too long to respond, please try again")
+var ErrCanceled = errors.New("the request was canceled")
+
+// Kinds of failure the services report, each answered with its own status code
+var (
+	ErrNotFound     = errors.New("not found")
+	ErrUnauthorized = errors.New("unauthorized")
+	ErrForbidden    = errors.New("forbidden")
+	ErrConflict     = errors.New("conflict")
+	ErrValidation   = errors.New("invalid input")
+	ErrTooLarge     = errors.New("too large")
+	ErrInternal     = errors.New("internal error")
+)
+
+type Error struct {
+	Kind    error  // One of the sentinels above
+	Message string // Shown to the user, except for internal errors
+	Err     error  // Underlying cause, only ever logged
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return e.Message + ": " + e.Err.Error()
+	}
+
+	return e.Message
+}
+
+func (e *Error) Unwrap() []error {
+	// errors.Is matches both the kind & whatever caused it
+	return []error{e.Kind, e.Err}
+}
+
+func NotFound(format string, args ...any) error {
+	return &Error{Kind: ErrNotFound, Message: fmt.Sprintf(format, args...)}
+}
+
+func Unauthorized(format string, args ...any) error {
+	return &Error{Kind: ErrUnauthorized, Message: fmt.Sprintf(format, args...)}
+}
+
+func Forbidden(format string, args ...any) error {
+	return &Error{Kind: ErrForbidden, Message: fmt.Sprintf(format, args...)}
+}
+
+func Conflict(format string, args ...any) error {
+	return &Error{Kind: ErrConflict, Message: fmt.Sprintf(format, args...)}
+}
+
+func Validation(format string, args ...any) error {
+	return &Error{Kind: ErrValidation, Message: fmt.Sprintf(format, args...)}
+}
+
+func TooLarge(format string, args ...any) error {
+	return &Error{Kind: ErrTooLarge, Message: fmt.Sprintf(format, args...)}
+}
+
+func Internal(err error, format string, args ...any) error {
+	return &Error{Kind: ErrInternal, Message: fmt.Sprintf(format, args...), Err: err}
+}
+
+func DatabaseError(ctx context.Context, err error, format string, args ...any) error {
+	// Operations cut short by their deadline or a disconnected client aren't database failures
+	switch {
+	case errors.Is(err, ErrTimeout) || errors.Is(ctx.Err(), context.DeadlineExceeded) || errors.Is(err, context.DeadlineExceeded):
+		return ErrTimeout
+	case errors.Is(err, ErrCanceled) || errors.Is(ctx.Err(), context.Canceled) || errors.Is(err, context.Canceled):
+		return ErrCanceled
+	}
+
+	return Internal(err, "database error: "+format, args...)
+}
+
+func ErrorStatus(err error) (int, string) {
+	var appErr *Error
+
+	switch {
+	case errors.Is(err, ErrTimeout) || errors.Is(err, context.DeadlineExceeded):
+		// The database didn't answer within the operation's deadline
+		return http.StatusGatewayTimeout, ErrTimeout.Error()
+	case errors.Is(err, ErrCanceled) || errors.Is(err, context.Canceled):
+		return http.StatusServiceUnavailable, ErrCanceled.Error()
+	case !errors.As(err, &appErr):
+		// Anything untyped is a bug or an outage, and its wording isn't meant for users
+		return http.StatusInternalServerError, INTERNAL_ERROR_MESSAGE
+	}
+
+	switch appErr.Kind {
+	case ErrNotFound:
+		return http.StatusNotFound, appErr.Message
+	case ErrUnauthorized:
+		return http.StatusUnauthorized, appErr.Message
+	case ErrForbidden:
+		return http.StatusForbidden, appErr.Message
+	case ErrConflict:
+		return http.StatusConflict, appErr.Message
+	case ErrValidation:
+		return http.StatusBadRequest, appErr.Message
+	case ErrTooLarge:
+		return http.StatusRequestEntityTooLarge, appErr.Message
+	}
+
+	return http.StatusInternalServerError, INTERNAL_ERROR_MESSAGE
+}
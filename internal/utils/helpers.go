@@ -2,7 +2,15 @@ package utils
 
 import (
 	"App/internal/types"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
 	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,20 +21,81 @@ func IsValidInputLength(inputString string, min, max int) bool {
 }
 
 func CapitalizeFirstLetter(s string) string {
+	// Decode the first character so multi-byte letters are uppercased whole
+	first, size := utf8.DecodeRuneInString(s)
+
 	// Check if string is invaild
-	if len(s) == 0 {
+	if first == utf8.RuneError {
 		return s
 	}
 
 	// Concat the first uppercased letter with rest of string & return
-	return strings.ToUpper(string(s[0])) + s[1:]
+	return string(unicode.ToUpper(first)) + s[size:]
+}
+
+func DisplayName(displayName, username string) string {
+	// Fall back to the username for users who haven't set a display name
+	if displayName != "" {
+		return displayName
+	}
+
+	return CapitalizeFirstLetter(username)
+}
+
+func AvatarURL(username string, updatedAt []byte) string {
+	// Users without an avatar get no image at all
+	version, err := time.Parse("2006-01-02 15:04:05", string(updatedAt))
+
+	if err != nil {
+		return ""
+	}
+
+	// The version busts browser caches whenever the avatar changes
+	return fmt.Sprintf("/avatar/%s?v=%d", strings.ToLower(username), version.Unix())
+}
+
+func VisibilityLabel(visibility string) string {
+	// Followers-only reads better than the stored value in the UI
+	if visibility == VISIBILITY_FOLLOWERS {
+		return "Followers only"
+	}
+
+	return CapitalizeFirstLetter(visibility)
 }
 
 func SendErrorResponse(context *gin.Context, statusCode int, errorMessage string) {
+	// The request logger picks the message up & logs it with the request ID
+	context.Error(errors.New(errorMessage))
+
+	writeError(context, statusCode, errorMessage)
+}
+
+func SendServiceError(context *gin.Context, err error) {
+	statusCode, message := ErrorStatus(err)
+
+	// The full error, including any underlying cause, only ever goes to the log through the request logger
+	context.Error(err)
+
+	// The client went away, so there is nobody left to render a page for
+	if statusCode == http.StatusServiceUnavailable {
+		context.AbortWithStatus(statusCode)
+		return
+	}
+
+	writeError(context, statusCode, message)
+}
+
+func writeError(context *gin.Context, statusCode int, message string) {
+	// Scripts & API clients get the message as JSON, browsers get the error page
+	if WantsJSON(context) {
+		context.JSON(statusCode, gin.H{"error": message})
+		return
+	}
+
 	// Render error page with passed in status code & error message
 	context.HTML(statusCode, ERROR_PAGE, types.ErrorPageData{
 		StatusCode:   statusCode,
-		ErrorMessage: errorMessage,
+		ErrorMessage: message,
 	})
 }
 
@@ -37,3 +106,44 @@ func TruncateChars(s string, maxRunes int) string {
 	}
 	return string(runes[:maxRunes]) + "…"
 }
+
+var OFFERED_FORMATS = []string{gin.MIMEHTML, gin.MIMEJSON} // Browsers & clients without an Accept header get HTML, on success & on error alike
+
+func WantsJSON(context *gin.Context) bool {
+	// Same order as the pages that negotiate their response
+	return context.NegotiateFormat(OFFERED_FORMATS...) == gin.MIMEJSON
+}
+
+func ComputeETag(body []byte) string {
+	// A strong validator derived from the exact response bytes
+	sum := sha256.Sum256(body)
+	return `"` + hex.EncodeToString(sum[:16]) + `"`
+}
+
+func IsNotModified(context *gin.Context, etag string, lastModified time.Time) bool {
+	// If-None-Match takes precedence over If-Modified-Since when both are sent
+	if ifNoneMatch := context.GetHeader("If-None-Match"); ifNoneMatch != "" {
+		for _, candidate := range strings.Split(ifNoneMatch, ",") {
+			candidate = strings.TrimPrefix(strings.TrimSpace(candidate), "W/")
+
+			if candidate == "*" || candidate == etag {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if lastModified.IsZero() {
+		return false
+	}
+
+	ifModifiedSince, err := http.ParseTime(context.GetHeader("If-Modified-Since"))
+
+	if err != nil {
+		return false
+	}
+
+	// HTTP dates only have second precision
+	return !lastModified.Truncate(time.Second).After(ifModifiedSince)
+}
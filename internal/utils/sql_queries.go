@@ -13,11 +13,13 @@ const (
 )
 
 const (
-	UpdatePostQuery = "UPDATE Posts SET Title = ?, Content = ?, IsPublic = ? WHERE ID = ? AND UserID = ?"
+	UpdatePostQuery = "UPDATE Posts SET Title = ?, Content = ?, Visibility = ?, EncryptedTags = ? WHERE ID = ? AND UserID = ?"
 )
 
 const (
-	InsertPostQuery = "INSERT INTO Posts (Title, Content, UserID, IsPublic) VALUES (?, ?, ?, ?)"
+	InsertPostQuery = "INSERT INTO Posts (Title, Content, UserID, Visibility, EncryptedTags) VALUES (?, ?, ?, ?, ?)"
+
+	InsertImportedPostQuery = "INSERT INTO Posts (Title, Content, UserID, Visibility, EncryptedTags, CreatedAt) VALUES (?, ?, ?, ?, ?, ?)"
 )
 
 const (
@@ -25,52 +27,148 @@ const (
 )
 
 const (
+	// Private accounts only list their posts to the owner & approved followers
 	SelectPostsByUsername = `
-		SELECT ID, Title, Content, CreatedAt, IsPublic, Count(*) OVER() AS total_count
+		SELECT ID, Title, Content, CreatedAt, Visibility, EncryptedTags, Count(*) OVER() AS total_count
+		FROM Posts
+		WHERE UserID = (SELECT ID FROM Users WHERE Username = ?)
+		AND (Visibility = 'public' OR UserID = ? OR (Visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM User_Follows WHERE follower_id = ? AND following_id = Posts.UserID
+		)))
+		AND (UserID = ? OR EXISTS (SELECT 1 FROM Users WHERE ID = Posts.UserID AND IsPrivate = FALSE) OR EXISTS (
+			SELECT 1 FROM User_Follows WHERE follower_id = ? AND following_id = Posts.UserID
+		))
+		AND NOT EXISTS (
+			SELECT 1 FROM User_Blocks b
+			WHERE (b.blocker_id = ? AND b.blocked_id = Posts.UserID) OR (b.blocker_id = Posts.UserID AND b.blocked_id = ?)
+		)
+		AND (? = '' OR ID IN (SELECT PostID FROM PostTags WHERE Tag = ?))
+		ORDER BY CreatedAt DESC, ID DESC
+		LIMIT ? OFFSET ?`
+
+	SelectPostsByUsernameAfterQuery = `
+		SELECT ID, Title, Content, CreatedAt, Visibility, EncryptedTags
 		FROM Posts
 		WHERE UserID = (SELECT ID FROM Users WHERE Username = ?)
-		AND (IsPublic = 1 OR UserID = ?)
-		ORDER BY CreatedAt DESC
+		AND (Visibility = 'public' OR UserID = ? OR (Visibility = 'followers' AND EXISTS (
+			SELECT 1 FROM User_Follows WHERE follower_id = ? AND following_id = Posts.UserID
+		)))
+		AND (UserID = ? OR EXISTS (SELECT 1 FROM Users WHERE ID = Posts.UserID AND IsPrivate = FALSE) OR EXISTS (
+			SELECT 1 FROM User_Follows WHERE follower_id = ? AND following_id = Posts.UserID
+		))
+		AND NOT EXISTS (
+			SELECT 1 FROM User_Blocks b
+			WHERE (b.blocker_id = ? AND b.blocked_id = Posts.UserID) OR (b.blocker_id = Posts.UserID AND b.blocked_id = ?)
+		)
+		AND (? = '' OR ID IN (SELECT PostID FROM PostTags WHERE Tag = ?))
+		AND (CreatedAt < ? OR (CreatedAt = ? AND ID < ?))
+		ORDER BY CreatedAt DESC, ID DESC
+		LIMIT ?`
+
+	// Private posts are matched on the keyed hashes of their tags, public ones on the tags themselves
+	SelectOwnPostsForTagQuery = `
+		SELECT ID, Title, Content, CreatedAt, Visibility, EncryptedTags, Count(*) OVER() AS total_count
+		FROM Posts
+		WHERE UserID = ?
+		AND (ID IN (SELECT PostID FROM PostTags WHERE Tag = ?) OR ID IN (SELECT PostID FROM PrivatePostTags WHERE TagHash = ?))
+		ORDER BY CreatedAt DESC, ID DESC
 		LIMIT ? OFFSET ?`
 
+	SelectOwnPostsForTagAfterQuery = `
+		SELECT ID, Title, Content, CreatedAt, Visibility, EncryptedTags
+		FROM Posts
+		WHERE UserID = ?
+		AND (ID IN (SELECT PostID FROM PostTags WHERE Tag = ?) OR ID IN (SELECT PostID FROM PrivatePostTags WHERE TagHash = ?))
+		AND (CreatedAt < ? OR (CreatedAt = ? AND ID < ?))
+		ORDER BY CreatedAt DESC, ID DESC
+		LIMIT ?`
+
 	SelectPostDetailsQuery = `
         SELECT 
             p.ID, p.Title, p.Content, p.CreatedAt, 
-            p.IsPublic, p.UserID, u.Username
+            p.Visibility, p.EncryptedTags, p.UserID, u.Username,
+            COALESCE(u.DisplayName, ''), u.AvatarUpdatedAt
         FROM Posts p
         JOIN Users u ON p.UserID = u.ID
-        WHERE p.ID = ? AND (p.IsPublic = 1 OR p.UserID = ?)
+        WHERE p.ID = ? AND (p.Visibility IN ('public', 'unlisted') OR p.UserID = ? OR (p.Visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        )))
+        AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        ))
+        AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+        )
     `
 )
 
 const (
 	SelectEditPostQuery = `
-        SELECT Title, Content, IsPublic
+        SELECT Title, Content, Visibility, EncryptedTags
         FROM Posts
         WHERE ID = ? AND UserID = ?
     `
 )
 
 const (
-	InsertCommentQuery         = `INSERT INTO Comments (PostID, UserID, Comment) VALUES (?, ?, ?)`
+	// Nothing is inserted unless the commenter may read the post & neither side has blocked the other
+	InsertCommentQuery = `
+        INSERT INTO Comments (PostID, UserID, Comment)
+        SELECT p.ID, ?, ?
+        FROM Posts p
+        JOIN Users u ON u.ID = p.UserID
+        WHERE p.ID = ? AND (p.Visibility IN ('public', 'unlisted') OR p.UserID = ? OR (p.Visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        )))
+        AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        ))
+        AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+        )`
+
 	SelectCommentsForPostQuery = `
 	SELECT 
     c.ID,
     c.Comment, 
     c.CreatedAt, 
-    u.Username 
+    u.Username,
+    COALESCE(u.DisplayName, ''),
+    u.AvatarUpdatedAt
 FROM 
     Comments c
 JOIN 
     Users u ON c.UserID = u.ID
 WHERE 
     c.PostID = ?
+    AND NOT EXISTS (
+        SELECT 1 FROM User_Blocks b
+        WHERE (b.blocker_id = ? AND b.blocked_id = c.UserID) OR (b.blocker_id = c.UserID AND b.blocked_id = ?)
+    )
 ORDER BY 
     c.CreatedAt ASC`
 )
 
 const (
-	InsertLikeQuery     = `INSERT INTO Likes (UserID, PostID) VALUES (?, ?)`
+	// Likes are refused the same way as comments on posts the user can't read
+	InsertLikeQuery = `
+        INSERT INTO Likes (UserID, PostID)
+        SELECT ?, p.ID
+        FROM Posts p
+        JOIN Users u ON u.ID = p.UserID
+        WHERE p.ID = ? AND (p.Visibility IN ('public', 'unlisted') OR p.UserID = ? OR (p.Visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        )))
+        AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        ))
+        AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+        )`
+
 	DeleteLikeQuery     = `DELETE FROM Likes WHERE UserID = ? AND PostID = ?`
 	CountLikesQuery     = `SELECT COUNT(*) FROM Likes WHERE PostID = ?`
 	CheckUserLikedQuery = `
@@ -105,13 +203,557 @@ const SelectHomeFeedPostsQuery = `
         Posts.Title, 
         Posts.Content, 
         Posts.CreatedAt, 
-        Posts.IsPublic, 
+        Posts.Visibility,
         Users.Username AS AuthorUsername,
+        COALESCE(Users.DisplayName, '') AS AuthorDisplayName,
 		Count(*) OVER() AS total_count
     FROM Posts
     JOIN User_Follows ON Posts.UserID = User_Follows.following_id
     JOIN Users ON Users.ID = Posts.UserID
     WHERE User_Follows.follower_id = ? 
-      AND Posts.IsPublic = 1
-    ORDER BY Posts.CreatedAt DESC
+      AND Posts.Visibility IN ('public', 'followers')
+      AND NOT EXISTS (
+          SELECT 1 FROM User_Mutes m
+          WHERE m.muter_id = User_Follows.follower_id AND m.muted_id = Posts.UserID
+      )
+      AND (? = '' OR Posts.ID IN (SELECT PostID FROM PostTags WHERE Tag = ?))
+    ORDER BY Posts.CreatedAt DESC, Posts.ID DESC
     LIMIT ? OFFSET ?`
+
+const SelectHomeFeedPostsAfterQuery = `
+    SELECT 
+        Posts.ID, 
+        Posts.Title, 
+        Posts.Content, 
+        Posts.CreatedAt, 
+        Posts.Visibility,
+        Users.Username AS AuthorUsername,
+        COALESCE(Users.DisplayName, '') AS AuthorDisplayName
+    FROM Posts
+    JOIN User_Follows ON Posts.UserID = User_Follows.following_id
+    JOIN Users ON Users.ID = Posts.UserID
+    WHERE User_Follows.follower_id = ? 
+      AND Posts.Visibility IN ('public', 'followers')
+      AND NOT EXISTS (
+          SELECT 1 FROM User_Mutes m
+          WHERE m.muter_id = User_Follows.follower_id AND m.muted_id = Posts.UserID
+      )
+      AND (? = '' OR Posts.ID IN (SELECT PostID FROM PostTags WHERE Tag = ?))
+      AND (Posts.CreatedAt < ? OR (Posts.CreatedAt = ? AND Posts.ID < ?))
+    ORDER BY Posts.CreatedAt DESC, Posts.ID DESC
+    LIMIT ?`
+
+const (
+	CheckPostOwnerQuery        = `SELECT EXISTS(SELECT 1 FROM Posts WHERE ID = ? AND UserID = ?)`
+	DeletePostTagsQuery        = `DELETE FROM PostTags WHERE PostID = ?`
+	InsertPostTagQuery         = `INSERT INTO PostTags (PostID, Tag) VALUES (?, ?)`
+	DeletePrivatePostTagsQuery = `DELETE FROM PrivatePostTags WHERE PostID = ?`
+	InsertPrivatePostTagQuery  = `INSERT INTO PrivatePostTags (PostID, TagHash) VALUES (?, ?)`
+
+	// Private posts saved before their tags were hashed, indexed the next time the owner filters by tag
+	SelectUnindexedPrivatePostsQuery = `
+        SELECT ID, EncryptedTags
+        FROM Posts
+        WHERE UserID = ? AND Visibility = 'private' AND EncryptedTags IS NOT NULL AND EncryptedTags <> ''
+        AND NOT EXISTS (SELECT 1 FROM PrivatePostTags t WHERE t.PostID = Posts.ID)`
+
+	// The IN clause placeholders are filled in at runtime for each batch of post IDs
+	SelectTagsForPostsQuery = `
+        SELECT PostID, Tag
+        FROM PostTags
+        WHERE PostID IN (%s)
+        ORDER BY Tag ASC`
+
+	SelectTagCloudByUsernameQuery = `
+        SELECT pt.Tag, COUNT(*) AS tag_count
+        FROM PostTags pt
+        JOIN Posts p ON p.ID = pt.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE u.Username = ? AND p.Visibility = 'public'
+        GROUP BY pt.Tag
+        ORDER BY tag_count DESC, pt.Tag ASC
+        LIMIT ?`
+
+	SelectLatestPublicPostsQuery = `
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.CreatedAt,
+            p.Visibility,
+            u.Username AS AuthorUsername,
+            COALESCE(u.DisplayName, '') AS AuthorDisplayName
+        FROM Posts p
+        JOIN Users u ON u.ID = p.UserID
+        WHERE p.Visibility = 'public' AND u.IsPrivate = FALSE
+        ORDER BY p.CreatedAt DESC, p.ID DESC
+        LIMIT ?`
+
+	SelectPostsByTagQuery = `
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.CreatedAt,
+            p.Visibility,
+            u.Username AS AuthorUsername,
+            COALESCE(u.DisplayName, '') AS AuthorDisplayName,
+            Count(*) OVER() AS total_count
+        FROM PostTags pt
+        JOIN Posts p ON p.ID = pt.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE pt.Tag = ? AND p.Visibility = 'public'
+          AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+              SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+          ))
+          AND NOT EXISTS (
+              SELECT 1 FROM User_Blocks b
+              WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+          )
+        ORDER BY p.CreatedAt DESC, p.ID DESC
+        LIMIT ? OFFSET ?`
+
+	SelectPostsByTagAfterQuery = `
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.CreatedAt,
+            p.Visibility,
+            u.Username AS AuthorUsername,
+            COALESCE(u.DisplayName, '') AS AuthorDisplayName
+        FROM PostTags pt
+        JOIN Posts p ON p.ID = pt.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE pt.Tag = ? AND p.Visibility = 'public'
+          AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+              SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+          ))
+          AND NOT EXISTS (
+              SELECT 1 FROM User_Blocks b
+              WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+          )
+          AND (p.CreatedAt < ? OR (p.CreatedAt = ? AND p.ID < ?))
+        ORDER BY p.CreatedAt DESC, p.ID DESC
+        LIMIT ?`
+)
+
+const (
+	SelectTrendingCandidatesQuery = `
+        SELECT
+            p.ID,
+            p.CreatedAt,
+            (SELECT COUNT(*) FROM Likes l WHERE l.PostID = p.ID) AS likes_count,
+            (SELECT COUNT(*) FROM Comments c WHERE c.PostID = p.ID) AS comments_count
+        FROM Posts p
+        WHERE p.Visibility = 'public' AND p.CreatedAt >= ?
+          AND EXISTS (SELECT 1 FROM Users u WHERE u.ID = p.UserID AND u.IsPrivate = FALSE)`
+
+	DeleteTrendingPostsQuery = `DELETE FROM TrendingPosts`
+
+	InsertTrendingPostQuery = `
+        INSERT INTO TrendingPosts (PostID, Score, LikesCount, CommentsCount, PostCreatedAt)
+        VALUES (?, ?, ?, ?, ?)`
+
+	// Visibility is re-checked here in case a post stopped being public or its author went private since the last refresh
+	SelectTrendingPostsQuery = `
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.CreatedAt,
+            p.Visibility,
+            u.Username AS AuthorUsername,
+            COALESCE(u.DisplayName, '') AS AuthorDisplayName,
+            t.LikesCount,
+            t.CommentsCount,
+            Count(*) OVER() AS total_count
+        FROM TrendingPosts t
+        JOIN Posts p ON p.ID = t.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE p.Visibility = 'public'
+          AND t.PostCreatedAt >= ?
+          AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+              SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+          ))
+          AND NOT EXISTS (
+              SELECT 1 FROM User_Blocks b
+              WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+          )
+          AND (? = '' OR p.ID IN (SELECT PostID FROM PostTags WHERE Tag = ?))
+        ORDER BY t.Score DESC, p.ID DESC
+        LIMIT ? OFFSET ?`
+)
+
+const (
+	SelectUserProfileForExportQuery = `
+        SELECT ID, Username, CreatedAt FROM Users WHERE Username = ?`
+
+	SelectPostsForExportQuery = `
+        SELECT ID, Title, Content, CreatedAt, Visibility, EncryptedTags
+        FROM Posts
+        WHERE UserID = ?
+        ORDER BY CreatedAt ASC, ID ASC`
+
+	SelectPublicTagsByUserQuery = `
+        SELECT pt.PostID, pt.Tag
+        FROM PostTags pt
+        JOIN Posts p ON p.ID = pt.PostID
+        WHERE p.UserID = ?
+        ORDER BY pt.PostID, pt.Tag`
+
+	SelectCommentsByUserQuery = `
+        SELECT c.ID, c.PostID, c.Comment, c.CreatedAt
+        FROM Comments c
+        WHERE c.UserID = ?
+        ORDER BY c.CreatedAt ASC, c.ID ASC`
+
+	SelectLikesByUserQuery = `
+        SELECT l.PostID, u.Username, l.CreatedAt
+        FROM Likes l
+        JOIN Posts p ON p.ID = l.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE l.UserID = ?
+        ORDER BY l.CreatedAt ASC`
+
+	SelectFollowersForExportQuery = `
+        SELECT u.Username, f.created_at
+        FROM User_Follows f
+        JOIN Users u ON u.ID = f.follower_id
+        WHERE f.following_id = ?
+        ORDER BY f.created_at ASC`
+
+	SelectFollowingForExportQuery = `
+        SELECT u.Username, f.created_at
+        FROM User_Follows f
+        JOIN Users u ON u.ID = f.following_id
+        WHERE f.follower_id = ?
+        ORDER BY f.created_at ASC`
+)
+
+const (
+	RequestAccountDeletionQuery = `UPDATE Users SET DeletionRequestedAt = ? WHERE ID = ?`
+
+	CancelAccountDeletionQuery = `
+        UPDATE Users SET DeletionRequestedAt = NULL
+        WHERE ID = ? AND DeletionRequestedAt IS NOT NULL`
+
+	SelectAccountsDueForDeletionQuery = `
+        SELECT ID, Username FROM Users
+        WHERE DeletionRequestedAt IS NOT NULL AND DeletionRequestedAt <= ?`
+
+	// Run in order inside one transaction so nothing is left pointing at the account
+	DeleteLikesByUserQuery          = `DELETE FROM Likes WHERE UserID = ?`
+	DeleteLikesOnUserPostsQuery     = `DELETE FROM Likes WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteCommentsByUserQuery       = `DELETE FROM Comments WHERE UserID = ?`
+	DeleteCommentsOnUserPostsQuery  = `DELETE FROM Comments WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteTagsOnUserPostsQuery      = `DELETE FROM PostTags WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeletePrivateTagsOfUserQuery    = `DELETE FROM PrivatePostTags WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteTrendingUserPostsQuery    = `DELETE FROM TrendingPosts WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteRevisionsOfUserPostsQuery = `DELETE FROM PostRevisions WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteShareLinksOfUserQuery     = `DELETE FROM PostShareLinks WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteBookmarksOnUserPostsQuery = `DELETE FROM Bookmarks WHERE PostID IN (SELECT ID FROM Posts WHERE UserID = ?)`
+	DeleteBookmarksByUserQuery      = `DELETE FROM Bookmarks WHERE UserID = ?`
+	DeleteBookmarkCollectionsQuery  = `DELETE FROM BookmarkCollections WHERE UserID = ?`
+	DeletePostsByUserQuery          = `DELETE FROM Posts WHERE UserID = ?`
+	DeleteFollowsOfUserQuery        = `DELETE FROM User_Follows WHERE follower_id = ? OR following_id = ?`
+	DeleteFollowRequestsOfUserQuery = `DELETE FROM Follow_Requests WHERE requester_id = ? OR target_id = ?`
+	DeleteBlocksOfUserQuery         = `DELETE FROM User_Blocks WHERE blocker_id = ? OR blocked_id = ?`
+	DeleteMutesOfUserQuery          = `DELETE FROM User_Mutes WHERE muter_id = ? OR muted_id = ?`
+	DeleteAvatarOfUserQuery         = `DELETE FROM UserAvatars WHERE UserID = ?`
+	DeleteMediaOfUserQuery          = `DELETE FROM Media WHERE UserID = ?`
+	DeleteUserQuery                 = `DELETE FROM Users WHERE ID = ?`
+)
+
+const (
+	SelectUserProfileQuery = `
+        SELECT
+            Username, COALESCE(DisplayName, ''), COALESCE(Bio, ''), COALESCE(Links, ''), AvatarUpdatedAt, IsPrivate,
+            (SELECT COUNT(*) FROM User_Follows f WHERE f.following_id = Users.ID) AS followers_count,
+            (SELECT COUNT(*) FROM User_Follows f WHERE f.follower_id = Users.ID) AS following_count
+        FROM Users
+        WHERE Username = ?`
+
+	UpdateUserProfileQuery = `UPDATE Users SET DisplayName = ?, Bio = ?, Links = ?, IsPrivate = ? WHERE ID = ?`
+
+	SelectAvatarByUsernameQuery = `
+        SELECT a.Image, a.ContentType, u.AvatarUpdatedAt
+        FROM UserAvatars a
+        JOIN Users u ON u.ID = a.UserID
+        WHERE u.Username = ?`
+
+	DeleteAvatarQuery          = `DELETE FROM UserAvatars WHERE UserID = ?`
+	InsertAvatarQuery          = `INSERT INTO UserAvatars (UserID, Image, ContentType) VALUES (?, ?, ?)`
+	UpdateAvatarTimestampQuery = `UPDATE Users SET AvatarUpdatedAt = ? WHERE ID = ?`
+)
+
+const (
+	mediaColumns = `ID, Token, UserID, COALESCE(PostID, 0), ContentType, Width, Height, Size, IsEncrypted`
+
+	InsertMediaQuery = `
+        INSERT INTO Media (Token, UserID, ContentType, Width, Height, Size, IsEncrypted, CreatedAt)
+        VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+
+	SelectMediaByTokenQuery = `SELECT ` + mediaColumns + ` FROM Media WHERE Token = ?`
+	SelectMediaByPostQuery  = `SELECT ` + mediaColumns + ` FROM Media WHERE PostID = ? ORDER BY ID ASC`
+	SelectMediaByUserQuery  = `SELECT ` + mediaColumns + ` FROM Media WHERE UserID = ?`
+
+	SelectUnattachedMediaQuery = `
+        SELECT ` + mediaColumns + `
+        FROM Media
+        WHERE PostID IS NULL AND CreatedAt < ?`
+
+	// Media on a post is only served to viewers who may read the post itself
+	SelectPostVisibilityForViewerQuery = `
+        SELECT p.Visibility
+        FROM Posts p
+        JOIN Users u ON u.ID = p.UserID
+        WHERE p.ID = ? AND (p.Visibility IN ('public', 'unlisted') OR p.UserID = ? OR (p.Visibility = 'followers' AND EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        )))
+        AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+            SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+        ))
+        AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+        )`
+
+	AttachMediaQuery = `UPDATE Media SET PostID = ?, IsEncrypted = ? WHERE ID = ? AND UserID = ?`
+	DeleteMediaQuery = `DELETE FROM Media WHERE ID = ?`
+)
+
+const (
+	CountPostRevisionsQuery = `SELECT COUNT(*) FROM PostRevisions WHERE PostID = ?`
+
+	// Copies the post as currently stored, so private revisions stay encrypted.
+	// Other tags are gathered from PostTags, a NULL date keeps the post's own date.
+	InsertPostRevisionQuery = `
+        INSERT INTO PostRevisions (PostID, Title, Content, Visibility, Tags, CreatedAt)
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.Visibility,
+            CASE WHEN p.Visibility = 'private'
+                THEN p.EncryptedTags
+                ELSE (SELECT GROUP_CONCAT(pt.Tag) FROM PostTags pt WHERE pt.PostID = p.ID)
+            END,
+            COALESCE(?, p.CreatedAt)
+        FROM Posts p
+        WHERE p.ID = ?`
+
+	// The derived table lets MySQL use LIMIT inside the subquery
+	PrunePostRevisionsQuery = `
+        DELETE FROM PostRevisions
+        WHERE PostID = ? AND ID NOT IN (
+            SELECT ID FROM (
+                SELECT ID FROM PostRevisions WHERE PostID = ? ORDER BY ID DESC LIMIT ?
+            ) AS kept
+        )`
+
+	SelectPostRevisionsQuery = `
+        SELECT r.ID, r.Title, r.Content, r.Visibility, r.Tags, r.CreatedAt
+        FROM PostRevisions r
+        JOIN Posts p ON p.ID = r.PostID
+        WHERE r.PostID = ? AND p.UserID = ?
+        ORDER BY r.ID ASC`
+
+	SelectPlainPostRevisionsQuery = `SELECT ID, Title, Content, Tags FROM PostRevisions WHERE PostID = ? AND Visibility <> 'private'`
+
+	UpdatePostRevisionQuery = `UPDATE PostRevisions SET Title = ?, Content = ?, Visibility = ?, Tags = ? WHERE ID = ?`
+)
+
+const (
+	InsertShareLinkQuery = `
+        INSERT INTO PostShareLinks (Token, PostID, LinkKey, Title, Content, Tags, ExpiresAt)
+        VALUES (?, ?, ?, ?, ?, ?, ?)`
+
+	CountShareLinksQuery = `SELECT COUNT(*) FROM PostShareLinks WHERE PostID = ?`
+
+	SelectShareLinksOfPostQuery = `
+        SELECT s.ID, s.Token, s.LinkKey, s.ExpiresAt, s.CreatedAt
+        FROM PostShareLinks s
+        JOIN Posts p ON p.ID = s.PostID
+        WHERE s.PostID = ? AND p.UserID = ?
+        ORDER BY s.ID DESC`
+
+	// Links only open while the post is still private and the link hasn't expired
+	SelectSharedPostQuery = `
+        SELECT s.PostID, s.Title, s.Content, s.Tags, s.ExpiresAt, p.CreatedAt,
+            u.Username, COALESCE(u.DisplayName, ''), u.AvatarUpdatedAt
+        FROM PostShareLinks s
+        JOIN Posts p ON p.ID = s.PostID
+        JOIN Users u ON u.ID = p.UserID
+        WHERE s.Token = ? AND p.Visibility = 'private'
+          AND (s.ExpiresAt IS NULL OR s.ExpiresAt > ?)`
+
+	UpdateShareLinkContentQuery = `UPDATE PostShareLinks SET Title = ?, Content = ?, Tags = ? WHERE ID = ?`
+
+	DeleteShareLinkQuery = `
+        DELETE FROM PostShareLinks
+        WHERE Token = ? AND PostID IN (SELECT ID FROM Posts WHERE ID = ? AND UserID = ?)`
+
+	DeleteShareLinksOfPostQuery  = `DELETE FROM PostShareLinks WHERE PostID = ?`
+	DeleteExpiredShareLinksQuery = `DELETE FROM PostShareLinks WHERE ExpiresAt IS NOT NULL AND ExpiresAt <= ?`
+)
+
+const (
+	// Everything the viewer needs to know about another user for the buttons on their profile
+	SelectUserRelationshipQuery = `
+        SELECT
+            u.ID,
+            u.IsPrivate,
+            EXISTS (SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = u.ID),
+            EXISTS (SELECT 1 FROM Follow_Requests r WHERE r.requester_id = ? AND r.target_id = u.ID),
+            EXISTS (SELECT 1 FROM User_Blocks b WHERE b.blocker_id = ? AND b.blocked_id = u.ID),
+            EXISTS (SELECT 1 FROM User_Blocks b WHERE b.blocker_id = u.ID AND b.blocked_id = ?),
+            EXISTS (SELECT 1 FROM User_Mutes m WHERE m.muter_id = ? AND m.muted_id = u.ID),
+            EXISTS (SELECT 1 FROM User_Follows f WHERE f.follower_id = u.ID AND f.following_id = ?)
+        FROM Users u
+        WHERE u.Username = ?`
+
+	InsertFollowRequestQuery = `INSERT INTO Follow_Requests (requester_id, target_id) VALUES (?, ?)`
+	DeleteFollowRequestQuery = `DELETE FROM Follow_Requests WHERE requester_id = ? AND target_id = ?`
+
+	SelectFollowRequestsQuery = `
+        SELECT
+            u.Username, COALESCE(u.DisplayName, ''), u.AvatarUpdatedAt, r.created_at,
+            FALSE,
+            EXISTS (SELECT 1 FROM User_Follows y WHERE y.follower_id = r.target_id AND y.following_id = u.ID),
+            Count(*) OVER() AS total_count
+        FROM Follow_Requests r
+        JOIN Users u ON u.ID = r.requester_id
+        WHERE r.target_id = ?
+        ORDER BY r.created_at DESC, u.ID DESC
+        LIMIT ? OFFSET ?`
+
+	// Turning a private account public lets in everyone who was still waiting
+	ApproveAllFollowRequestsQuery = `
+        INSERT INTO User_Follows (follower_id, following_id)
+        SELECT requester_id, target_id FROM Follow_Requests WHERE target_id = ?`
+
+	DeleteFollowRequestsToUserQuery = `DELETE FROM Follow_Requests WHERE target_id = ?`
+
+	InsertBlockQuery = `INSERT INTO User_Blocks (blocker_id, blocked_id) VALUES (?, ?)`
+	DeleteBlockQuery = `DELETE FROM User_Blocks WHERE blocker_id = ? AND blocked_id = ?`
+
+	DeleteFollowsBetweenQuery = `
+        DELETE FROM User_Follows
+        WHERE (follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)`
+
+	DeleteFollowRequestsBetweenQuery = `
+        DELETE FROM Follow_Requests
+        WHERE (requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)`
+
+	InsertMuteQuery = `INSERT INTO User_Mutes (muter_id, muted_id) VALUES (?, ?)`
+	DeleteMuteQuery = `DELETE FROM User_Mutes WHERE muter_id = ? AND muted_id = ?`
+
+	// Users who blocked the viewer, or were blocked by them, are left out of both lists.
+	// Each user also says whether they follow the viewer & whether the viewer follows them.
+	SelectFollowersQuery = `
+        SELECT
+            u.Username, COALESCE(u.DisplayName, ''), u.AvatarUpdatedAt, f.created_at,
+            EXISTS (SELECT 1 FROM User_Follows y WHERE y.follower_id = u.ID AND y.following_id = ?),
+            EXISTS (SELECT 1 FROM User_Follows y WHERE y.follower_id = ? AND y.following_id = u.ID),
+            Count(*) OVER() AS total_count
+        FROM User_Follows f
+        JOIN Users u ON u.ID = f.follower_id
+        WHERE f.following_id = ? AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = u.ID) OR (b.blocker_id = u.ID AND b.blocked_id = ?)
+        )
+        ORDER BY f.created_at DESC, u.ID DESC
+        LIMIT ? OFFSET ?`
+
+	SelectFollowingQuery = `
+        SELECT
+            u.Username, COALESCE(u.DisplayName, ''), u.AvatarUpdatedAt, f.created_at,
+            EXISTS (SELECT 1 FROM User_Follows y WHERE y.follower_id = u.ID AND y.following_id = ?),
+            EXISTS (SELECT 1 FROM User_Follows y WHERE y.follower_id = ? AND y.following_id = u.ID),
+            Count(*) OVER() AS total_count
+        FROM User_Follows f
+        JOIN Users u ON u.ID = f.following_id
+        WHERE f.follower_id = ? AND NOT EXISTS (
+            SELECT 1 FROM User_Blocks b
+            WHERE (b.blocker_id = ? AND b.blocked_id = u.ID) OR (b.blocker_id = u.ID AND b.blocked_id = ?)
+        )
+        ORDER BY f.created_at DESC, u.ID DESC
+        LIMIT ? OFFSET ?`
+)
+
+const (
+	// Bookmarks are only for posts the user can read that aren't private
+	SelectBookmarkablePostQuery = `
+        SELECT EXISTS (
+            SELECT 1 FROM Posts p
+            JOIN Users u ON u.ID = p.UserID
+            WHERE p.ID = ? AND (p.Visibility IN ('public', 'unlisted') OR (p.Visibility = 'followers' AND (p.UserID = ? OR EXISTS (
+                SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+            ))))
+            AND (p.UserID = ? OR u.IsPrivate = FALSE OR EXISTS (
+                SELECT 1 FROM User_Follows f WHERE f.follower_id = ? AND f.following_id = p.UserID
+            ))
+            AND NOT EXISTS (
+                SELECT 1 FROM User_Blocks b
+                WHERE (b.blocker_id = ? AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = ?)
+            )
+        )`
+
+	// Saving a post again just moves it to another collection
+	UpsertBookmarkQuery = `
+        INSERT INTO Bookmarks (UserID, PostID, CollectionID) VALUES (?, ?, ?)
+        ON DUPLICATE KEY UPDATE CollectionID = ?`
+
+	DeleteBookmarkQuery = `DELETE FROM Bookmarks WHERE UserID = ? AND PostID = ?`
+
+	SelectBookmarkQuery = `SELECT COALESCE(CollectionID, 0) FROM Bookmarks WHERE UserID = ? AND PostID = ?`
+
+	// Posts that stopped being readable stay bookmarked but are left out until they are again
+	SelectSavedPostsQuery = `
+        SELECT
+            p.ID,
+            p.Title,
+            p.Content,
+            p.CreatedAt,
+            p.Visibility,
+            u.Username AS AuthorUsername,
+            COALESCE(u.DisplayName, '') AS AuthorDisplayName,
+            bm.CreatedAt,
+            COALESCE(c.Name, ''),
+            Count(*) OVER() AS total_count
+        FROM Bookmarks bm
+        JOIN Posts p ON p.ID = bm.PostID
+        JOIN Users u ON u.ID = p.UserID
+        LEFT JOIN BookmarkCollections c ON c.ID = bm.CollectionID
+        WHERE bm.UserID = ? AND (? = 0 OR bm.CollectionID = ?)
+          AND (p.Visibility IN ('public', 'unlisted') OR (p.Visibility = 'followers' AND (p.UserID = bm.UserID OR EXISTS (
+              SELECT 1 FROM User_Follows f WHERE f.follower_id = bm.UserID AND f.following_id = p.UserID
+          ))))
+          AND (p.UserID = bm.UserID OR u.IsPrivate = FALSE OR EXISTS (
+              SELECT 1 FROM User_Follows f WHERE f.follower_id = bm.UserID AND f.following_id = p.UserID
+          ))
+          AND NOT EXISTS (
+              SELECT 1 FROM User_Blocks b
+              WHERE (b.blocker_id = bm.UserID AND b.blocked_id = p.UserID) OR (b.blocker_id = p.UserID AND b.blocked_id = bm.UserID)
+          )
+        ORDER BY bm.CreatedAt DESC, p.ID DESC
+        LIMIT ? OFFSET ?`
+
+	SelectBookmarkCollectionsQuery = `
+        SELECT ID, Name FROM BookmarkCollections WHERE UserID = ? ORDER BY Name ASC`
+
+	CountBookmarkCollectionsQuery = `SELECT COUNT(*) FROM BookmarkCollections WHERE UserID = ?`
+
+	CheckBookmarkCollectionNameQuery = `
+        SELECT EXISTS (SELECT 1 FROM BookmarkCollections WHERE UserID = ? AND Name = ?)`
+
+	CheckBookmarkCollectionOwnerQuery = `
+        SELECT EXISTS (SELECT 1 FROM BookmarkCollections WHERE ID = ? AND UserID = ?)`
+
+	InsertBookmarkCollectionQuery = `INSERT INTO BookmarkCollections (UserID, Name) VALUES (?, ?)`
+
+	// Bookmarks in a removed collection are kept, just without a collection
+	ClearBookmarkCollectionQuery  = `UPDATE Bookmarks SET CollectionID = NULL WHERE CollectionID = ? AND UserID = ?`
+	DeleteBookmarkCollectionQuery = `DELETE FROM BookmarkCollections WHERE ID = ? AND UserID = ?`
+)
@@ -1,170 +1,121 @@
 package main
 
 import (
-	"encoding/gob"
-	"fmt"
-	"html/template"
+	"context"
 	"log"
-	"net/http"
+	"log/slog"
 	"os"
-
-	"App/internal/api"
-	"App/internal/types"
-	"database/sql"
+	"os/signal"
+	"syscall"
+
+	"App/internal/cli"
+	"App/internal/config"
+	"App/internal/logging"
+	"App/internal/migrations"
+	"App/internal/server"
+	"App/internal/storage"
+	"App/internal/tracing"
+	"App/internal/utils"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	_ "github.com/go-sql-driver/mysql"
-	"github.com/gorilla/sessions"
 )
 
 func main() {
+	// Run an admin command instead of the server when one is given
+	if len(os.Args) > 1 {
+		if err := cli.Run(os.Args[1:]); err != nil {
+			log.Fatal(err)
+		}
+
+		return
+	}
+
 	// Set Gin to release mode
 	gin.SetMode(gin.ReleaseMode)
 
-	// Open or create the log file
-	logFile, err := os.OpenFile("/home/ec2-user/logs/posto.log", os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0666)
+	// SIGTERM from systemd (or Ctrl-C) starts a graceful shutdown
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM, os.Interrupt)
+	defer stop()
+
+	// Load configuration from the system environment variables
+	cfg, err := config.Load()
 
 	if err != nil {
-		log.Fatal("Error opening log file:", err)
+		log.Fatal("Error loading configuration:", err)
 	}
 
-	defer logFile.Close() // Ensure the log file is closed when done
+	// Switch every log line over to leveled JSON, written to stdout or a rotated file
+	logOutput, err := logging.Setup(cfg)
 
-	// Set the log output to the log file
-	log.SetOutput(logFile)
+	if err != nil {
+		log.Fatal("Error setting up logging:", err)
+	}
+
+	defer logOutput.Close()
 
-	// Get required system environment variables
-	mysqlUser := os.Getenv("MYSQL_USER")
-	mysqlPassword := os.Getenv("MYSQL_PASSWORD")
-	mysqlHost := os.Getenv("MYSQL_HOST")
-	mysqlDB := os.Getenv("MYSQL_DB")
-	cookieStoreKey := os.Getenv("COOKIE_STORE_KEY")
+	// Send spans to stdout or an OTLP collector when tracing is turned on
+	shutdownTracing, err := tracing.Setup(context.Background(), cfg)
 
-	// Ensure all necessary environment variables are present
-	if mysqlUser == "" || mysqlPassword == "" || mysqlHost == "" || mysqlDB == "" || cookieStoreKey == "" {
-		log.Fatal("Required environment variables are missing.")
+	if err != nil {
+		fatal("Error setting up tracing", err)
 	}
 
-	// Connect to database through formatted connection string
-	database, err := sql.Open("mysql", fmt.Sprintf("%s:%s@tcp(%s:3306)/%s", mysqlUser, mysqlPassword, mysqlHost, mysqlDB))
+	defer shutdownTracing(context.Background())
+
+	// Connect to database through formatted connection string, with a span for every query
+	database, err := tracing.OpenDB("mysql", cfg.DatabaseDSN())
 
 	if err != nil {
-		log.Fatal("Error opening database connection:", err)
+		fatal("Error opening database connection", err)
 	}
 
 	// Ensure the database connection is valid
 	if err := database.Ping(); err != nil {
-		log.Fatal("Error pinging the database:", err)
+		fatal("Error pinging the database", err)
 	}
 	defer database.Close()
 
-	// Register the User type for encoding/decoding
-	gob.Register(types.User{})
-
-	// Create a cookie store for session management
-	cookieStore := sessions.NewCookieStore([]byte(cookieStoreKey))
-	cookieStore.Options.HttpOnly = true
-	cookieStore.Options.SameSite = http.SameSiteStrictMode
-	cookieStore.Options.Domain = "postoblog.duckdns.org"
-	cookieStore.Options.MaxAge = 604800
-	cookieStore.Options.Secure = true
-
-	// Create app struct for accessing session & database
-	app := &types.App{SessionStore: cookieStore, Database: database}
-
-	// Create a router to map incoming requests to handler functions
-	router := gin.New()
-
-	// Use CORS middleware
-	allowedOrigins := []string{
-		"https://codingwithkarim.github.io",
-		"https://postoblog.duckdns.org",
+	// Bring the database schema up to date
+	if err := migrations.Apply(database); err != nil {
+		fatal("Error applying database migrations", err)
 	}
 
-	router.Use(api.CORSMiddleware(allowedOrigins))
+	// Open the blob store that holds uploaded images
+	mediaStore, err := storage.Open(cfg)
 
-	// Use Gin's recovery middleware to recover from panics
-	router.Use(gin.Recovery())
+	if err != nil {
+		fatal("Error opening media storage", err)
+	}
 
-	// Use Gin's logger middleware to log requests
-	router.Use(gin.LoggerWithConfig(gin.LoggerConfig{
-		Output: logFile,
-	}))
+	// Wire the router, listeners & background jobs around the connections opened above
+	srv, err := server.New(cfg, server.Deps{DB: database, MediaStore: mediaStore})
 
-	// Set up trusted proxies
-	if err := router.SetTrustedProxies([]string{"127.0.0.1"}); err != nil {
-		log.Fatalf("Failed to set trusted proxies: %v", err)
+	if err != nil {
+		fatal("Error setting up server", err)
 	}
 
-	// This function map allows us to use custom functions in our HTML templates
-	templateFunctions := template.FuncMap{
-		"Iterate": func(start, end int) []int {
-			items := make([]int, end-start+1)
-			for i := range items {
-				items[i] = start + i
-			}
-			return items
-		},
-		"add":      func(a, b int) int { return a + b },
-		"subtract": func(a, b int) int { return a - b },
-		"max": func(a, b int) int {
-			if a > b {
-				return a
-			}
-			return b
-		},
-		"min": func(a, b int) int {
-			if a < b {
-				return a
-			}
-			return b
-		},
+	// Run until a server fails or a shutdown signal arrives
+	if err := srv.Start(ctx); err != nil {
+		fatal("Error starting HTTP server", err)
 	}
 
-	// Create a custom template with the template functions defined above
-	tmplate, err := template.New("").Funcs(templateFunctions).ParseGlob("./internal/templates/*.html")
+	slog.Info("Shutting down, draining open requests")
 
-	if err != nil {
-		log.Fatalf("Error parsing templates: %v", err)
-	}
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), utils.SHUTDOWN_TIMEOUT_SEC*time.Second)
+	defer cancel()
 
-	// Set custom template for Gin router
-	// This allows us to use the custom functions in our HTML templates
-	router.SetHTMLTemplate(tmplate)
-
-	// Middleware for blocking suspicious IPs
-	router.Use(api.BlockSuspiciousIPsAndRateLimit)
-
-	// Invalid Routes
-	router.NoRoute(api.GetNotFoundHandler)
-
-	// Public Routes (No authentication required)
-	router.GET("/", api.OptionalAuth(app), api.GetHomePageHandler)
-	router.GET("/profile/:username", api.OptionalAuth(app), api.RenderUserProfilePageHandler(app.Database))
-	router.GET("/blogpost/:ID", api.OptionalAuth(app), api.RenderSingleBlogPostHandler(app))
-	router.GET("/login", api.GetLoginPageHandler)
-	router.GET("/signup", api.GetSignupPageHandler)
-	router.POST("/login", api.PostLoginHandler(app))
-	router.POST("/signup", api.PostSignupHandler(app))
-
-	// Authenticated Routes (Require authentication)
-	authRoutes := router.Group("/")
-	authRoutes.Use(api.RequireAuth(app))
-	{
-		authRoutes.GET("/edit/:ID", api.GetCreateOrEditPostPageHandler(app))
-		authRoutes.POST("/createpost", api.CreatePostHandler(app))
-		authRoutes.POST("/edit/:ID", api.UpdatePostHandler(app))
-		authRoutes.GET("/createpost", api.GetCreateOrEditPostPageHandler(app))
-		authRoutes.POST("/delete/:ID", api.DeletePostHandler(app))
-		authRoutes.POST("/logout", api.PostLogoutHandler(app))
-		authRoutes.POST("/blogpost/:ID/comment", api.PostCommentHandler(app))
-		authRoutes.POST("/blogpost/:ID/like", api.PostLikeHandler(app))
-		authRoutes.POST("/follow/:username", api.PostFollowHandler(app))
-		authRoutes.GET("/feed", api.GetHomeFeedHandler(app))
+	// Stop accepting connections, wait for in-flight requests & running jobs before the database closes
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("HTTP server did not shut down cleanly", "error", err)
 	}
 
-	// Start the server on port 8080 with SSL
-	if err := router.Run(":8080"); err != nil {
-		log.Fatal("Error starting HTTP server:", err)
-	}
+	slog.Info("Shutdown complete")
+}
+
+func fatal(message string, err error) {
+	// Log startup failures at error level before exiting
+	slog.Error(message, "error", err)
+	os.Exit(1)
 }
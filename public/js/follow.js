@@ -1,5 +1,12 @@
 const followButton = document.getElementById("follow-btn");
 
+// Button text for each status the server can answer with
+const followLabels = {
+    following: "Unfollow",
+    requested: "Requested",
+    none: "Follow",
+};
+
 if (followButton) {
     followButton.addEventListener("click", function (event) {
         event.preventDefault();
@@ -12,12 +19,20 @@ function toggleFollow() {
         method: 'POST',
         headers: {
             'X-Requested-With': 'XMLHttpRequest',
+            'Accept': 'application/json',
         }
     })
-        .then(() => {
-            followButton.innerText = followButton.innerText === "Follow" ? "Unfollow" : "Follow";
+        .then(response => {
+            if (!response.ok) {
+                throw new Error(`Follow request failed with status ${response.status}`);
+            }
+
+            return response.json();
+        })
+        .then(data => {
+            followButton.innerText = followLabels[data.status] || "Follow";
         })
         .catch(error => {
             console.error("Error toggling follow:", error);
         });
-}
\ No newline at end of file
+}
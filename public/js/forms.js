@@ -0,0 +1,11 @@
+// Ask before submitting forms that delete or change something for good
+document.querySelectorAll('form[data-confirm]').forEach((form) => {
+    form.addEventListener('submit', (e) => {
+        if (!confirm(form.dataset.confirm)) e.preventDefault();
+    });
+});
+
+// Select the whole value of read-only fields on focus so it can be copied in one go
+document.querySelectorAll('input[data-select-on-focus]').forEach((input) => {
+    input.addEventListener('focus', () => input.select());
+});
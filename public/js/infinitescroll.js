@@ -0,0 +1,141 @@
+const scrollWrapper = document.getElementById("posts-wrapper");
+
+if (scrollWrapper && scrollWrapper.dataset.nextCursor) {
+    setupInfiniteScroll(scrollWrapper);
+}
+
+function setupInfiniteScroll(wrapper) {
+    let nextCursor = wrapper.dataset.nextCursor;
+    let loading = false;
+
+    // Sentinel element that triggers a fetch when it scrolls into view
+    const sentinel = document.createElement("div");
+    sentinel.className = "scroll-sentinel";
+    wrapper.after(sentinel);
+
+    const observer = new IntersectionObserver(async (entries) => {
+        if (!entries[0].isIntersecting || loading || !nextCursor) return;
+
+        loading = true;
+
+        try {
+            const url = new URL(wrapper.dataset.source, window.location.origin);
+            url.searchParams.set("cursor", nextCursor);
+
+            const response = await fetch(url, {
+                headers: { "Accept": "application/json" }
+            });
+
+            if (!response.ok) {
+                console.error("Error loading more posts:", response.status);
+                observer.disconnect();
+                return;
+            }
+
+            const data = await response.json();
+
+            (data.posts || []).forEach(post => {
+                const divider = document.createElement("hr");
+                divider.className = "my-4";
+                wrapper.append(renderPost(post), divider);
+            });
+
+            // Numbered pages no longer line up once extra posts are appended
+            const pagination = document.getElementById("pagination-controls");
+            if (pagination) pagination.style.display = "none";
+
+            nextCursor = data.nextCursor;
+
+            if (!nextCursor) observer.disconnect();
+        } catch (error) {
+            console.error("Error loading more posts:", error);
+            observer.disconnect();
+        } finally {
+            loading = false;
+        }
+    });
+
+    observer.observe(sentinel);
+}
+
+function renderPost(post) {
+    const author = post.username || document.body.dataset.username;
+
+    const preview = document.createElement("div");
+    preview.className = "post-preview";
+    preview.dataset.postId = post.id;
+
+    const link = document.createElement("a");
+    link.href = `/blogpost/${post.id}`;
+    link.target = "_blank";
+
+    const title = document.createElement("h2");
+    title.className = "post-title post-title-page";
+    title.textContent = post.title;
+
+    const subtitle = document.createElement("h3");
+    subtitle.className = "post-subtitle post-subtitle-page";
+    subtitle.textContent = post.content;
+
+    link.append(title, subtitle);
+
+    const meta = document.createElement("p");
+    meta.className = "post-meta";
+
+    const authorLink = document.createElement("a");
+    authorLink.href = `/profile/${author}`;
+    authorLink.style.color = "cornflowerblue";
+    authorLink.textContent = post.displayName || document.body.dataset.displayName || author;
+
+    meta.append("Posted by ", authorLink, ` on ${post.createdAt}`);
+    preview.append(link, meta);
+
+    if (post.tags && post.tags.length) {
+        const tags = document.createElement("div");
+        tags.className = "post-tags";
+
+        post.tags.forEach(tag => {
+            const pill = document.createElement("a");
+            pill.className = "tag-pill";
+            pill.href = `/tag/${encodeURIComponent(tag)}`;
+            pill.textContent = `#${tag}`;
+            tags.appendChild(pill);
+        });
+
+        preview.appendChild(tags);
+    }
+
+    if (document.body.dataset.owner === "true") {
+        preview.appendChild(renderOwnerActions(post.id));
+    }
+
+    return preview;
+}
+
+function renderOwnerActions(postID) {
+    const actions = document.createElement("div");
+    actions.className = "post-actions";
+
+    const edit = document.createElement("a");
+    edit.href = `/edit/${postID}`;
+    edit.className = "edit-link";
+    edit.innerHTML = '<i class="fas fa-edit"></i> Edit';
+
+    const form = document.createElement("form");
+    form.action = `/delete/${postID}`;
+    form.method = "POST";
+    form.className = "delete-form";
+    form.addEventListener("submit", (e) => {
+        if (!confirm("Are you sure you want to delete this post?")) e.preventDefault();
+    });
+
+    const button = document.createElement("button");
+    button.type = "submit";
+    button.className = "delete-button";
+    button.innerHTML = '<i class="fas fa-trash-alt"></i> Delete';
+
+    form.appendChild(button);
+    actions.append(edit, form);
+
+    return actions;
+}
@@ -0,0 +1,79 @@
+const mediaList = document.getElementById("media-list");
+const mediaUpload = document.getElementById("media-upload");
+const mediaStatus = document.getElementById("media-status");
+const mediaHint = mediaStatus ? mediaStatus.textContent : "";
+
+function addMediaItem(media) {
+    const item = document.createElement("li");
+    item.className = "media-item";
+
+    const preview = document.createElement("img");
+    preview.src = media.thumbnailUrl;
+    preview.alt = "";
+
+    // The hidden input attaches the upload when the post is saved
+    const input = document.createElement("input");
+    input.type = "hidden";
+    input.name = "media";
+    input.value = media.id;
+
+    const remove = document.createElement("button");
+    remove.type = "button";
+    remove.className = "media-remove";
+    remove.setAttribute("aria-label", "Remove image");
+    remove.innerHTML = '<i class="fas fa-times"></i>';
+
+    item.append(preview, input, remove);
+    mediaList.append(item);
+}
+
+async function uploadMedia(file) {
+    const visibility = document.querySelector('input[name="visibility"]:checked');
+
+    const body = new FormData();
+    body.append("file", file);
+    body.append("visibility", visibility ? visibility.value : "private");
+
+    const response = await fetch("/media", {
+        method: "POST",
+        headers: { Accept: "application/json" },
+        body,
+    });
+
+    const data = await response.json().catch(() => ({}));
+
+    if (!response.ok) {
+        throw new Error(data.error || `Failed to upload ${file.name}`);
+    }
+
+    return data;
+}
+
+if (mediaList && mediaUpload) {
+    mediaList.addEventListener("click", (event) => {
+        const remove = event.target.closest(".media-remove");
+
+        if (remove) {
+            remove.closest(".media-item").remove();
+        }
+    });
+
+    mediaUpload.addEventListener("change", async () => {
+        const files = Array.from(mediaUpload.files);
+        mediaUpload.disabled = true;
+
+        try {
+            for (const [index, file] of files.entries()) {
+                mediaStatus.textContent = `Uploading ${index + 1} of ${files.length}...`;
+                addMediaItem(await uploadMedia(file));
+            }
+
+            mediaStatus.textContent = mediaHint;
+        } catch (error) {
+            mediaStatus.textContent = error.message;
+        } finally {
+            mediaUpload.disabled = false;
+            mediaUpload.value = "";
+        }
+    });
+}
@@ -16,8 +16,13 @@ function updatePageInput(event) {
     const maxPage = parseInt(input.max.trim());
 
     if (pageNumber && pageNumber >= 1 && pageNumber <= maxPage) {
-        const path = form.dataset.redirect;
+        const params = new URLSearchParams({ page: pageNumber });
 
-        window.location.href = `${path}/?page=${pageNumber}`;
+        // Carry over any filters the listing was opened with
+        if (form.dataset.window) params.set("window", form.dataset.window);
+        if (form.dataset.tag) params.set("tag", form.dataset.tag);
+        if (form.dataset.collection) params.set("collection", form.dataset.collection);
+
+        window.location.href = `${form.dataset.redirect}/?${params}`;
     }
 }
\ No newline at end of file
@@ -0,0 +1,73 @@
+const base64ToBytes = (value) => {
+  const normalized = value.replace(/-/g, '+').replace(/_/g, '/');
+  const padded = normalized + '='.repeat((4 - (normalized.length % 4)) % 4);
+  return Uint8Array.from(atob(padded), (c) => c.charCodeAt(0));
+};
+
+// Values are the nonce followed by the AES-GCM ciphertext, the same layout the server writes
+const decryptValue = async (key, value) => {
+  const data = base64ToBytes(value);
+  const plaintext = await crypto.subtle.decrypt(
+    { name: 'AES-GCM', iv: data.slice(0, 12) },
+    key,
+    data.slice(12)
+  );
+
+  return new TextDecoder().decode(plaintext);
+};
+
+const showSharedPostError = (message) => {
+  const content = document.getElementById('shared-content');
+  content.textContent = message;
+  content.classList.add('text-danger');
+};
+
+const setupSharedPost = async () => {
+  const post = document.getElementById('shared-post');
+
+  if (!post) return;
+
+  // The key only lives in the fragment, so it is never sent to the server
+  const rawKey = window.location.hash.slice(1);
+
+  if (!rawKey) {
+    showSharedPostError('This link is missing its key. Ask the author to share the full link again.');
+    return;
+  }
+
+  if (!window.crypto || !window.crypto.subtle) {
+    showSharedPostError('Your browser cannot decrypt this post. Open the link over HTTPS in an up-to-date browser.');
+    return;
+  }
+
+  try {
+    const key = await crypto.subtle.importKey('raw', base64ToBytes(rawKey), 'AES-GCM', false, ['decrypt']);
+    const [title, content, tags] = await Promise.all([
+      decryptValue(key, post.dataset.title),
+      decryptValue(key, post.dataset.content),
+      decryptValue(key, post.dataset.tags),
+    ]);
+
+    // Text is only ever set as text, never parsed as HTML
+    document.getElementById('post-title-post').textContent = title;
+    document.title = title;
+
+    const contentElement = document.getElementById('shared-content');
+    contentElement.textContent = content;
+    contentElement.classList.remove('text-muted');
+
+    const tagsElement = document.getElementById('shared-tags');
+
+    tags.split(',').filter(Boolean).forEach((tag) => {
+      const pill = document.createElement('span');
+      pill.className = 'tag-pill';
+      pill.textContent = `#${tag}`;
+      tagsElement.appendChild(pill);
+    });
+  } catch (error) {
+    console.error('Failed to decrypt shared post', error);
+    showSharedPostError('This link could not be opened. It may have been copied incompletely.');
+  }
+};
+
+document.addEventListener('DOMContentLoaded', setupSharedPost);